@@ -2,6 +2,8 @@ package filter
 
 import (
 	"fmt"
+	"math/big"
+	"strings"
 )
 
 const (
@@ -37,14 +39,24 @@ type AttributeExpression struct {
 	AttributePath AttributePath
 	Operator      CompareOperator
 	CompareValue  any
+	// RawValue is the compare value as it appears in the raw data, e.g.
+	// "\"bjensen\"" or "1e3". It is only set if positions are tracked.
+	RawValue string
+	Span     Span
 }
 
 func (e AttributeExpression) String() string {
 	s := fmt.Sprintf("%v %s", e.AttributePath, e.Operator)
 	if e.CompareValue != nil {
-		switch e.CompareValue.(type) {
+		switch v := e.CompareValue.(type) {
 		case string:
-			s += fmt.Sprintf(" %q", e.CompareValue)
+			var b strings.Builder
+			writeString(&b, v)
+			s += " " + b.String()
+		case *big.Rat:
+			var b strings.Builder
+			writeRat(&b, v)
+			s += " " + b.String()
 		default:
 			s += fmt.Sprintf(" %v", e.CompareValue)
 		}
@@ -65,6 +77,7 @@ type AttributePath struct {
 	URIPrefix     *string
 	AttributeName string
 	SubAttribute  *string
+	Span          Span
 }
 
 func (p AttributePath) String() string {
@@ -113,6 +126,7 @@ type Expression interface {
 type LogicalExpression struct {
 	Left, Right Expression
 	Operator    LogicalOperator
+	Span        Span
 }
 
 func (e LogicalExpression) String() string {
@@ -139,6 +153,7 @@ type LogicalOperator string
 // NotExpression represents an 'not' node.
 type NotExpression struct {
 	Expression Expression
+	Span       Span
 }
 
 func (e NotExpression) String() string {
@@ -158,6 +173,7 @@ type Path struct {
 	AttributePath   AttributePath
 	ValueExpression Expression
 	SubAttribute    *string
+	Span            Span
 }
 
 func (p Path) String() string {
@@ -180,10 +196,22 @@ func (p *Path) SubAttributeName() string {
 	return ""
 }
 
+// Span is the location of a node within the raw data, as byte offsets. The
+// span of a node in parentheses does not include the parentheses. Spans are
+// only set if positions are tracked (see TrackPositions), otherwise they are
+// zero.
+type Span struct {
+	// Start is the offset of the first byte of the node.
+	Start int
+	// End is the offset directly after the last byte of the node.
+	End int
+}
+
 // ValuePath represents a filter on a attribute path.
 type ValuePath struct {
 	AttributePath AttributePath
 	ValueFilter   Expression
+	Span          Span
 }
 
 func (e ValuePath) String() string {
@@ -2,147 +2,402 @@ package filter
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
-	"github.com/di-wu/parser"
-	"github.com/di-wu/parser/ast"
-	"github.com/scim2/filter-parser/v2/internal/grammar"
 	"github.com/scim2/filter-parser/v2/internal/types"
+	"math/big"
 	"strconv"
 	"strings"
+	"unicode"
+	"unicode/utf16"
+	"unicode/utf8"
 )
 
+// compareOperators are all the compare operators, except 'pr', in the order in
+// which they are defined in the grammar.
+var compareOperators = [...]CompareOperator{EQ, NE, CO, SW, EW, GT, LT, GE, LE}
+
 // ParseAttrExp parses the given raw data as an AttributeExpression.
 func ParseAttrExp(raw []byte) (AttributeExpression, error) {
 	return parseAttrExp(raw, config{})
 }
 
 // ParseAttrExpNumber parses the given raw data as an AttributeExpression with json.Number.
+//
+// Deprecated: Use NewParser(UseNumber()).ParseAttrExp instead.
 func ParseAttrExpNumber(raw []byte) (AttributeExpression, error) {
 	return parseAttrExp(raw, config{useNumber: true})
 }
 
-func parseAttrExp(raw []byte, c config) (AttributeExpression, error) {
-	p, err := ast.New(raw)
-	if err != nil {
-		return AttributeExpression{}, err
+func isHex(c byte) bool {
+	return isDigit(c) || 'A' <= c && c <= 'F' || 'a' <= c && c <= 'f'
+}
+
+func isUnescaped(c byte) bool {
+	return c >= 0x20 && c != '"' && c != '\\'
+}
+
+// mantissa returns the given number without its exponent.
+func mantissa(number string) string {
+	if i := strings.IndexAny(number, "eE"); i != -1 {
+		return number[:i]
 	}
-	node, err := grammar.AttrExp(p)
+	return number
+}
+
+func parseAttrExp(raw []byte, c config) (AttributeExpression, error) {
+	attrExp, err := parse(raw, c, typ.AttrExp, (*parser).attrExp)
 	if err != nil {
 		return AttributeExpression{}, err
 	}
-	if _, err := p.Expect(parser.EOD); err != nil {
-		return AttributeExpression{}, err
-	}
-	return c.parseAttrExp(node)
+	return *attrExp, nil
 }
 
-func (p config) parseAttrExp(node *ast.Node) (AttributeExpression, error) {
-	if node.Type != typ.AttrExp {
-		return AttributeExpression{}, invalidTypeError(typ.AttrExp, node.Type)
-	}
+// unquote decodes the escape sequences of the given string, as defined in
+// RFC 8259, Section 7. Invalid UTF-8 and unpaired surrogates are replaced by
+// the replacement character, the same as encoding/json does.
+// More info: https://tools.ietf.org/html/rfc8259#section-7
+func unquote(s string) string {
+	var b strings.Builder
+	b.Grow(len(s))
+	for i := 0; i < len(s); {
+		if s[i] != '\\' {
+			r, size := utf8.DecodeRuneInString(s[i:])
+			if r == utf8.RuneError && size == 1 {
+				b.WriteRune(unicode.ReplacementChar)
+			} else {
+				b.WriteString(s[i : i+size])
+			}
+			i += size
+			continue
+		}
 
-	children := node.Children()
-	if len(children) == 0 {
-		return AttributeExpression{}, invalidLengthError(typ.AttrExp, 1, 0)
+		switch c := s[i+1]; c {
+		case 'b':
+			b.WriteByte('\b')
+		case 'f':
+			b.WriteByte('\f')
+		case 'n':
+			b.WriteByte('\n')
+		case 'r':
+			b.WriteByte('\r')
+		case 't':
+			b.WriteByte('\t')
+		case 'u':
+			r, _ := strconv.ParseUint(s[i+2:i+6], 16, 16)
+			i += 6
+			if utf16.IsSurrogate(rune(r)) {
+				if i+6 <= len(s) && s[i] == '\\' && s[i+1] == 'u' {
+					r2, _ := strconv.ParseUint(s[i+2:i+6], 16, 16)
+					if dec := utf16.DecodeRune(rune(r), rune(r2)); dec != unicode.ReplacementChar {
+						b.WriteRune(dec)
+						i += 6
+						continue
+					}
+				}
+				r = unicode.ReplacementChar
+			}
+			b.WriteRune(rune(r))
+			continue
+		default:
+			b.WriteByte(c)
+		}
+		i += 2
 	}
+	return b.String()
+}
 
-	// AttrPath 'pr'
-	attrPath, err := parseAttrPath(children[0])
-	if err != nil {
-		return AttributeExpression{}, err
+func (p *parser) attrExp() (*AttributeExpression, bool) {
+	f := p.push(typ.AttrExp)
+	begin := p.pos
+	attrPath, ok := p.attrPath()
+	if !ok || !p.spaces(1) {
+		p.pop(f, false)
+		return nil, false
 	}
 
-	if len(children) == 1 {
-		return AttributeExpression{
+	// AttrPath SP 'pr', unless it is the start of a registered operator, e.g.
+	// "pre", since 'pr' is never followed by a letter.
+	start := p.pos
+	if p.keyword("pr") && (p.operators == nil || !p.peek(isAlpha)) {
+		p.pop(f, true)
+		p.term()
+		attrExp := AttributeExpression{
 			AttributePath: attrPath,
 			Operator:      PR,
-		}, nil
-	}
-
-	if l := len(children); l != 3 {
-		return AttributeExpression{}, invalidLengthError(typ.AttrExp, 3, l)
-	}
-
-	var (
-		compareOp    = CompareOperator(strings.ToLower(children[1].Value))
-		compareValue any
-	)
-	switch node := children[2]; node.Type {
-	case typ.False:
-		compareValue = false
-	case typ.Null:
-		compareValue = nil
-	case typ.True:
-		compareValue = true
-	case typ.Number:
-		value, err := p.parseNumber(node)
-		if err != nil {
-			return AttributeExpression{}, err
-		}
-		compareValue = value
-	case typ.String:
-		str := node.Value
-		str = strings.TrimPrefix(str, "\"")
-		str = strings.TrimSuffix(str, "\"")
-		compareValue = str
-	default:
-		return AttributeExpression{}, invalidChildTypeError(typ.AttrExp, node.Type)
-	}
-
-	return AttributeExpression{
+		}
+		if p.positions {
+			attrExp.Span = Span{Start: begin, End: p.pos}
+		}
+		return &attrExp, true
+	}
+
+	// AttrPath SP CompareOp SP CompareValue
+	p.pos = start
+	compareOp, ok := p.compareOp()
+	if !ok || !p.spaces(1) {
+		p.pop(f, false)
+		return nil, false
+	}
+	valueStart := p.pos
+	compareValue, ok := p.compareValue()
+	p.pop(f, ok)
+	if !ok {
+		return nil, false
+	}
+	p.term()
+	attrExp := AttributeExpression{
 		AttributePath: attrPath,
 		Operator:      compareOp,
 		CompareValue:  compareValue,
-	}, nil
-}
-
-func (p config) parseNumber(node *ast.Node) (any, error) {
-	var frac, exp bool
-	var nStr string
-	for _, node := range node.Children() {
-		switch t := node.Type; t {
-		case typ.Minus:
-			nStr = "-"
-		case typ.Int:
-			nStr += node.Value
-		case typ.Frac:
-			frac = true
-			children := node.Children()
-			if l := len(children); l != 1 {
-				return AttributeExpression{}, invalidLengthError(typ.Frac, 1, l)
+	}
+	if p.positions {
+		attrExp.RawValue = p.text(valueStart, p.pos)
+		attrExp.Span = Span{Start: begin, End: p.pos}
+	}
+	return &attrExp, true
+}
+
+func (p *parser) compareOp() (CompareOperator, bool) {
+	f := p.push(typ.CompareOp)
+	operators := p.operators
+	if operators == nil {
+		operators = compareOperators[:]
+	}
+	for _, op := range operators {
+		if p.keyword(string(op)) {
+			p.pop(f, true)
+			return op, true
+		}
+	}
+	p.pop(f, false)
+	return "", false
+}
+
+func (p *parser) compareValue() (any, bool) {
+	start := p.pos
+	if p.literal(typ.False, "false") {
+		return false, true
+	}
+	if p.literal(typ.Null, "null") {
+		return nil, true
+	}
+	if p.literal(typ.True, "true") {
+		return true, true
+	}
+	if number, ok := p.number(); ok {
+		return number, true
+	}
+	p.pos = start
+	if str, ok := p.string(); ok {
+		return str, true
+	}
+	p.pos = start
+	return nil, false
+}
+
+// digits consumes one or more digits.
+func (p *parser) digits() bool {
+	f := p.push(typ.Digits)
+	start := p.pos
+	for p.peek(isDigit) {
+		p.pos++
+	}
+	ok := p.pos != start
+	p.pop(f, ok)
+	return ok
+}
+
+// escape consumes an escape sequence.
+func (p *parser) escape() bool {
+	if !p.char('\\') {
+		return false
+	}
+	if p.pos < len(p.raw) {
+		switch p.raw[p.pos] {
+		case '"', '\\', '/', 'b', 'f', 'n', 'r', 't':
+			p.pos++
+			return true
+		case 'u':
+			if end := p.pos + 5; end <= len(p.raw) &&
+				isHex(p.raw[p.pos+1]) && isHex(p.raw[p.pos+2]) &&
+				isHex(p.raw[p.pos+3]) && isHex(p.raw[p.pos+4]) {
+				p.pos = end
+				return true
 			}
-			nStr += fmt.Sprintf(".%s", children[0].Value)
-		case typ.Exp:
-			exp = true
-			nStr += "e"
-			for _, node := range node.Children() {
-				switch t := node.Type; t {
-				case typ.Sign, typ.Digits:
-					nStr += node.Value
-				default:
-					return AttributeExpression{}, invalidChildTypeError(typ.Number, node.Type)
-				}
+		}
+	}
+	if p.track {
+		if p.pos < len(p.raw) && p.raw[p.pos] == 'u' {
+			// The first of the four hex digits that is missing.
+			i := p.pos + 1
+			for i < len(p.raw) && i < p.pos+5 && isHex(p.raw[i]) {
+				i++
 			}
-		default:
-			return AttributeExpression{}, invalidChildTypeError(typ.Number, node.Type)
+			p.fail(i, "a hex digit")
+			return false
+		}
+		const escaped = "\"\\/bfnrtu"
+		for i := range len(escaped) {
+			p.fail(p.pos, describe(escaped[i]))
+		}
+	}
+	return false
+}
+
+// literal consumes the given (case-insensitive) literal of the given type.
+func (p *parser) literal(literalType int, literal string) bool {
+	f := p.push(literalType)
+	ok := p.keyword(literal)
+	p.pop(f, ok)
+	return ok
+}
+
+func (p *parser) number() (any, bool) {
+	f := p.push(typ.Number)
+	start := p.pos
+
+	// Minus?
+	minus := p.push(typ.Minus)
+	p.pop(minus, p.char('-'))
+
+	// Int
+	i := p.push(typ.Int)
+	if !p.char('0') {
+		if !p.peek(func(c byte) bool { return '1' <= c && c <= '9' }) {
+			p.pop(i, false)
+			p.pop(f, false)
+			return nil, false
 		}
+		for p.pos++; p.peek(isDigit); p.pos++ {
+		}
+	}
+	p.pop(i, true)
+
+	// Frac?
+	end := p.pos
+	frac := p.push(typ.Frac)
+	isFrac := p.char('.') && p.digits()
+	p.pop(frac, isFrac)
+	if !isFrac {
+		p.pos = end
 	}
 
+	// Exp?
+	end = p.pos
+	exp := p.push(typ.Exp)
+	isExp := p.char('e') || p.char('E')
+	if isExp {
+		sign := p.push(typ.Sign)
+		p.pop(sign, p.char('-') || p.char('+'))
+		isExp = p.digits()
+	}
+	p.pop(exp, isExp)
+	if !isExp {
+		p.pos = end
+	}
+	p.pop(f, true)
+
+	nStr := p.text(start, p.pos)
 	if p.useNumber {
-		return json.Number(nStr), nil
+		return json.Number(nStr), true
 	}
 
-	f, err := strconv.ParseFloat(nStr, 64)
-	if err != nil {
-		return AttributeExpression{}, &internalError{
+	// Integers can not contain fractional or exponent parts.
+	// More info: https://tools.ietf.org/html/rfc7643#section-2.3.4
+	if !isFrac && !isExp {
+		if n, err := strconv.ParseInt(nStr, 10, 64); err == nil {
+			// Integers are returned as an int if they fit, as they were
+			// before larger integers were supported.
+			if i := int(n); int64(i) == n {
+				return i, true
+			}
+			return n, true
+		}
+		n, ok := new(big.Int).SetString(nStr, 10)
+		if !ok {
+			p.err = &internalError{
+				Message: fmt.Sprintf("invalid integer: %q", nStr),
+			}
+		}
+		return n, true
+	}
+
+	// Values that overflow or underflow a float64 are rejected, instead of
+	// silently being replaced by infinity or zero.
+	n, err := strconv.ParseFloat(nStr, 64)
+	switch {
+	case errors.Is(err, strconv.ErrRange),
+		n == 0 && strings.ContainsAny(mantissa(nStr), "123456789"):
+		p.err = newParseError(p.raw, start, typ.Number, []string{"a number within the range of a float64"})
+		return nil, false
+	case err != nil:
+		p.err = &internalError{
 			Message: err.Error(),
 		}
+		return nil, false
 	}
+	if p.useRat {
+		if n == 0 {
+			return new(big.Rat), true
+		}
+		r, ok := new(big.Rat).SetString(nStr)
+		if !ok {
+			p.err = &internalError{
+				Message: fmt.Sprintf("invalid decimal: %q", nStr),
+			}
+		}
+		return r, true
+	}
+	return n, true
+}
 
-	// Integers can not contain fractional or exponent parts.
-	// More info: https://tools.ietf.org/html/rfc7643#section-2.3.4
-	if !frac && !exp {
-		return int(f), nil
+// string parses a JSON string, as defined in RFC 8259, Section 7.
+// More info: https://tools.ietf.org/html/rfc8259#section-7
+func (p *parser) string() (string, bool) {
+	f := p.push(typ.String)
+	if !p.char('"') {
+		p.pop(f, false)
+		return "", false
+	}
+	start := p.pos
+	var escaped bool
+	for {
+		if p.peek(isUnescaped) {
+			p.pos++
+			continue
+		}
+		end := p.pos
+		if !p.escape() {
+			p.pos = end
+			break
+		}
+		escaped = true
+	}
+	end := p.pos
+	if !p.char('"') {
+		p.pop(f, false)
+		return "", false
+	}
+	p.pop(f, true)
+	if max := p.limits.MaxStringLength; max > 0 && end-start > max {
+		p.pos = start + max
+		p.exceed(StringLengthLimit, max)
+		return "", false
+	}
+
+	str := p.text(start, end)
+	if escaped || !utf8.ValidString(str) {
+		str = unquote(str)
+	}
+	return str, true
+}
+
+// term counts an attribute expression. It fails the parser if the maximum
+// number of terms is exceeded.
+func (p *parser) term() {
+	p.terms++
+	if max := p.limits.MaxTerms; max > 0 && p.terms > max {
+		p.exceed(TermsLimit, max)
 	}
-	return f, err
 }
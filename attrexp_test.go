@@ -2,9 +2,9 @@ package filter
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
-	"github.com/di-wu/parser/ast"
-	"github.com/scim2/filter-parser/v2/internal/grammar"
+	"github.com/scim2/filter-parser/v2/internal/types"
 	"strings"
 	"testing"
 )
@@ -21,6 +21,112 @@ func ExampleParseAttrExp_sw() {
 	// userName sw "J" <nil>
 }
 
+func TestParseAttrExp_string(t *testing.T) {
+	for _, test := range []struct {
+		input    string
+		expected string
+	}{
+		{
+			input:    `name.familyName eq "O\"Malley"`,
+			expected: `O"Malley`,
+		},
+		{
+			input:    `name.familyName eq "\u00E9"`,
+			expected: "é",
+		},
+		{
+			input:    `name.familyName eq "\u00e9"`,
+			expected: "é",
+		},
+		{
+			input:    `name.familyName eq "\uD83d\ude00"`,
+			expected: "😀",
+		},
+		{
+			input:    `name.familyName eq "é"`,
+			expected: "é",
+		},
+		{
+			input:    `name.familyName eq "\uD83D\uDE00"`,
+			expected: "😀",
+		},
+		{
+			input:    `name.familyName eq "\\\/\b\f\n\r\t"`,
+			expected: "\\/\b\f\n\r\t",
+		},
+		{
+			input:    `meta.version eq "W/\"990-6468886345120203448\""`,
+			expected: `W/"990-6468886345120203448"`,
+		},
+	} {
+		t.Run(test.input, func(t *testing.T) {
+			exp, err := ParseAttrExp([]byte(test.input))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if exp.CompareValue != test.expected {
+				t.Errorf("expected %q, got %q", test.expected, exp.CompareValue)
+			}
+		})
+	}
+}
+
+func TestParseAttrExp_number(t *testing.T) {
+	for _, test := range []struct {
+		value    string
+		useRat   bool
+		expected string
+		err      bool
+	}{
+		{value: "-510", expected: "int -510"},
+		{value: "9007199254740993", expected: "int 9007199254740993"},
+		{value: "-9223372036854775808", expected: "int -9223372036854775808"},
+		{value: "9223372036854775808", expected: "*big.Int 9223372036854775808"},
+		{value: "-92233720368547758080", expected: "*big.Int -92233720368547758080"},
+		{value: "0.1", expected: "float64 0.1"},
+		{value: "1e-400", err: true},
+		{value: "0.0e-400", expected: "float64 0"},
+		{value: "1e400", err: true},
+		{value: "-1.5e308", expected: "float64 -1.5e+308"},
+		{value: "-1.8e308", err: true},
+		{value: "0.1", useRat: true, expected: "*big.Rat 1/10"},
+		{value: "-1.25e2", useRat: true, expected: "*big.Rat -125/1"},
+		{value: "0e99999999", useRat: true, expected: "*big.Rat 0/1"},
+		{value: "1e-400", useRat: true, err: true},
+		{value: "1", useRat: true, expected: "int 1"},
+	} {
+		t.Run(test.value, func(t *testing.T) {
+			p := NewParser()
+			if test.useRat {
+				p = NewParser(UseRat())
+			}
+			attrExp, err := p.ParseAttrExp([]byte("a eq " + test.value))
+			if test.err {
+				var parseErr *ParseError
+				if !errors.As(err, &parseErr) || parseErr.Offset != 5 || parseErr.Rule != "Number" {
+					t.Errorf("expected a parse error at the number, got %v", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if s := fmt.Sprintf("%T %v", attrExp.CompareValue, attrExp.CompareValue); s != test.expected {
+				t.Errorf("expected %s, got %s", test.expected, s)
+			}
+
+			// The formatted expression results in an equal value.
+			again, err := p.ParseAttrExp([]byte(Format(&attrExp)))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if s := fmt.Sprintf("%T %v", again.CompareValue, again.CompareValue); s != test.expected {
+				t.Errorf("expected %s after formatting, got %s", test.expected, s)
+			}
+		})
+	}
+}
+
 func TestParseNumber(t *testing.T) {
 	for _, test := range []struct {
 		nStr     string
@@ -40,13 +146,11 @@ func TestParseNumber(t *testing.T) {
 		},
 	} {
 		t.Run(test.nStr, func(t *testing.T) {
-			p, _ := ast.New([]byte(test.nStr))
-			n, err := grammar.Number(p)
-			if err != nil {
-				t.Fatal(err)
+			parseNumber := func(c config) (any, error) {
+				return parse([]byte(test.nStr), c, typ.Number, (*parser).number)
 			}
 			{ // Empty config.
-				i, err := config{}.parseNumber(n)
+				i, err := parseNumber(config{})
 				if err != nil {
 					t.Fatal(err)
 				}
@@ -62,9 +166,9 @@ func TestParseNumber(t *testing.T) {
 					t.Error(err)
 				}
 
-				i, err := config{
+				i, err := parseNumber(config{
 					useNumber: true,
-				}.parseNumber(n)
+				})
 				if err != nil {
 					t.Fatal(err)
 				}
@@ -85,9 +189,9 @@ func TestParseNumber(t *testing.T) {
 					t.Error(err)
 				}
 
-				i, err := config{
+				i, err := parseNumber(config{
 					useNumber: true,
-				}.parseNumber(n)
+				})
 				if err != nil {
 					t.Fatal(err)
 				}
@@ -0,0 +1,41 @@
+package filter
+
+import (
+	"github.com/scim2/filter-parser/v2/internal/types"
+)
+
+// ParseAttributeList parses the given raw data as a comma separated list of
+// attribute paths, as used by the "attributes" and "excludedAttributes"
+// parameters. Spaces around the commas are allowed.
+//
+// More info: https://tools.ietf.org/html/rfc7644#section-3.4.2.5
+func ParseAttributeList(raw []byte) ([]AttributePath, error) {
+	return parse(raw, config{}, typ.AttrList, (*parser).attrList)
+}
+
+func (p *parser) attrList() ([]AttributePath, bool) {
+	f := p.push(typ.AttrList)
+	attrPath, ok := p.attrPath()
+	if !ok {
+		p.pop(f, false)
+		return nil, false
+	}
+	attrPaths := []AttributePath{attrPath}
+	for {
+		start := p.pos
+		p.spaces(0)
+		if !p.char(',') {
+			p.pos = start
+			break
+		}
+		p.spaces(0)
+		attrPath, ok := p.attrPath()
+		if !ok {
+			p.pos = start
+			break
+		}
+		attrPaths = append(attrPaths, attrPath)
+	}
+	p.pop(f, true)
+	return attrPaths, true
+}
@@ -0,0 +1,44 @@
+package filter
+
+import (
+	"fmt"
+	"testing"
+)
+
+func ExampleParseAttributeList() {
+	fmt.Println(ParseAttributeList([]byte("userName, name.givenName,urn:ietf:params:scim:schemas:extension:enterprise:2.0:User:employeeNumber")))
+	// Output:
+	// [userName name.givenName urn:ietf:params:scim:schemas:extension:enterprise:2.0:User:employeeNumber] <nil>
+}
+
+func TestParseAttributeList(t *testing.T) {
+	for _, test := range []struct {
+		raw      string
+		expected int
+	}{
+		{raw: "userName", expected: 1},
+		{raw: "userName,emails", expected: 2},
+		{raw: "userName , emails ,name.familyName", expected: 3},
+	} {
+		attrPaths, err := ParseAttributeList([]byte(test.raw))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(attrPaths) != test.expected {
+			t.Errorf("expected %d attribute paths, got %v", test.expected, attrPaths)
+		}
+	}
+
+	for _, invalid := range []string{
+		"",
+		"userName,",
+		",userName",
+		"userName,,emails",
+		"userName emails",
+		"emails[type eq \"work\"]",
+	} {
+		if _, err := ParseAttributeList([]byte(invalid)); err == nil {
+			t.Errorf("expected an error for %q", invalid)
+		}
+	}
+}
@@ -1,61 +1,106 @@
 package filter
 
 import (
-	"github.com/di-wu/parser"
-	"github.com/di-wu/parser/ast"
-	"github.com/scim2/filter-parser/v2/internal/grammar"
 	"github.com/scim2/filter-parser/v2/internal/types"
-	"strings"
 )
 
 // ParseAttrPath parses the given raw data as an AttributePath.
 func ParseAttrPath(raw []byte) (AttributePath, error) {
-	p, err := ast.New(raw)
-	if err != nil {
-		return AttributePath{}, err
+	return parse(raw, config{}, typ.AttrPath, (*parser).attrPath)
+}
+
+func isNameChar(c byte) bool {
+	return c == '-' || c == '_' || isDigit(c) || isAlpha(c)
+}
+
+func isURIChar(c byte) bool {
+	return c == '-' || c == '.' || isDigit(c) || isAlpha(c)
+}
+
+func (p *parser) attrName() (string, bool) {
+	f := p.push(typ.AttrName)
+	start := p.pos
+	p.char('$')
+	if !p.peek(isAlpha) {
+		p.pop(f, false)
+		return "", false
 	}
-	node, err := grammar.AttrPath(p)
-	if err != nil {
-		return AttributePath{}, err
+	for p.pos++; p.peek(isNameChar); p.pos++ {
 	}
-	if _, err := p.Expect(parser.EOD); err != nil {
-		return AttributePath{}, err
+	if p.track {
+		p.fail(p.pos, describe('-'))
+		p.fail(p.pos, describe('_'))
 	}
-	return parseAttrPath(node)
+	p.pop(f, true)
+	return p.text(start, p.pos), true
 }
 
-func parseAttrPath(node *ast.Node) (AttributePath, error) {
-	if node.Type != typ.AttrPath {
-		return AttributePath{}, invalidTypeError(typ.AttrPath, node.Type)
+func (p *parser) attrPath() (AttributePath, bool) {
+	f := p.push(typ.AttrPath)
+	var attrPath AttributePath
+	start := p.pos
+	// The URI and the sub-attribute are only copied to the heap once they are
+	// matched.
+	if uri, ok := p.uri(); ok {
+		attrPath.URIPrefix = new(uri)
+	} else {
+		p.pos = start
 	}
 
-	// Indicates whether we encountered an attribute name.
-	// These are the minimum requirements of an attribute path.
-	var valid bool
+	name, ok := p.attrName()
+	if !ok {
+		p.pop(f, false)
+		return AttributePath{}, false
+	}
+	attrPath.AttributeName = name
 
-	var attrPath AttributePath
-	for _, node := range node.Children() {
-		switch t := node.Type; t {
-		case typ.URI:
-			uri := node.Value
-			uri = strings.TrimSuffix(uri, ":")
-			attrPath.URIPrefix = &uri
-		case typ.AttrName:
-			name := node.Value
-			if attrPath.AttributeName == "" {
-				attrPath.AttributeName = name
-
-				valid = true
-			} else {
-				attrPath.SubAttribute = &name
-			}
-		default:
-			return AttributePath{}, invalidChildTypeError(typ.AttrPath, t)
-		}
+	end := p.pos
+	if subAttr, ok := p.subAttr(); ok {
+		attrPath.SubAttribute = new(subAttr)
+	} else {
+		p.pos = end
 	}
+	p.pop(f, true)
+	if p.positions {
+		attrPath.Span = Span{Start: start, End: p.pos}
+	}
+	if attrPath.URIPrefix == nil && p.schema != "" && !p.valueFilter {
+		uri := p.schema
+		attrPath.URIPrefix = &uri
+	}
+	return attrPath, true
+}
+
+func (p *parser) subAttr() (string, bool) {
+	if !p.char('.') {
+		return "", false
+	}
+	return p.attrName()
+}
 
-	if !valid {
-		return AttributePath{}, missingValueError(typ.AttrPath, typ.AttrName)
+// uri parses one or more URN segments that are followed by a colon. The last
+// colon is not part of the returned URI.
+func (p *parser) uri() (string, bool) {
+	f := p.push(typ.URI)
+	start := p.pos
+	for {
+		segment := p.pos
+		for p.peek(isURIChar) {
+			p.pos++
+		}
+		if p.track {
+			p.fail(p.pos, describe('-'))
+			p.fail(p.pos, describe('.'))
+		}
+		if p.pos == segment || !p.char(':') {
+			p.pos = segment
+			break
+		}
+	}
+	if p.pos == start {
+		p.pop(f, false)
+		return "", false
 	}
-	return attrPath, nil
+	p.pop(f, true)
+	return p.text(start, p.pos-1), true
 }
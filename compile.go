@@ -0,0 +1,401 @@
+package filter
+
+import (
+	"cmp"
+	"fmt"
+	"math"
+	"math/big"
+	"reflect"
+	"strings"
+	"time"
+)
+
+// Compile compiles the given expression to a predicate that reports whether a
+// resource matches the expression, with the same semantics as Evaluate.
+// Operators are resolved and compare values are lowercased and parsed (as
+// numbers or RFC 3339 timestamps) once, which makes the predicate a lot cheaper
+// to call than Evaluate when filtering many resources.
+//
+// The predicate does not modify the expression or any shared state, so it can
+// be called concurrently from multiple goroutines. Modifying the expression
+// does not affect the predicate.
+//
+// The errors that Evaluate returns for invalid expressions are returned by
+// Compile. Ordering operators on boolean attributes, on which Evaluate returns
+// an error, do not match.
+func Compile(expr Expression, opts ...CompileOption) (func(map[string]any) bool, error) {
+	c := compiler{
+		caseExact: make(map[string]bool),
+	}
+	for _, opt := range opts {
+		opt(&c)
+	}
+	return c.compile(expr, nil)
+}
+
+// WithCaseExact marks the given attributes (e.g. "id" or "emails.value") as
+// case exact, so that their string values are compared case-sensitively. The
+// attribute paths are matched case-insensitively. An attribute path with a URI
+// prefix falls back to the attribute without the prefix.
+func WithCaseExact(paths ...string) CompileOption {
+	return func(c *compiler) {
+		for _, path := range paths {
+			c.caseExact[strings.ToLower(path)] = true
+		}
+	}
+}
+
+// compileBool returns a predicate that compares attribute values with the given
+// boolean compare value.
+func compileBool(op CompareOperator, cv bool) (func(any) bool, error) {
+	if isOrdering(op) {
+		return nil, fmt.Errorf("operator %q is not supported on boolean values", op)
+	}
+	return func(value any) bool {
+		v, ok := value.(bool)
+		switch {
+		case !ok:
+			return false
+		case op == EQ:
+			return v == cv
+		case op == NE:
+			return v != cv
+		default:
+			return false
+		}
+	}, nil
+}
+
+// compileCompare returns a predicate that compares a single attribute value
+// with the given compare value, like compare does.
+func compileCompare(op CompareOperator, compareValue any, caseExact bool) (func(any) bool, error) {
+	switch op {
+	case EQ, NE, CO, SW, EW, GT, GE, LT, LE:
+	default:
+		return nil, fmt.Errorf("invalid compare operator: %q", op)
+	}
+
+	switch cv := compareValue.(type) {
+	case bool:
+		return compileBool(op, cv)
+	case string:
+		return compileString(op, cv, caseExact), nil
+	default:
+		n, ok := newNumber(compareValue)
+		if !ok {
+			return nil, fmt.Errorf("invalid compare value: %v (%T)", compareValue, compareValue)
+		}
+		return func(value any) bool {
+			c, ok := n.compare(value)
+			if !ok {
+				return false
+			}
+			switch op {
+			case EQ:
+				return c == 0
+			case NE:
+				return c != 0
+			case GT, GE, LT, LE:
+				return order(op, c)
+			default:
+				return false
+			}
+		}, nil
+	}
+}
+
+// compileString returns a predicate that compares attribute values with the
+// given string compare value.
+func compileString(op CompareOperator, cv string, caseExact bool) func(any) bool {
+	fold := strings.ToLower
+	if caseExact {
+		fold = func(s string) string { return s }
+	}
+	folded := fold(cv)
+
+	var match func(string) bool
+	switch op {
+	case EQ, NE:
+		eq := func(v string) bool { return strings.EqualFold(v, cv) }
+		if caseExact {
+			eq = func(v string) bool { return v == cv }
+		}
+		match = eq
+		if op == NE {
+			match = func(v string) bool { return !eq(v) }
+		}
+	case CO:
+		match = func(v string) bool { return strings.Contains(fold(v), folded) }
+	case SW:
+		match = func(v string) bool { return strings.HasPrefix(fold(v), folded) }
+	case EW:
+		match = func(v string) bool { return strings.HasSuffix(fold(v), folded) }
+	default:
+		ts, err := time.Parse(time.RFC3339Nano, cv)
+		isTime := err == nil
+		match = func(v string) bool {
+			if isTime {
+				if t, err := time.Parse(time.RFC3339Nano, v); err == nil {
+					return order(op, t.Compare(ts))
+				}
+			}
+			return order(op, strings.Compare(fold(v), folded))
+		}
+	}
+	return func(value any) bool {
+		v, ok := value.(string)
+		return ok && match(v)
+	}
+}
+
+// each reports whether any of the values of a (multi-valued) attribute matches.
+// The values are the same as the ones returned by flatten.
+func each(value any, match func(any) bool) bool {
+	switch v := value.(type) {
+	case nil:
+		return false
+	case []any:
+		for _, v := range v {
+			if match(v) {
+				return true
+			}
+		}
+		return false
+	case []map[string]any:
+		for _, v := range v {
+			if match(v) {
+				return true
+			}
+		}
+		return false
+	case string, []byte:
+		return match(v)
+	}
+	if rv := reflect.ValueOf(value); rv.Kind() == reflect.Slice {
+		for i := range rv.Len() {
+			if match(rv.Index(i).Interface()) {
+				return true
+			}
+		}
+		return false
+	}
+	return match(value)
+}
+
+// newCompiledPath resolves the names of the given attribute path.
+func newCompiledPath(path AttributePath) compiledPath {
+	var p compiledPath
+	if path.URIPrefix != nil {
+		p.uri = *path.URIPrefix
+	}
+	p.name = path.AttributeName
+	if path.SubAttribute != nil {
+		p.sub = *path.SubAttribute
+	}
+	return p
+}
+
+// newNumber parses the given numeric compare value. Returns false if the value
+// is not a number.
+func newNumber(value any) (number, bool) {
+	f, ok := toFloat(value)
+	if !ok {
+		return number{}, false
+	}
+	n := number{
+		float: f,
+		big:   isBig(value),
+	}
+	n.int, n.isInt = toInt64(value)
+	if r, ok := toRat(value); ok {
+		// The predicate must not be affected if the *big.Rat of the
+		// expression is modified.
+		n.rat, n.isRat = new(big.Rat).Set(r), true
+	}
+	return n, true
+}
+
+// CompileOption configures how an expression is compiled.
+type CompileOption func(*compiler)
+
+// compiledPath is an attribute path that is resolved like lookup does, without
+// collecting the values it refers to.
+type compiledPath struct {
+	uri, name, sub string
+}
+
+// match reports whether any of the values the path refers to within the given
+// resource matches.
+func (p compiledPath) match(resource map[string]any, match func(any) bool) bool {
+	if p.uri != "" {
+		if extension, ok := get(resource, p.uri).(map[string]any); ok {
+			resource = extension
+		}
+	}
+	value := get(resource, p.name)
+	if p.sub == "" {
+		return each(value, match)
+	}
+	return each(value, func(value any) bool {
+		complexValue, ok := value.(map[string]any)
+		return ok && each(get(complexValue, p.sub), match)
+	})
+}
+
+type compiler struct {
+	// caseExact contains the (lowercased) attribute paths of which the string
+	// values are compared case-sensitively.
+	caseExact map[string]bool
+}
+
+// compile compiles the given expression. If the parent is not nil, the
+// expression is the value filter of that attribute path.
+func (c *compiler) compile(expr Expression, parent *AttributePath) (func(map[string]any) bool, error) {
+	switch e := expr.(type) {
+	case *AttributeExpression:
+		return c.compileAttrExp(e, parent)
+	case *LogicalExpression:
+		left, err := c.compile(e.Left, parent)
+		if err != nil {
+			return nil, err
+		}
+		right, err := c.compile(e.Right, parent)
+		if err != nil {
+			return nil, err
+		}
+		switch LogicalOperator(strings.ToLower(string(e.Operator))) {
+		case AND:
+			return func(resource map[string]any) bool {
+				return left(resource) && right(resource)
+			}, nil
+		case OR:
+			return func(resource map[string]any) bool {
+				return left(resource) || right(resource)
+			}, nil
+		default:
+			return nil, fmt.Errorf("invalid logical operator: %q", e.Operator)
+		}
+	case *NotExpression:
+		match, err := c.compile(e.Expression, parent)
+		if err != nil {
+			return nil, err
+		}
+		return func(resource map[string]any) bool {
+			return !match(resource)
+		}, nil
+	case *ValuePath:
+		valueFilter, err := c.compile(e.ValueFilter, &e.AttributePath)
+		if err != nil {
+			return nil, err
+		}
+		element := func(value any) bool {
+			element, ok := value.(map[string]any)
+			return ok && valueFilter(element)
+		}
+		path := newCompiledPath(e.AttributePath)
+		return func(resource map[string]any) bool {
+			return path.match(resource, element)
+		}, nil
+	default:
+		return nil, fmt.Errorf("invalid expression type: %T", expr)
+	}
+}
+
+func (c *compiler) compileAttrExp(e *AttributeExpression, parent *AttributePath) (func(map[string]any) bool, error) {
+	op := CompareOperator(strings.ToLower(string(e.Operator)))
+	path := newCompiledPath(e.AttributePath)
+	switch {
+	case op == PR:
+		return func(resource map[string]any) bool {
+			return path.match(resource, present)
+		}, nil
+	case e.CompareValue == nil:
+		// Comparing to null is the same as checking whether the attribute is
+		// (not) present.
+		switch op {
+		case EQ:
+			return func(resource map[string]any) bool {
+				return !path.match(resource, present)
+			}, nil
+		case NE:
+			return func(resource map[string]any) bool {
+				return path.match(resource, present)
+			}, nil
+		default:
+			return nil, fmt.Errorf("operator %q is not supported on null", op)
+		}
+	}
+
+	attrPath := e.AttributePath
+	if parent != nil && attrPath.URIPrefix == nil && attrPath.SubAttribute == nil {
+		// e.g. emails[value eq "..."] => emails.value
+		attrPath = *parent
+		attrPath.SubAttribute = &e.AttributePath.AttributeName
+	}
+	if op == NE {
+		// The 'ne' operator is the negation of 'eq', so that it also matches
+		// unassigned attributes.
+		eq, err := compileCompare(EQ, e.CompareValue, c.isCaseExact(attrPath))
+		if err != nil {
+			return nil, err
+		}
+		return func(resource map[string]any) bool {
+			return !path.match(resource, eq)
+		}, nil
+	}
+	match, err := compileCompare(op, e.CompareValue, c.isCaseExact(attrPath))
+	if err != nil {
+		return nil, err
+	}
+	return func(resource map[string]any) bool {
+		return path.match(resource, match)
+	}, nil
+}
+
+// isCaseExact checks whether the given attribute path is marked as case exact.
+func (c *compiler) isCaseExact(path AttributePath) bool {
+	if c.caseExact[strings.ToLower(path.String())] {
+		return true
+	}
+	if path.URIPrefix == nil {
+		return false
+	}
+	path.URIPrefix = nil
+	return c.caseExact[strings.ToLower(path.String())]
+}
+
+// number is a numeric compare value that is converted to all the
+// representations compareNumbers needs.
+type number struct {
+	int   int64
+	isInt bool
+	rat   *big.Rat
+	isRat bool
+	float float64
+	big   bool
+}
+
+// compare compares the given attribute value with the number, like
+// compareNumbers does. Returns false if the value is not a number or NaN.
+func (n number) compare(value any) (int, bool) {
+	if n.isInt {
+		if x, ok := toInt64(value); ok {
+			return cmp.Compare(x, n.int), true
+		}
+	}
+	if n.big || isBig(value) {
+		if !n.isRat {
+			return 0, false
+		}
+		x, ok := toRat(value)
+		if !ok {
+			return 0, false
+		}
+		return x.Cmp(n.rat), true
+	}
+	x, ok := toFloat(value)
+	if !ok || math.IsNaN(x) || math.IsNaN(n.float) {
+		return 0, false
+	}
+	return cmp.Compare(x, n.float), true
+}
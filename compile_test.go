@@ -0,0 +1,251 @@
+package filter
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func BenchmarkCompile(b *testing.B) {
+	var resource map[string]any
+	if err := json.Unmarshal([]byte(testUser), &resource); err != nil {
+		b.Fatal(err)
+	}
+	expression, err := ParseFilter([]byte("userName eq \"BJensen\" and emails[type eq \"work\" and value ew \"@example.com\"] and meta.lastModified gt \"2011-05-13T00:00:00Z\""))
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	b.Run("Evaluate", func(b *testing.B) {
+		for b.Loop() {
+			if ok, _ := Evaluate(expression, resource); !ok {
+				b.Fatal("expected a match")
+			}
+		}
+	})
+	b.Run("Compile", func(b *testing.B) {
+		match, err := Compile(expression)
+		if err != nil {
+			b.Fatal(err)
+		}
+		for b.Loop() {
+			if !match(resource) {
+				b.Fatal("expected a match")
+			}
+		}
+	})
+}
+
+func ExampleCompile() {
+	expression, _ := ParseFilter([]byte("userName sw \"b\" and emails[type eq \"work\"]"))
+	match, _ := Compile(expression)
+	for _, resource := range []map[string]any{
+		{"userName": "bjensen", "emails": []any{map[string]any{"type": "work"}}},
+		{"userName": "Babs", "emails": []any{map[string]any{"type": "home"}}},
+		{"userName": "jsmith", "emails": []any{map[string]any{"type": "work"}}},
+	} {
+		fmt.Println(resource["userName"], match(resource))
+	}
+	// Output:
+	// bjensen true
+	// Babs false
+	// jsmith false
+}
+
+func ExampleWithCaseExact() {
+	expression, _ := ParseFilter([]byte("id eq \"2819c223\" and emails[value sw \"bjensen\"]"))
+	match, _ := Compile(expression, WithCaseExact("id", "emails.value"))
+	fmt.Println(match(map[string]any{"id": "2819c223", "emails": []any{map[string]any{"value": "bjensen@example.com"}}}))
+	fmt.Println(match(map[string]any{"id": "2819C223", "emails": []any{map[string]any{"value": "bjensen@example.com"}}}))
+	fmt.Println(match(map[string]any{"id": "2819c223", "emails": []any{map[string]any{"value": "BJensen@example.com"}}}))
+	// Output:
+	// true
+	// false
+	// false
+}
+
+func TestCompile(t *testing.T) {
+	var resource map[string]any
+	if err := json.Unmarshal([]byte(testUser), &resource); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, test := range evaluateTests {
+		t.Run(test.filter, func(t *testing.T) {
+			expression, err := ParseFilterNumber([]byte(test.filter))
+			if err != nil {
+				t.Fatal(err)
+			}
+			match, err := Compile(expression)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if ok := match(resource); ok != test.match {
+				t.Errorf("expected %v, got %v", test.match, ok)
+			}
+		})
+	}
+}
+
+func TestCompile_caseExact(t *testing.T) {
+	var resource map[string]any
+	if err := json.Unmarshal([]byte(testUser), &resource); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, test := range []struct {
+		filter string
+		match  bool
+	}{
+		{"userName eq \"bjensen\"", true},
+		{"userName eq \"BJensen\"", false},
+		{"userName ne \"BJensen\"", true},
+		{"USERNAME co \"Jen\"", false},
+		{"userName gt \"B\"", true},
+		{"name.familyName sw \"o'm\"", true},
+		{"emails.value ew \"@EXAMPLE.COM\"", false},
+		{"emails[value ew \"@EXAMPLE.COM\"]", false},
+		{"emails[value ew \"@example.com\"]", true},
+		{"emails[type eq \"WORK\"]", true},
+		{"urn:ietf:params:scim:schemas:extension:enterprise:2.0:User:manager.value sw \"26118915\"", true},
+		{"urn:ietf:params:scim:schemas:core:2.0:User:userName eq \"BJENSEN\"", false},
+	} {
+		t.Run(test.filter, func(t *testing.T) {
+			expression, err := ParseFilter([]byte(test.filter))
+			if err != nil {
+				t.Fatal(err)
+			}
+			match, err := Compile(expression, WithCaseExact("userName", "Emails.Value"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if ok := match(resource); ok != test.match {
+				t.Errorf("expected %v, got %v", test.match, ok)
+			}
+		})
+	}
+}
+
+func TestCompile_concurrent(t *testing.T) {
+	expression, err := ParseFilter([]byte("userName eq \"bjensen\" or emails[value co \"jensen\"]"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	match, err := Compile(expression)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var wg sync.WaitGroup
+	for i := range 8 {
+		wg.Go(func() {
+			for j := range 100 {
+				name := fmt.Sprintf("user%d-%d", i, j)
+				resource := map[string]any{
+					"userName": name,
+					"emails":   []any{map[string]any{"value": name + "@example.com"}},
+				}
+				if match(resource) {
+					t.Errorf("unexpected match: %s", name)
+				}
+				resource["userName"] = "BJENSEN"
+				if !match(resource) {
+					t.Errorf("expected a match: %s", name)
+				}
+			}
+		})
+	}
+	wg.Wait()
+}
+
+func TestCompile_invalid(t *testing.T) {
+	for _, expression := range []Expression{
+		&AttributeExpression{
+			AttributePath: AttributePath{AttributeName: "active"},
+			Operator:      GT,
+			CompareValue:  false,
+		},
+		&AttributeExpression{
+			AttributePath: AttributePath{AttributeName: "active"},
+			Operator:      "xx",
+			CompareValue:  "x",
+		},
+		&AttributeExpression{
+			AttributePath: AttributePath{AttributeName: "active"},
+			Operator:      GT,
+			CompareValue:  nil,
+		},
+		&AttributeExpression{
+			AttributePath: AttributePath{AttributeName: "active"},
+			Operator:      EQ,
+			CompareValue:  []string{"x"},
+		},
+		&LogicalExpression{
+			Left:     &AttributeExpression{AttributePath: AttributePath{AttributeName: "active"}, Operator: PR},
+			Right:    &AttributeExpression{AttributePath: AttributePath{AttributeName: "active"}, Operator: PR},
+			Operator: "xor",
+		},
+		&ValuePath{
+			AttributePath: AttributePath{AttributeName: "emails"},
+			ValueFilter: &NotExpression{
+				Expression: &AttributeExpression{AttributePath: AttributePath{AttributeName: "type"}, Operator: "xx"},
+			},
+		},
+	} {
+		t.Run(fmt.Sprint(expression), func(t *testing.T) {
+			if _, err := Compile(expression); err == nil {
+				t.Error("expected an error")
+			}
+		})
+	}
+}
+
+func TestCompile_modify(t *testing.T) {
+	expression, err := NewParser(UseRat()).ParseFilter([]byte("score eq 0.5"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	match, err := Compile(expression)
+	if err != nil {
+		t.Fatal(err)
+	}
+	attrExp := expression.(*AttributeExpression)
+	attrExp.AttributePath.AttributeName = "other"
+	attrExp.CompareValue.(*big.Rat).SetInt64(2)
+	if !match(map[string]any{"score": 0.5}) {
+		t.Error("expected the predicate to be unaffected by modifying the expression")
+	}
+	if match(map[string]any{"score": 2}) || match(map[string]any{"other": 2}) {
+		t.Error("expected no match")
+	}
+}
+
+func TestCompile_numbers(t *testing.T) {
+	decoder := json.NewDecoder(strings.NewReader(numbersResource))
+	decoder.UseNumber()
+	var resource map[string]any
+	if err := decoder.Decode(&resource); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, test := range evaluateNumberTests {
+		t.Run(test.filter, func(t *testing.T) {
+			for _, p := range []*Parser{NewParser(), NewParser(UseRat())} {
+				expression, err := p.ParseFilter([]byte(test.filter))
+				if err != nil {
+					t.Fatal(err)
+				}
+				match, err := Compile(expression)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if ok := match(resource); ok != test.match {
+					t.Errorf("expected %v, got %v", test.match, ok)
+				}
+			}
+		})
+	}
+}
@@ -1,7 +1,212 @@
 package filter
 
+import (
+	"fmt"
+	"github.com/scim2/filter-parser/v2/internal/types"
+	"slices"
+	"strings"
+)
+
+const (
+	// Lenient is the default mode, it accepts any number of spaces where the
+	// RFC requires one and allows spaces around brackets, parentheses and the
+	// logical operators of value filters.
+	Lenient Mode = iota
+	// Strict only accepts filters that conform to the ABNF of the RFC: exactly
+	// one space where the RFC requires one and no other spaces. A single space
+	// between 'not' and '(' is allowed, as used in the examples of the RFC.
+	//
+	// More info: https://tools.ietf.org/html/rfc7644#section-3.4.2.2
+	Strict
+)
+
+const (
+	// LengthLimit limits the length of the raw data.
+	LengthLimit Limit = "length"
+	// DepthLimit limits the nesting depth of parentheses and value paths.
+	DepthLimit Limit = "depth"
+	// TermsLimit limits the number of attribute expressions.
+	TermsLimit Limit = "terms"
+	// StringLengthLimit limits the length of string literals.
+	StringLengthLimit Limit = "string length"
+)
+
+// DefaultLimits are limits that are generous enough for any sensible filter,
+// but prevent hostile filters from exhausting the stack or CPU. They are not
+// used unless given to WithLimits, the package-level parse functions and
+// parsers that are created without the WithLimits option are not limited.
+var DefaultLimits = Limits{
+	MaxLength:       64 * 1024,
+	MaxDepth:        64,
+	MaxTerms:        1024,
+	MaxStringLength: 16 * 1024,
+}
+
+// NewParser creates a parser with the given options.
+func NewParser(opts ...Option) *Parser {
+	var p Parser
+	for _, opt := range opts {
+		opt(&p.config)
+	}
+	return &p
+}
+
+// TrackPositions makes the parser set the span of every node and attribute
+// path, and the raw compare value of attribute expressions.
+func TrackPositions() Option {
+	return func(c *config) {
+		c.positions = true
+	}
+}
+
+// UseNumber makes the parser return json.Number instead of int, int64,
+// *big.Int or float64 values.
+func UseNumber() Option {
+	return func(c *config) {
+		c.useNumber = true
+	}
+}
+
+// UseRat makes the parser return *big.Rat instead of float64 values for numbers
+// with a fractional or exponent part, so that decimals are represented
+// exactly. It is ignored if UseNumber is used.
+func UseRat() Option {
+	return func(c *config) {
+		c.useRat = true
+	}
+}
+
+// WithLimits sets the limits of the parser. A zero limit means that there is no
+// limit, so Limits{} disables all limits.
+func WithLimits(limits Limits) Option {
+	return func(c *config) {
+		c.limits = limits
+	}
+}
+
+// WithMode sets the mode of the parser, Lenient by default.
+func WithMode(mode Mode) Option {
+	return func(c *config) {
+		c.mode = mode
+	}
+}
+
+// WithOperators registers additional compare operators, e.g. "re" for regular
+// expressions. Operators are case-insensitive, must consist of letters and are
+// followed by a compare value, the same as 'eq'. The expressions that use them
+// can not be evaluated by Evaluate. It panics if an operator is invalid.
+func WithOperators(operators ...CompareOperator) Option {
+	for _, op := range operators {
+		if op == "" || strings.IndexFunc(string(op), func(r rune) bool {
+			return r > 0x7F || !isAlpha(byte(r))
+		}) != -1 {
+			panic(fmt.Sprintf("filter: invalid compare operator: %q", op))
+		}
+	}
+	return func(c *config) {
+		if c.operators == nil {
+			c.operators = compareOperators[:]
+		}
+		c.operators = slices.Clone(c.operators)
+		for _, op := range operators {
+			op = CompareOperator(strings.ToLower(string(op)))
+			if op != PR && !slices.Contains(c.operators, op) {
+				c.operators = append(c.operators, op)
+			}
+		}
+		// Longer operators are tried first, so that operators that start with
+		// another operator can be parsed.
+		slices.SortStableFunc(c.operators, func(a, b CompareOperator) int {
+			return len(b) - len(a)
+		})
+	}
+}
+
+// WithSchema sets the URI of the schema of attribute paths without a URI
+// prefix, e.g. "urn:ietf:params:scim:schemas:core:2.0:User". It is used as the
+// URI prefix of those attribute paths, except for the sub-attributes within
+// value filters.
+func WithSchema(uri string) Option {
+	return func(c *config) {
+		c.schema = uri
+	}
+}
+
+// Limit is the name of a limit that was exceeded.
+type Limit string
+
+// Limits bound the resources that are used to parse raw data. A value of zero
+// means that there is no limit.
+type Limits struct {
+	// MaxLength is the maximum length of the raw data in bytes.
+	MaxLength int
+	// MaxDepth is the maximum nesting depth of parentheses (including those of
+	// 'not') and value paths.
+	MaxDepth int
+	// MaxTerms is the maximum number of attribute expressions.
+	MaxTerms int
+	// MaxStringLength is the maximum length of a string literal in bytes, as
+	// it appears in the raw data (i.e. escaped and without the quotes).
+	MaxStringLength int
+}
+
+// Mode determines which filters the parser accepts.
+type Mode int
+
+// Option configures a parser.
+type Option func(*config)
+
+// Parser parses raw data with custom options. It is safe for concurrent use.
+type Parser struct {
+	config config
+}
+
+// ParseAttrExp parses the given raw data as an AttributeExpression.
+func (p *Parser) ParseAttrExp(raw []byte) (AttributeExpression, error) {
+	return parseAttrExp(raw, p.config)
+}
+
+// ParseAttrPath parses the given raw data as an AttributePath.
+func (p *Parser) ParseAttrPath(raw []byte) (AttributePath, error) {
+	return parse(raw, p.config, typ.AttrPath, (*parser).attrPath)
+}
+
+// ParseAttributeList parses the given raw data as a comma-separated list of
+// attribute paths.
+func (p *Parser) ParseAttributeList(raw []byte) ([]AttributePath, error) {
+	return parse(raw, p.config, typ.AttrList, (*parser).attrList)
+}
+
+// ParseFilter parses the given raw data as an Expression.
+func (p *Parser) ParseFilter(raw []byte) (Expression, error) {
+	return parseFilter(raw, p.config)
+}
+
+// ParsePath parses the given raw data as a Path.
+func (p *Parser) ParsePath(raw []byte) (Path, error) {
+	return parsePath(raw, p.config)
+}
+
+// ParseValuePath parses the given raw data as a ValuePath.
+func (p *Parser) ParseValuePath(raw []byte) (ValuePath, error) {
+	return parseValuePath(raw, p.config)
+}
+
 // config represents the internal config of the parser functions.
 type config struct {
 	// useNumber indicates that json.Number needs to be returned instead of int/float64 values.
 	useNumber bool
+	// useRat indicates that *big.Rat needs to be returned instead of float64 values.
+	useRat bool
+	// limits bound the resources that are used by the parser.
+	limits Limits
+	// mode determines which filters are accepted.
+	mode Mode
+	// operators are the compare operators, except 'pr', in the order in which
+	// they are tried. Nil means compareOperators.
+	operators []CompareOperator
+	// schema is the URI prefix of attribute paths without one.
+	schema string
+	// positions indicates that the spans of nodes need to be set.
+	positions bool
 }
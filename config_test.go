@@ -0,0 +1,351 @@
+package filter
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func ExampleNewParser() {
+	p := NewParser(WithLimits(Limits{
+		MaxTerms: 2,
+	}))
+	fmt.Println(p.ParseFilter([]byte("title pr and userType eq \"Employee\"")))
+	_, err := p.ParseFilter([]byte("title pr and userType eq \"Employee\" and emails pr"))
+	var limitErr *LimitError
+	if errors.As(err, &limitErr) {
+		fmt.Println(limitErr, limitErr.ScimType())
+	}
+	// Output:
+	// title pr and userType eq "Employee" <nil>
+	// limit exceeded at offset 49: terms exceeds the maximum of 2 tooMany
+}
+
+func ExampleTrackPositions() {
+	raw := []byte("title pr and emails[type eq \"work\"]")
+	expr, _ := NewParser(TrackPositions()).ParseFilter(raw)
+	Walk(expr, func(e Expression) bool {
+		var span Span
+		switch e := e.(type) {
+		case *AttributeExpression:
+			span = e.Span
+		case *LogicalExpression:
+			span = e.Span
+		case *ValuePath:
+			span = e.Span
+		}
+		fmt.Printf("%d-%d: %s\n", span.Start, span.End, raw[span.Start:span.End])
+		return true
+	})
+	// Output:
+	// 0-35: title pr and emails[type eq "work"]
+	// 0-8: title pr
+	// 13-35: emails[type eq "work"]
+	// 20-34: type eq "work"
+}
+
+func ExampleWithOperators() {
+	p := NewParser(WithOperators("re"))
+	fmt.Println(p.ParseFilter([]byte("userName RE \"^b.*n$\"")))
+	// Output:
+	// userName re "^b.*n$" <nil>
+}
+
+func ExampleWithSchema() {
+	p := NewParser(WithSchema("urn:ietf:params:scim:schemas:core:2.0:User"))
+	fmt.Println(p.ParseFilter([]byte("emails[type eq \"work\"]")))
+	// Output:
+	// urn:ietf:params:scim:schemas:core:2.0:User:emails[type eq "work"] <nil>
+}
+
+func TestParser_mode(t *testing.T) {
+	strict := NewParser(WithMode(Strict))
+	lenient := NewParser(WithMode(Lenient))
+	for _, test := range []struct {
+		filter string
+		strict bool
+	}{
+		{filter: "userName eq \"bjensen\"", strict: true},
+		{filter: "title pr and userType eq \"Employee\"", strict: true},
+		{filter: "not (title pr)", strict: true},
+		{filter: "not(title pr)", strict: true},
+		{filter: "(title pr)", strict: true},
+		{filter: "emails[type eq \"work\" and value co \"@example.com\"]", strict: true},
+		{filter: "userName  eq \"bjensen\""},
+		{filter: "title pr  and userType eq \"Employee\""},
+		{filter: "not  (title pr)"},
+		{filter: "( title pr )"},
+		{filter: "( title pr)"},
+		{filter: "(title pr )"},
+		{filter: "not ( title pr)"},
+		{filter: "not (title pr )"},
+		{filter: "emails[(type eq \"work\") and value pr]", strict: true},
+		{filter: "emails[not (type eq \"work\")]", strict: true},
+		{filter: "emails[( type eq \"work\") and value pr]"},
+		{filter: "emails[(type eq \"work\" ) and value pr]"},
+		{filter: "emails[not ( type eq \"work\")]"},
+		{filter: "emails[not (type eq \"work\" )]"},
+		{filter: "emails [type eq \"work\"]"},
+		{filter: "emails[ type eq \"work\" ]"},
+		{filter: "emails[type eq \"work\"and value co \"@example.com\"]"},
+	} {
+		t.Run(test.filter, func(t *testing.T) {
+			if _, err := lenient.ParseFilter([]byte(test.filter)); err != nil {
+				t.Errorf("lenient: %v", err)
+			}
+			if _, err := strict.ParseFilter([]byte(test.filter)); (err == nil) != test.strict {
+				t.Errorf("strict: expected valid to be %t, got %v", test.strict, err)
+			}
+		})
+	}
+}
+
+func TestParser_operators(t *testing.T) {
+	p := NewParser(WithOperators("e", "Equals"), WithOperators("eq", "in", "Pre"))
+	for _, test := range []struct {
+		filter   string
+		operator CompareOperator
+	}{
+		{filter: "userName eq \"bjensen\"", operator: EQ},
+		{filter: "userName e \"bjensen\"", operator: "e"},
+		{filter: "userName EQUALS \"bjensen\"", operator: "equals"},
+		{filter: "userName in \"bjensen\"", operator: "in"},
+		{filter: "emails[value in \"bjensen\"]"},
+		{filter: "userName pre \"b\"", operator: "pre"},
+		{filter: "userName PRE \"b\"", operator: "pre"},
+		{filter: "userName pr", operator: PR},
+		{filter: "emails[value pre \"b\" and type pr]"},
+	} {
+		t.Run(test.filter, func(t *testing.T) {
+			expr, err := p.ParseFilter([]byte(test.filter))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if e, ok := expr.(*AttributeExpression); ok && e.Operator != test.operator {
+				t.Errorf("expected %q, got %q", test.operator, e.Operator)
+			}
+		})
+	}
+	if _, err := ParseFilter([]byte("userName in \"bjensen\"")); err == nil {
+		t.Error("expected an error for an operator that is not registered")
+	}
+
+	for _, op := range []CompareOperator{"", "r e", "≈", "=="} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("%q: expected a panic", op)
+				}
+			}()
+			WithOperators(op)
+		}()
+	}
+}
+
+func TestParser_positions(t *testing.T) {
+	p := NewParser(TrackPositions())
+	raw := `urn:ietf:params:scim:schemas:core:2.0:User:userName sw "J" or ` +
+		`not ( emails[type eq "work" and not(value ew "@example.com")] and meta.created gt 1e3 ) or ` +
+		`(name.familyName eq "O'Malley")`
+	expr, err := p.ParseFilter([]byte(raw))
+	if err != nil {
+		t.Fatal(err)
+	}
+	text := func(span Span) string {
+		return raw[span.Start:span.End]
+	}
+	var nodes []string
+	Walk(expr, func(e Expression) bool {
+		switch e := e.(type) {
+		case *AttributeExpression:
+			nodes = append(nodes, text(e.Span), text(e.AttributePath.Span), e.RawValue)
+		case *LogicalExpression:
+			nodes = append(nodes, text(e.Span))
+		case *NotExpression:
+			nodes = append(nodes, text(e.Span))
+		case *ValuePath:
+			nodes = append(nodes, text(e.Span), text(e.AttributePath.Span))
+		}
+		return true
+	})
+	expected := []string{
+		raw,
+		`urn:ietf:params:scim:schemas:core:2.0:User:userName sw "J" or not ( emails[type eq "work" and not(value ew "@example.com")] and meta.created gt 1e3 )`,
+		`urn:ietf:params:scim:schemas:core:2.0:User:userName sw "J"`,
+		`urn:ietf:params:scim:schemas:core:2.0:User:userName`,
+		`"J"`,
+		`not ( emails[type eq "work" and not(value ew "@example.com")] and meta.created gt 1e3 )`,
+		`emails[type eq "work" and not(value ew "@example.com")] and meta.created gt 1e3`,
+		`emails[type eq "work" and not(value ew "@example.com")]`,
+		`emails`,
+		`type eq "work" and not(value ew "@example.com")`,
+		`type eq "work"`,
+		`type`,
+		`"work"`,
+		`not(value ew "@example.com")`,
+		`value ew "@example.com"`,
+		`value`,
+		`"@example.com"`,
+		`meta.created gt 1e3`,
+		`meta.created`,
+		`1e3`,
+		`name.familyName eq "O'Malley"`,
+		`name.familyName`,
+		`"O'Malley"`,
+	}
+	if !slices.Equal(nodes, expected) {
+		t.Errorf("expected:\n%s\ngot:\n%s", strings.Join(expected, "\n"), strings.Join(nodes, "\n"))
+	}
+
+	path, err := p.ParsePath([]byte("members[value pr].displayName"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if path.Span != (Span{Start: 0, End: 29}) || path.AttributePath.Span != (Span{Start: 0, End: 7}) {
+		t.Errorf("unexpected spans: %v, %v", path.Span, path.AttributePath.Span)
+	}
+
+	// Positions are not tracked by default.
+	attrExp, err := ParseAttrExp([]byte("title eq \"x\""))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if attrExp.Span != (Span{}) || attrExp.AttributePath.Span != (Span{}) || attrExp.RawValue != "" {
+		t.Errorf("unexpected positions: %+v", attrExp)
+	}
+}
+
+func TestParser_schema(t *testing.T) {
+	const core = "urn:ietf:params:scim:schemas:core:2.0:User"
+	p := NewParser(WithSchema(core))
+	for _, test := range []struct {
+		filter   string
+		expected string
+	}{
+		{filter: "userName pr", expected: core + ":userName pr"},
+		{filter: "name.givenName pr", expected: core + ":name.givenName pr"},
+		{
+			filter:   "urn:ietf:params:scim:schemas:extension:enterprise:2.0:User:employeeNumber pr",
+			expected: "urn:ietf:params:scim:schemas:extension:enterprise:2.0:User:employeeNumber pr",
+		},
+		{
+			filter:   "emails[type eq \"work\" and not (primary eq true)] or title pr",
+			expected: core + ":emails[type eq \"work\" and not (primary eq true)] or " + core + ":title pr",
+		},
+	} {
+		t.Run(test.filter, func(t *testing.T) {
+			expr, err := p.ParseFilter([]byte(test.filter))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if s := Format(expr); s != test.expected {
+				t.Errorf("expected %s, got %s", test.expected, s)
+			}
+		})
+	}
+
+	path, err := p.ParsePath([]byte("members[value pr].displayName"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s := path.String(); s != core+":members[value pr].displayName" {
+		t.Errorf("unexpected path: %s", s)
+	}
+}
+
+func TestParser_useNumber(t *testing.T) {
+	attrExp, err := NewParser(UseNumber()).ParseAttrExp([]byte("age gt 10"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := attrExp.CompareValue.(json.Number); !ok {
+		t.Errorf("expected json.Number, got %T", attrExp.CompareValue)
+	}
+}
+
+func TestParser_limits(t *testing.T) {
+	nested := func(n int) string {
+		return strings.Repeat("not (", n) + "title pr" + strings.Repeat(")", n)
+	}
+	terms := func(n int) string {
+		return strings.Repeat("title pr or ", n-1) + "title pr"
+	}
+	limits := Limits{
+		MaxLength:       256,
+		MaxDepth:        4,
+		MaxTerms:        8,
+		MaxStringLength: 8,
+	}
+	p := NewParser(WithLimits(limits))
+	for _, test := range []struct {
+		name   string
+		parse  func([]byte) error
+		input  string
+		limit  Limit
+		offset int
+	}{
+		{name: "length", input: terms(22), limit: LengthLimit, offset: 256},
+		{name: "depth", input: nested(4)},
+		{name: "depth", input: nested(5), limit: DepthLimit, offset: 25},
+		{name: "depth", input: "((((title pr))))"},
+		{name: "depth", input: "(((((title pr)))))", limit: DepthLimit, offset: 5},
+		{name: "depth", input: "((((emails[type pr]))))", limit: DepthLimit, offset: 11},
+		{name: "depth", input: "emails[(((type pr)))]"},
+		{name: "depth", input: "emails[((((type pr))))]", limit: DepthLimit, offset: 11},
+		{
+			name: "depth",
+			parse: func(raw []byte) error {
+				_, err := p.ParsePath(raw)
+				return err
+			},
+			input:  "emails[((((type pr))))].value",
+			limit:  DepthLimit,
+			offset: 11,
+		},
+		{name: "terms", input: terms(8)},
+		{name: "terms", input: terms(9), limit: TermsLimit, offset: 104},
+		{name: "terms", input: "emails[type pr or value pr] and " + terms(7), limit: TermsLimit, offset: 112},
+		{name: "string", input: "userName eq \"12345678\""},
+		{name: "string", input: "userName eq \"123456789\"", limit: StringLengthLimit, offset: 21},
+		{name: "string", input: "userName eq \"\\\"\\\"\\\"\\\"\\\"\"", limit: StringLengthLimit, offset: 21},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			if test.parse == nil {
+				test.parse = func(raw []byte) error {
+					_, err := p.ParseFilter(raw)
+					return err
+				}
+			}
+			err := test.parse([]byte(test.input))
+			if test.limit == "" {
+				if err != nil {
+					t.Fatal(err)
+				}
+				return
+			}
+			var limitErr *LimitError
+			if !errors.As(err, &limitErr) {
+				t.Fatalf("expected a limit error, got %v", err)
+			}
+			if limitErr.Limit != test.limit || limitErr.Offset != test.offset {
+				t.Errorf("expected %s at offset %d, got %s at offset %d", test.limit, test.offset, limitErr.Limit, limitErr.Offset)
+			}
+		})
+	}
+
+	t.Run("default", func(t *testing.T) {
+		if _, err := ParseFilter([]byte(nested(DefaultLimits.MaxDepth + 1))); err != nil {
+			t.Error(err)
+		}
+		if _, err := NewParser().ParseFilter([]byte(nested(DefaultLimits.MaxDepth + 1))); err != nil {
+			t.Error(err)
+		}
+		var limitErr *LimitError
+		if _, err := NewParser(WithLimits(DefaultLimits)).ParseFilter([]byte(nested(DefaultLimits.MaxDepth + 1))); !errors.As(err, &limitErr) {
+			t.Errorf("expected a limit error, got %v", err)
+		}
+	})
+}
@@ -0,0 +1,377 @@
+// Package elastic translates filter expressions to the query DSL of
+// Elasticsearch and OpenSearch. Queries are plain maps that can be encoded as
+// JSON.
+//
+// More info: https://www.elastic.co/guide/en/elasticsearch/reference/current/query-dsl.html
+package elastic
+
+import (
+	"encoding/json"
+	"fmt"
+	filter "github.com/scim2/filter-parser/v2"
+	"math/big"
+	"strings"
+)
+
+// ranges maps compare operators to the parameters of the range query.
+var ranges = map[filter.CompareOperator]string{
+	filter.GT: "gt",
+	filter.GE: "gte",
+	filter.LT: "lt",
+	filter.LE: "lte",
+}
+
+// Translate translates the given expression to a query. Attribute paths are
+// resolved with the given mapping.
+//
+// String comparisons use the keyword sub-field of the field (if any) and are
+// case-insensitive unless the field is marked as case exact. Value paths and
+// sub-attributes of nested fields are translated to nested queries.
+//
+// An attribute expression that is negated matches documents in which the field
+// is missing, like the 'ne' operator does. Sub-attributes of nested fields
+// match like Evaluate does, e.g. emails.value ne "..." matches if none of the
+// values are equal.
+func Translate(expr filter.Expression, mapping Mapping) (map[string]any, error) {
+	t := translator{
+		fields: make(Mapping, len(mapping)),
+	}
+	for k, v := range mapping {
+		t.fields[key(k)] = v
+	}
+	return t.translate(expr, nil)
+}
+
+// boolQuery returns a bool query with the given clauses.
+func boolQuery(occur string, clauses []any) map[string]any {
+	query := map[string]any{
+		occur: clauses,
+	}
+	if occur == "should" {
+		query["minimum_should_match"] = 1
+	}
+	return map[string]any{
+		"bool": query,
+	}
+}
+
+// escape escapes the wildcards of the wildcard query.
+func escape(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `*`, `\*`, `?`, `\?`).Replace(s)
+}
+
+// exists returns an exists query on the given field.
+func exists(field string) map[string]any {
+	return map[string]any{
+		"exists": map[string]any{
+			"field": field,
+		},
+	}
+}
+
+// fullPath returns the given attribute path, which is a sub-attribute of the
+// given value path if the parent is not nil.
+func fullPath(path filter.AttributePath, parent *filter.AttributePath) filter.AttributePath {
+	if parent == nil {
+		return path
+	}
+	full := *parent
+	full.SubAttribute = &path.AttributeName
+	return full
+}
+
+// key returns the normalized key of the given attribute path.
+func key(path string) string {
+	return strings.ToLower(path)
+}
+
+// nestedQuery returns a nested query on the given field.
+func nestedQuery(field Field, query map[string]any) map[string]any {
+	return map[string]any{
+		"nested": map[string]any{
+			"path":  field.Name,
+			"query": query,
+		},
+	}
+}
+
+// value converts the given compare value to a value that can be encoded as
+// JSON. Big numbers are converted to json.Number, so that they are encoded
+// without losing precision.
+func value(compareValue any) (any, error) {
+	switch v := compareValue.(type) {
+	case *big.Int:
+		return json.Number(v.String()), nil
+	case *big.Rat:
+		prec, _ := v.FloatPrec()
+		return json.Number(v.FloatString(prec)), nil
+	case bool, string, json.Number, int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64, float32, float64:
+		return v, nil
+	default:
+		return nil, fmt.Errorf("invalid compare value: %v (%T)", compareValue, compareValue)
+	}
+}
+
+// Field describes the field of a document in which the value of an attribute
+// is stored.
+type Field struct {
+	// Name is the (dotted) name of the field, e.g. "name.familyName".
+	Name string
+	// Keyword is the name of the keyword sub-field of a text field, which is
+	// used to compare strings, e.g. "keyword" (as created by dynamic
+	// mappings) results in "name.familyName.keyword".
+	Keyword string
+	// CaseExact indicates that strings are compared case-sensitively.
+	CaseExact bool
+	// Nested indicates that the field is of the nested type, i.e. that its
+	// values are indexed as separate documents.
+	Nested bool
+}
+
+// keyword returns the name of the field that is used to compare strings.
+func (f Field) keyword() string {
+	if f.Keyword == "" {
+		return f.Name
+	}
+	return f.Name + "." + f.Keyword
+}
+
+// Mapping maps attribute paths to fields. The keys are attribute paths as
+// accepted by filter.ParseAttrPath (e.g. "name.familyName") and are matched
+// case-insensitively. An attribute path with a URI prefix falls back to the
+// field of the path without the prefix.
+//
+// Value paths need both the attribute (e.g. "emails") and its sub-attributes
+// (e.g. "emails.type") to be mapped. The attribute needs to be nested, since
+// the values of other fields are flattened.
+type Mapping map[string]Field
+
+type translator struct {
+	fields Mapping
+}
+
+// lookup returns the field that is mapped to the given attribute path.
+func (t *translator) lookup(path filter.AttributePath) (Field, error) {
+	field, ok := t.fields[key(path.String())]
+	if !ok && path.URIPrefix != nil {
+		path.URIPrefix = nil
+		field, ok = t.fields[key(path.String())]
+	}
+	if !ok {
+		return Field{}, fmt.Errorf("no field mapped for attribute %q", path)
+	}
+	return field, nil
+}
+
+// nested returns the nested field that contains the given attribute path, if
+// any. E.g. "emails" for "emails.value" if emails is nested.
+func (t *translator) nested(path filter.AttributePath) (Field, bool) {
+	if path.SubAttribute == nil {
+		return Field{}, false
+	}
+	path.SubAttribute = nil
+	field, err := t.lookup(path)
+	return field, err == nil && field.Nested
+}
+
+// translate translates the given expression. If the parent is not nil, the
+// expression is the value filter of that (nested) attribute path.
+func (t *translator) translate(expr filter.Expression, parent *filter.AttributePath) (map[string]any, error) {
+	switch e := expr.(type) {
+	case *filter.AttributeExpression:
+		if parent != nil && (e.AttributePath.URIPrefix != nil || e.AttributePath.SubAttribute != nil) {
+			return nil, fmt.Errorf("value filters can only contain sub-attributes: %q", e.AttributePath)
+		}
+		path := fullPath(e.AttributePath, parent)
+		field, err := t.lookup(path)
+		if err != nil {
+			return nil, err
+		}
+		if parent == nil {
+			// e.g. emails.value eq "..." => emails[value eq "..."]
+			if nested, ok := t.nested(path); ok {
+				return t.translateNested(nested, field, e)
+			}
+		}
+		return t.translateAttrExp(field, e)
+	case *filter.LogicalExpression:
+		var occur string
+		switch filter.LogicalOperator(strings.ToLower(string(e.Operator))) {
+		case filter.AND:
+			occur = "must"
+		case filter.OR:
+			occur = "should"
+		default:
+			return nil, fmt.Errorf("invalid logical operator: %q", e.Operator)
+		}
+		clauses, err := t.translateOperands(e.Operator, e, parent)
+		if err != nil {
+			return nil, err
+		}
+		return boolQuery(occur, clauses), nil
+	case *filter.NotExpression:
+		query, err := t.translate(e.Expression, parent)
+		if err != nil {
+			return nil, err
+		}
+		return boolQuery("must_not", []any{query}), nil
+	case *filter.ValuePath:
+		if parent != nil {
+			return nil, fmt.Errorf("value paths can not be nested: %q", e)
+		}
+		field, err := t.lookup(e.AttributePath)
+		if err != nil {
+			return nil, err
+		}
+		if !field.Nested {
+			return nil, fmt.Errorf("value path %q requires a nested field", e)
+		}
+		query, err := t.translate(e.ValueFilter, &e.AttributePath)
+		if err != nil {
+			return nil, err
+		}
+		return nestedQuery(field, query), nil
+	default:
+		return nil, fmt.Errorf("invalid expression type: %T", expr)
+	}
+}
+
+func (t *translator) translateAttrExp(field Field, e *filter.AttributeExpression) (map[string]any, error) {
+	op := filter.CompareOperator(strings.ToLower(string(e.Operator)))
+	if op == filter.PR {
+		return exists(field.Name), nil
+	}
+	if e.CompareValue == nil {
+		switch op {
+		case filter.EQ:
+			return boolQuery("must_not", []any{exists(field.Name)}), nil
+		case filter.NE:
+			return exists(field.Name), nil
+		default:
+			return nil, fmt.Errorf("operator %q is not supported on null", op)
+		}
+	}
+
+	v, err := value(e.CompareValue)
+	if err != nil {
+		return nil, err
+	}
+	str, isString := v.(string)
+	if _, ok := v.(bool); ok {
+		switch op {
+		case filter.EQ, filter.NE:
+		default:
+			return nil, fmt.Errorf("operator %q is not supported on boolean values", op)
+		}
+	}
+
+	name := field.Name
+	if isString {
+		name = field.keyword()
+	}
+	switch op {
+	case filter.EQ, filter.NE:
+		term := map[string]any{
+			"value": v,
+		}
+		if isString && !field.CaseExact {
+			term["case_insensitive"] = true
+		}
+		query := map[string]any{
+			"term": map[string]any{
+				name: term,
+			},
+		}
+		if op == filter.NE {
+			return boolQuery("must_not", []any{query}), nil
+		}
+		return query, nil
+	case filter.CO, filter.SW, filter.EW:
+		if !isString {
+			return nil, fmt.Errorf("operator %q is only supported on strings", op)
+		}
+		queryType := "wildcard"
+		pattern := escape(str)
+		switch op {
+		case filter.CO:
+			pattern = "*" + pattern + "*"
+		case filter.SW:
+			queryType = "prefix"
+			pattern = str
+		case filter.EW:
+			pattern = "*" + pattern
+		}
+		query := map[string]any{
+			"value": pattern,
+		}
+		if !field.CaseExact {
+			query["case_insensitive"] = true
+		}
+		return map[string]any{
+			queryType: map[string]any{
+				name: query,
+			},
+		}, nil
+	case filter.GT, filter.GE, filter.LT, filter.LE:
+		return map[string]any{
+			"range": map[string]any{
+				name: map[string]any{
+					ranges[op]: v,
+				},
+			},
+		}, nil
+	default:
+		return nil, fmt.Errorf("invalid compare operator: %q", op)
+	}
+}
+
+// translateNested translates the given attribute expression on a sub-attribute
+// of the given nested field to a nested query. The 'ne' operator is the
+// negation of 'eq', so that it matches if none of the values are equal:
+// emails.value ne "..." => not (emails[value eq "..."]). The same goes for
+// 'eq null', which matches if none of the values are present.
+func (t *translator) translateNested(nested, field Field, e *filter.AttributeExpression) (map[string]any, error) {
+	op := filter.CompareOperator(strings.ToLower(string(e.Operator)))
+	var negate bool
+	switch {
+	case op == filter.NE && e.CompareValue != nil:
+		op, negate = filter.EQ, true
+	case op == filter.EQ && e.CompareValue == nil:
+		op, negate = filter.NE, true
+	}
+	query, err := t.translateAttrExp(field, &filter.AttributeExpression{
+		AttributePath: e.AttributePath,
+		Operator:      op,
+		CompareValue:  e.CompareValue,
+	})
+	if err != nil {
+		return nil, err
+	}
+	query = nestedQuery(nested, query)
+	if negate {
+		return boolQuery("must_not", []any{query}), nil
+	}
+	return query, nil
+}
+
+// translateOperands translates the operands of the given logical expression.
+// Operands with the same operator are flattened, e.g. {bool: {must: [a, b, c]}}.
+func (t *translator) translateOperands(operator filter.LogicalOperator, expr filter.Expression, parent *filter.AttributePath) ([]any, error) {
+	e, ok := expr.(*filter.LogicalExpression)
+	if !ok || !strings.EqualFold(string(e.Operator), string(operator)) {
+		query, err := t.translate(expr, parent)
+		if err != nil {
+			return nil, err
+		}
+		return []any{query}, nil
+	}
+	left, err := t.translateOperands(operator, e.Left, parent)
+	if err != nil {
+		return nil, err
+	}
+	right, err := t.translateOperands(operator, e.Right, parent)
+	if err != nil {
+		return nil, err
+	}
+	return append(left, right...), nil
+}
@@ -0,0 +1,308 @@
+package elastic
+
+import (
+	"encoding/json"
+	"fmt"
+	filter "github.com/scim2/filter-parser/v2"
+	"regexp"
+	"slices"
+	"strings"
+	"testing"
+)
+
+var mapping = Mapping{
+	"id":              {Name: "id", CaseExact: true},
+	"userName":        {Name: "userName", Keyword: "keyword"},
+	"name.familyName": {Name: "name.familyName", Keyword: "keyword"},
+	"active":          {Name: "active"},
+	"meta.created":    {Name: "meta.created", CaseExact: true},
+	"emails":          {Name: "emails", Nested: true},
+	"emails.type":     {Name: "emails.type"},
+	"emails.value":    {Name: "emails.value", Keyword: "keyword"},
+	"emails.primary":  {Name: "emails.primary"},
+	"addresses":       {Name: "addresses"},
+	"addresses.type":  {Name: "addresses.type"},
+	"urn:ietf:params:scim:schemas:extension:enterprise:2.0:User:employeeNumber": {Name: "enterprise.employeeNumber"},
+}
+
+func ExampleTranslate() {
+	expression, _ := filter.ParseFilter([]byte("userName eq \"bjensen\" and emails[type eq \"work\"]"))
+	query, _ := Translate(expression, mapping)
+	raw, _ := json.Marshal(query)
+	fmt.Println(string(raw))
+	// Output:
+	// {"bool":{"must":[{"term":{"userName.keyword":{"case_insensitive":true,"value":"bjensen"}}},{"nested":{"path":"emails","query":{"term":{"emails.type":{"case_insensitive":true,"value":"work"}}}}}]}}
+}
+
+func TestTranslate(t *testing.T) {
+	for _, test := range []struct {
+		filter   string
+		expected string
+	}{
+		{filter: `userName eq "bjensen"`, expected: `{"term":{"userName.keyword":{"case_insensitive":true,"value":"bjensen"}}}`},
+		{filter: `USERNAME EQ "bjensen"`, expected: `{"term":{"userName.keyword":{"case_insensitive":true,"value":"bjensen"}}}`},
+		{filter: `id eq "2819c223"`, expected: `{"term":{"id":{"value":"2819c223"}}}`},
+		{filter: `userName ne "bjensen"`, expected: `{"bool":{"must_not":[{"term":{"userName.keyword":{"case_insensitive":true,"value":"bjensen"}}}]}}`},
+		{filter: `userName co "a*b?"`, expected: `{"wildcard":{"userName.keyword":{"case_insensitive":true,"value":"*a\\*b\\?*"}}}`},
+		{filter: `userName sw "b*"`, expected: `{"prefix":{"userName.keyword":{"case_insensitive":true,"value":"b*"}}}`},
+		{filter: `userName ew "\\"`, expected: `{"wildcard":{"userName.keyword":{"case_insensitive":true,"value":"*\\\\"}}}`},
+		{filter: `id sw "2819"`, expected: `{"prefix":{"id":{"value":"2819"}}}`},
+		{filter: `userName pr`, expected: `{"exists":{"field":"userName"}}`},
+		{filter: `userName eq null`, expected: `{"bool":{"must_not":[{"exists":{"field":"userName"}}]}}`},
+		{filter: `userName ne null`, expected: `{"exists":{"field":"userName"}}`},
+		{filter: `active eq true`, expected: `{"term":{"active":{"value":true}}}`},
+		{filter: `meta.created gt "2011-05-13T04:42:34Z"`, expected: `{"range":{"meta.created":{"gt":"2011-05-13T04:42:34Z"}}}`},
+		{filter: `meta.created le "2011-05-13T04:42:34Z"`, expected: `{"range":{"meta.created":{"lte":"2011-05-13T04:42:34Z"}}}`},
+		{filter: `urn:ietf:params:scim:schemas:extension:enterprise:2.0:User:employeeNumber ge 701984`, expected: `{"range":{"enterprise.employeeNumber":{"gte":701984}}}`},
+		{filter: `urn:ietf:params:scim:schemas:extension:enterprise:2.0:User:employeeNumber lt 92233720368547758070`, expected: `{"range":{"enterprise.employeeNumber":{"lt":92233720368547758070}}}`},
+		{filter: `urn:ietf:params:scim:schemas:core:2.0:User:active eq true`, expected: `{"term":{"active":{"value":true}}}`},
+		{
+			filter:   `emails.value ew "@example.com"`,
+			expected: `{"nested":{"path":"emails","query":{"wildcard":{"emails.value.keyword":{"case_insensitive":true,"value":"*@example.com"}}}}}`,
+		},
+		{
+			filter:   `emails.value ne "bjensen@example.com"`,
+			expected: `{"bool":{"must_not":[{"nested":{"path":"emails","query":{"term":{"emails.value.keyword":{"case_insensitive":true,"value":"bjensen@example.com"}}}}}]}}`,
+		},
+		{
+			filter:   `emails.value eq null`,
+			expected: `{"bool":{"must_not":[{"nested":{"path":"emails","query":{"exists":{"field":"emails.value"}}}}]}}`,
+		},
+		{filter: `addresses.type ne "work"`, expected: `{"bool":{"must_not":[{"term":{"addresses.type":{"case_insensitive":true,"value":"work"}}}]}}`},
+		{filter: `addresses.type eq "work"`, expected: `{"term":{"addresses.type":{"case_insensitive":true,"value":"work"}}}`},
+		{
+			filter:   `active eq true and userName pr and id pr`,
+			expected: `{"bool":{"must":[{"term":{"active":{"value":true}}},{"exists":{"field":"userName"}},{"exists":{"field":"id"}}]}}`,
+		},
+		{
+			filter:   `active eq true or userName pr and id pr`,
+			expected: `{"bool":{"minimum_should_match":1,"should":[{"term":{"active":{"value":true}}},{"bool":{"must":[{"exists":{"field":"userName"}},{"exists":{"field":"id"}}]}}]}}`,
+		},
+		{filter: `not (active eq true)`, expected: `{"bool":{"must_not":[{"term":{"active":{"value":true}}}]}}`},
+		{
+			filter:   `emails[type eq "work" and not (primary eq true)]`,
+			expected: `{"nested":{"path":"emails","query":{"bool":{"must":[{"term":{"emails.type":{"case_insensitive":true,"value":"work"}}},{"bool":{"must_not":[{"term":{"emails.primary":{"value":true}}}]}}]}}}}`,
+		},
+	} {
+		t.Run(test.filter, func(t *testing.T) {
+			expression, err := filter.ParseFilter([]byte(test.filter))
+			if err != nil {
+				t.Fatal(err)
+			}
+			query, err := Translate(expression, mapping)
+			if err != nil {
+				t.Fatal(err)
+			}
+			raw, err := json.Marshal(query)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(raw) != test.expected {
+				t.Errorf("expected %s, got %s", test.expected, raw)
+			}
+		})
+	}
+}
+
+func TestTranslate_invalid(t *testing.T) {
+	for _, test := range []string{
+		`nickName eq "Babs"`,
+		`active gt true`,
+		`userName co 1`,
+		`userName lt null`,
+		`emails[display eq "x"]`,
+		`addresses[type eq "work"]`,
+	} {
+		t.Run(test, func(t *testing.T) {
+			expression, err := filter.ParseFilter([]byte(test))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, err := Translate(expression, mapping); err == nil {
+				t.Error("expected an error")
+			}
+		})
+	}
+}
+
+// TestTranslate_evaluate checks that the translated queries match the same
+// documents as Evaluate, by evaluating them against the documents in memory.
+func TestTranslate_evaluate(t *testing.T) {
+	mapping := Mapping{
+		"userName":       {Name: "userName"},
+		"active":         {Name: "active"},
+		"emails":         {Name: "emails", Nested: true},
+		"emails.value":   {Name: "emails.value"},
+		"emails.type":    {Name: "emails.type"},
+		"emails.primary": {Name: "emails.primary"},
+	}
+	resources := []map[string]any{
+		{"userName": "bjensen", "active": true, "emails": []any{
+			map[string]any{"value": "a@x", "type": "work", "primary": true},
+			map[string]any{"value": "b@y", "type": "home"},
+		}},
+		{"userName": "BJensen", "emails": []any{
+			map[string]any{"value": "A@X", "type": "work"},
+		}},
+		{},
+		{"userName": "x", "active": false, "emails": []any{
+			map[string]any{"type": "work"},
+		}},
+		{"emails": []any{
+			map[string]any{"value": "b@y", "type": "home", "primary": false},
+		}},
+		{"emails": []any{}},
+	}
+	for _, raw := range []string{
+		`userName eq "bjensen"`,
+		`userName ne "bjensen"`,
+		`emails.value eq "a@x"`,
+		`emails.value ne "a@x"`,
+		`emails.value eq null`,
+		`emails.value ne null`,
+		`emails.value pr`,
+		`emails.value co "@"`,
+		`emails.primary ne true`,
+		`not (emails.value ne "a@x")`,
+		`emails[value ne "a@x"]`,
+		`emails[type eq "work" and value ne "a@x"]`,
+		`not (userName eq "bjensen") and emails.type ne "work"`,
+		`active ne true or emails.value eq null`,
+	} {
+		t.Run(raw, func(t *testing.T) {
+			expression, err := filter.ParseFilter([]byte(raw))
+			if err != nil {
+				t.Fatal(err)
+			}
+			query, err := Translate(expression, mapping)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, resource := range resources {
+				expected, err := filter.Evaluate(expression, resource)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if match := matchQuery(t, query, resource); match != expected {
+					t.Errorf("%v: expected %v, got %v for %v", query, expected, match, resource)
+				}
+			}
+		})
+	}
+}
+
+// fieldValues returns the values of the given (dotted) field within the given
+// document. Arrays are flattened, like Elasticsearch does.
+func fieldValues(document any, field string) []any {
+	name, rest, _ := strings.Cut(field, ".")
+	var values []any
+	switch v := document.(type) {
+	case map[string]any:
+		value, ok := v[name]
+		if !ok {
+			return nil
+		}
+		if rest == "" {
+			if array, ok := value.([]any); ok {
+				return array
+			}
+			return []any{value}
+		}
+		return fieldValues(value, rest)
+	case []any:
+		for _, element := range v {
+			values = append(values, fieldValues(element, field)...)
+		}
+	}
+	return values
+}
+
+// matchQuery reports whether the given query, as returned by Translate,
+// matches the given document. Only the queries that Translate generates are
+// supported.
+func matchQuery(t *testing.T, query map[string]any, document map[string]any) bool {
+	if len(query) != 1 {
+		t.Fatalf("invalid query: %v", query)
+	}
+	matchAny := func(field string, match func(value any) bool) bool {
+		for _, value := range fieldValues(document, field) {
+			if value != nil && match(value) {
+				return true
+			}
+		}
+		return false
+	}
+	for queryType, params := range query {
+		params := params.(map[string]any)
+		switch queryType {
+		case "bool":
+			for occur, clauses := range params {
+				clauses, ok := clauses.([]any)
+				if !ok {
+					// minimum_should_match
+					continue
+				}
+				for _, clause := range clauses {
+					match := matchQuery(t, clause.(map[string]any), document)
+					switch occur {
+					case "must":
+						if !match {
+							return false
+						}
+					case "must_not":
+						if match {
+							return false
+						}
+					}
+				}
+				if occur == "should" {
+					if !slices.ContainsFunc(clauses, func(clause any) bool {
+						return matchQuery(t, clause.(map[string]any), document)
+					}) {
+						return false
+					}
+				}
+			}
+			return true
+		case "nested":
+			path := params["path"].(string)
+			return slices.ContainsFunc(fieldValues(document, path), func(value any) bool {
+				return matchQuery(t, params["query"].(map[string]any), map[string]any{path: value})
+			})
+		case "exists":
+			return matchAny(params["field"].(string), func(any) bool { return true })
+		}
+		for field, p := range params {
+			p := p.(map[string]any)
+			caseInsensitive, _ := p["case_insensitive"].(bool)
+			equal := func(value any) bool {
+				if s, ok := value.(string); ok && caseInsensitive {
+					return strings.EqualFold(s, p["value"].(string))
+				}
+				return value == p["value"]
+			}
+			switch queryType {
+			case "term":
+				return matchAny(field, equal)
+			case "prefix":
+				return matchAny(field, func(value any) bool {
+					s, ok := value.(string)
+					return ok && len(s) >= len(p["value"].(string)) && equal(s[:len(p["value"].(string))])
+				})
+			case "wildcard":
+				pattern := regexp.QuoteMeta(p["value"].(string))
+				pattern = strings.ReplaceAll(strings.ReplaceAll(pattern, `\*`, ".*"), `\?`, ".")
+				if caseInsensitive {
+					pattern = "(?i)" + pattern
+				}
+				re := regexp.MustCompile("^" + pattern + "$")
+				return matchAny(field, func(value any) bool {
+					s, ok := value.(string)
+					return ok && re.MatchString(s)
+				})
+			}
+		}
+	}
+	t.Fatalf("unsupported query: %v", query)
+	return false
+}
@@ -0,0 +1,231 @@
+package filter
+
+import (
+	"encoding/binary"
+	"fmt"
+	"hash"
+	"hash/fnv"
+	"math/big"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// Clone returns a deep copy of the given expression. Unlike the copies made by
+// Rewrite, the copy does not share the URI prefixes and sub-attributes of its
+// attribute paths, or big numbers, with the original.
+func Clone(expr Expression) Expression {
+	switch e := expr.(type) {
+	case *AttributeExpression:
+		attrExp := *e
+		attrExp.AttributePath = cloneAttrPath(e.AttributePath)
+		switch v := e.CompareValue.(type) {
+		case *big.Int:
+			attrExp.CompareValue = new(big.Int).Set(v)
+		case *big.Rat:
+			attrExp.CompareValue = new(big.Rat).Set(v)
+		}
+		return &attrExp
+	case *LogicalExpression:
+		return &LogicalExpression{
+			Left:     Clone(e.Left),
+			Right:    Clone(e.Right),
+			Operator: e.Operator,
+			Span:     e.Span,
+		}
+	case *NotExpression:
+		return &NotExpression{
+			Expression: Clone(e.Expression),
+			Span:       e.Span,
+		}
+	case *ValuePath:
+		return &ValuePath{
+			AttributePath: cloneAttrPath(e.AttributePath),
+			ValueFilter:   Clone(e.ValueFilter),
+			Span:          e.Span,
+		}
+	default:
+		return expr
+	}
+}
+
+// ClonePath returns a deep copy of the given path, see Clone.
+func ClonePath(path Path) Path {
+	return Path{
+		AttributePath:   cloneAttrPath(path.AttributePath),
+		ValueExpression: Clone(path.ValueExpression),
+		SubAttribute:    cloneString(path.SubAttribute),
+		Span:            path.Span,
+	}
+}
+
+// Equal reports whether the given expressions are structurally equal, i.e.
+// whether they have the same nodes in the same order. Attribute names, URIs
+// and operators are compared case-insensitively (RFC 7644, Section 3.4.2.2).
+// Numbers are compared by value: integers of any type (including json.Number
+// and big numbers) exactly, other numbers as float64, e.g. 1,
+// json.Number("1e0") and 1.0 are equal. Spans and raw values are ignored.
+//
+// More info: https://tools.ietf.org/html/rfc7644#section-3.4.2.2
+func Equal(a, b Expression) bool {
+	switch a := a.(type) {
+	case *AttributeExpression:
+		b, ok := b.(*AttributeExpression)
+		return ok &&
+			equalAttrPaths(a.AttributePath, b.AttributePath) &&
+			strings.EqualFold(string(a.Operator), string(b.Operator)) &&
+			equalValues(a.CompareValue, b.CompareValue)
+	case *LogicalExpression:
+		b, ok := b.(*LogicalExpression)
+		return ok &&
+			strings.EqualFold(string(a.Operator), string(b.Operator)) &&
+			Equal(a.Left, b.Left) &&
+			Equal(a.Right, b.Right)
+	case *NotExpression:
+		b, ok := b.(*NotExpression)
+		return ok && Equal(a.Expression, b.Expression)
+	case *ValuePath:
+		b, ok := b.(*ValuePath)
+		return ok &&
+			equalAttrPaths(a.AttributePath, b.AttributePath) &&
+			Equal(a.ValueFilter, b.ValueFilter)
+	default:
+		return a == b
+	}
+}
+
+// Hash returns a hash of the given expression. Expressions that are equal (see
+// Equal) have the same hash. The hash is stable, i.e. it does not change
+// between runs of a program, so it can be used as a cache key. Note that
+// different expressions can have the same hash.
+func Hash(expr Expression) uint64 {
+	h := fnv.New64a()
+	writeHash(h, expr)
+	return h.Sum64()
+}
+
+// cloneAttrPath returns a copy of the given attribute path that does not share
+// its URI prefix and sub-attribute.
+func cloneAttrPath(path AttributePath) AttributePath {
+	path.URIPrefix = cloneString(path.URIPrefix)
+	path.SubAttribute = cloneString(path.SubAttribute)
+	return path
+}
+
+func cloneString(s *string) *string {
+	if s == nil {
+		return nil
+	}
+	c := *s
+	return &c
+}
+
+// equalAttrPaths compares two attribute paths case-insensitively.
+func equalAttrPaths(a, b AttributePath) bool {
+	return (a.URIPrefix == nil) == (b.URIPrefix == nil) &&
+		(a.SubAttribute == nil) == (b.SubAttribute == nil) &&
+		strings.EqualFold(a.URI(), b.URI()) &&
+		strings.EqualFold(a.AttributeName, b.AttributeName) &&
+		strings.EqualFold(a.SubAttributeName(), b.SubAttributeName())
+}
+
+// equalValues compares two compare values, see Equal.
+func equalValues(a, b any) bool {
+	if x, ok := numberKey(a); ok {
+		y, ok := numberKey(b)
+		return ok && x == y
+	}
+	switch a := a.(type) {
+	case nil:
+		return b == nil
+	case bool:
+		b, ok := b.(bool)
+		return ok && a == b
+	case string:
+		b, ok := b.(string)
+		return ok && a == b
+	default:
+		return reflect.DeepEqual(a, b)
+	}
+}
+
+// numberKey returns a canonical representation of the given number. Integers
+// are represented exactly, other numbers as float64.
+func numberKey(value any) (string, bool) {
+	if _, ok := toFloat(value); !ok {
+		return "", false
+	}
+	r, ok := toRat(value)
+	if !ok {
+		// NaN or infinity.
+		f, _ := toFloat(value)
+		return strconv.FormatFloat(f, 'g', -1, 64), true
+	}
+	if r.IsInt() {
+		return r.Num().String(), true
+	}
+	f, _ := r.Float64()
+	return strconv.FormatFloat(f, 'g', -1, 64), true
+}
+
+// writeHash writes a canonical encoding of the given expression, see Equal, to
+// the given hash. Every node starts with a tag byte and strings are prefixed by
+// their length, so that different expressions have different encodings.
+func writeHash(h hash.Hash64, expr Expression) {
+	writeString := func(s string) {
+		_, _ = h.Write(binary.AppendUvarint(nil, uint64(len(s))))
+		_, _ = h.Write([]byte(s))
+	}
+	writePath := func(path AttributePath) {
+		var flags byte
+		if path.URIPrefix != nil {
+			flags |= 1
+		}
+		if path.SubAttribute != nil {
+			flags |= 2
+		}
+		_, _ = h.Write([]byte{flags})
+		writeString(strings.ToLower(path.URI()))
+		writeString(strings.ToLower(path.AttributeName))
+		writeString(strings.ToLower(path.SubAttributeName()))
+	}
+
+	switch e := expr.(type) {
+	case *AttributeExpression:
+		_, _ = h.Write([]byte{'A'})
+		writePath(e.AttributePath)
+		writeString(strings.ToLower(string(e.Operator)))
+		if key, ok := numberKey(e.CompareValue); ok {
+			_, _ = h.Write([]byte{'n'})
+			writeString(key)
+			return
+		}
+		switch v := e.CompareValue.(type) {
+		case nil:
+			_, _ = h.Write([]byte{'0'})
+		case bool:
+			_, _ = h.Write([]byte{'b'})
+			writeString(strconv.FormatBool(v))
+		case string:
+			_, _ = h.Write([]byte{'s'})
+			writeString(v)
+		default:
+			_, _ = h.Write([]byte{'?'})
+			writeString(fmt.Sprintf("%T %v", v, v))
+		}
+	case *LogicalExpression:
+		_, _ = h.Write([]byte{'L'})
+		writeString(strings.ToLower(string(e.Operator)))
+		writeHash(h, e.Left)
+		writeHash(h, e.Right)
+	case *NotExpression:
+		_, _ = h.Write([]byte{'N'})
+		writeHash(h, e.Expression)
+	case *ValuePath:
+		_, _ = h.Write([]byte{'V'})
+		writePath(e.AttributePath)
+		writeHash(h, e.ValueFilter)
+	default:
+		_, _ = h.Write([]byte{0})
+	}
+}
@@ -0,0 +1,220 @@
+package filter
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"testing"
+)
+
+func ExampleClone() {
+	expression, _ := ParseFilter([]byte("urn:ietf:params:scim:schemas:core:2.0:User:name.familyName eq \"Jensen\""))
+	clone := Clone(expression)
+	*clone.(*AttributeExpression).AttributePath.SubAttribute = "givenName"
+	fmt.Println(Format(expression))
+	fmt.Println(Format(clone))
+	// Output:
+	// urn:ietf:params:scim:schemas:core:2.0:User:name.familyName eq "Jensen"
+	// urn:ietf:params:scim:schemas:core:2.0:User:name.givenName eq "Jensen"
+}
+
+func ExampleEqual() {
+	a, _ := ParseFilter([]byte("userName eq \"bjensen\" and meta.version gt 1"))
+	b, _ := NewParser(UseNumber()).ParseFilter([]byte("USERNAME EQ \"bjensen\" AND Meta.Version GT 1.0"))
+	fmt.Println(Equal(a, b), Hash(a) == Hash(b))
+	// Output:
+	// true true
+}
+
+func TestClone(t *testing.T) {
+	for _, raw := range []string{
+		`urn:ietf:params:scim:schemas:core:2.0:User:name.familyName eq "Jensen"`,
+		`emails[type eq "work" and not (value ew "@example.com")] or id pr`,
+		`urn:ietf:params:scim:schemas:extension:enterprise:2.0:User:manager[value eq "x"]`,
+		`big gt 92233720368547758070 and score lt 0.5`,
+	} {
+		t.Run(raw, func(t *testing.T) {
+			expression, err := NewParser(TrackPositions(), UseRat()).ParseFilter([]byte(raw))
+			if err != nil {
+				t.Fatal(err)
+			}
+			clone := Clone(expression)
+			if !Equal(expression, clone) {
+				t.Errorf("expected %s, got %s", Format(expression), Format(clone))
+			}
+			// Every pointer in the clone differs from the original.
+			var originals []any
+			Walk(expression, func(expr Expression) bool {
+				originals = append(originals, pointers(expr)...)
+				return true
+			})
+			Walk(clone, func(expr Expression) bool {
+				for _, p := range pointers(expr) {
+					for _, o := range originals {
+						if p == o {
+							t.Errorf("clone shares %T with the original", p)
+						}
+					}
+				}
+				return true
+			})
+		})
+	}
+}
+
+func TestClonePath(t *testing.T) {
+	path, err := ParsePath([]byte(`urn:ietf:params:scim:schemas:core:2.0:Group:members[value eq "2819c223"].displayName`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	clone := ClonePath(path)
+	if clone.String() != path.String() {
+		t.Errorf("expected %s, got %s", path, clone)
+	}
+	if clone.SubAttribute == path.SubAttribute || clone.AttributePath.URIPrefix == path.AttributePath.URIPrefix {
+		t.Error("clone shares pointers with the original")
+	}
+	if clone.ValueExpression == path.ValueExpression {
+		t.Error("clone shares the value expression with the original")
+	}
+}
+
+func TestEqual(t *testing.T) {
+	for _, test := range []struct {
+		a, b  string
+		equal bool
+	}{
+		{a: `userName eq "bjensen"`, b: `userName eq "bjensen"`, equal: true},
+		{a: `userName eq "bjensen"`, b: `USERNAME EQ "bjensen"`, equal: true},
+		{a: `userName eq "bjensen"`, b: `userName eq "BJensen"`, equal: false},
+		{a: `userName eq "bjensen"`, b: `userName ne "bjensen"`, equal: false},
+		{a: `userName eq "bjensen"`, b: `userName eq null`, equal: false},
+		{a: `userName eq null`, b: `userName eq null`, equal: true},
+		{a: `userName pr`, b: `userName pr`, equal: true},
+		{a: `userName eq "1"`, b: `userName eq 1`, equal: false},
+		{a: `active eq true`, b: `active eq true`, equal: true},
+		{a: `active eq true`, b: `active eq false`, equal: false},
+		{a: `age eq 1`, b: `age eq 1.0`, equal: true},
+		{a: `age eq 1`, b: `age eq 1e0`, equal: true},
+		{a: `age eq 0.1`, b: `age eq 1e-1`, equal: true},
+		{a: `age eq 9007199254740993`, b: `age eq 9007199254740992`, equal: false},
+		{a: `age eq 92233720368547758070`, b: `age eq 92233720368547758070`, equal: true},
+		{a: `age eq 92233720368547758070`, b: `age eq 92233720368547758071`, equal: false},
+		{a: `name.givenName pr`, b: `Name.GivenName pr`, equal: true},
+		{a: `name.givenName pr`, b: `name pr`, equal: false},
+		{a: `urn:ietf:params:scim:schemas:core:2.0:User:name pr`, b: `URN:IETF:PARAMS:SCIM:SCHEMAS:CORE:2.0:USER:NAME pr`, equal: true},
+		{a: `urn:ietf:params:scim:schemas:core:2.0:User:name pr`, b: `name pr`, equal: false},
+		{a: `a pr and b pr`, b: `a pr AND b pr`, equal: true},
+		{a: `a pr and b pr`, b: `b pr and a pr`, equal: false},
+		{a: `a pr and b pr`, b: `a pr or b pr`, equal: false},
+		{a: `a pr and (b pr and c pr)`, b: `a pr and b pr and c pr`, equal: false},
+		{a: `not (a pr)`, b: `not (A pr)`, equal: true},
+		{a: `not (a pr)`, b: `a pr`, equal: false},
+		{a: `emails[type eq "work"]`, b: `EMAILS[TYPE eq "work"]`, equal: true},
+		{a: `emails[type eq "work"]`, b: `emails.type eq "work"`, equal: false},
+		{a: `emails[type eq "work"]`, b: `ims[type eq "work"]`, equal: false},
+	} {
+		t.Run(fmt.Sprintf("%s=%s", test.a, test.b), func(t *testing.T) {
+			for _, p := range []*Parser{NewParser(), NewParser(UseNumber()), NewParser(UseRat(), TrackPositions())} {
+				a, err := ParseFilter([]byte(test.a))
+				if err != nil {
+					t.Fatal(err)
+				}
+				b, err := p.ParseFilter([]byte(test.b))
+				if err != nil {
+					t.Fatal(err)
+				}
+				if Equal(a, b) != test.equal || Equal(b, a) != test.equal {
+					t.Errorf("expected %v", test.equal)
+				}
+				if test.equal && Hash(a) != Hash(b) {
+					t.Errorf("expected equal hashes, got %x and %x", Hash(a), Hash(b))
+				}
+				if !test.equal && Hash(a) == Hash(b) {
+					t.Errorf("expected different hashes, got %x", Hash(a))
+				}
+			}
+		})
+	}
+}
+
+func TestEqual_compareValues(t *testing.T) {
+	path := AttributePath{AttributeName: "age"}
+	for _, test := range []struct {
+		a, b  any
+		equal bool
+	}{
+		{a: 1, b: int64(1), equal: true},
+		{a: uint8(1), b: json.Number("1"), equal: true},
+		{a: 1.0, b: json.Number("1.0"), equal: true},
+		{a: big.NewInt(1), b: big.NewRat(2, 2), equal: true},
+		{a: 0.5, b: big.NewRat(1, 2), equal: true},
+		{a: 0.1, b: json.Number("0.1"), equal: true},
+		{a: 1, b: 1.5, equal: false},
+		{a: 1, b: "1", equal: false},
+		{a: 1, b: nil, equal: false},
+		{a: true, b: 1, equal: false},
+		{a: []string{"a"}, b: []string{"a"}, equal: true},
+	} {
+		t.Run(fmt.Sprintf("%T(%v)=%T(%v)", test.a, test.a, test.b, test.b), func(t *testing.T) {
+			a := &AttributeExpression{AttributePath: path, Operator: EQ, CompareValue: test.a}
+			b := &AttributeExpression{AttributePath: path, Operator: EQ, CompareValue: test.b}
+			if Equal(a, b) != test.equal {
+				t.Errorf("expected %v", test.equal)
+			}
+			if test.equal && Hash(a) != Hash(b) {
+				t.Errorf("expected equal hashes, got %x and %x", Hash(a), Hash(b))
+			}
+		})
+	}
+}
+
+func TestHash(t *testing.T) {
+	// The hash is stable across runs.
+	expression, err := ParseFilter([]byte(`userName eq "bjensen"`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if h := Hash(expression); h != Hash(Clone(expression)) {
+		t.Errorf("expected the hash of the clone to be %x", h)
+	}
+	if Hash(nil) == Hash(expression) {
+		t.Error("expected different hashes")
+	}
+
+	// Strings are prefixed by their length.
+	a := &AttributeExpression{AttributePath: AttributePath{AttributeName: "ab"}, Operator: "c"}
+	b := &AttributeExpression{AttributePath: AttributePath{AttributeName: "a"}, Operator: "bc"}
+	if Hash(a) == Hash(b) {
+		t.Error("expected different hashes")
+	}
+}
+
+// pointers returns the pointers of the given node that a clone should not
+// share.
+func pointers(expr Expression) []any {
+	var ps []any
+	add := func(path AttributePath) {
+		if path.URIPrefix != nil {
+			ps = append(ps, path.URIPrefix)
+		}
+		if path.SubAttribute != nil {
+			ps = append(ps, path.SubAttribute)
+		}
+	}
+	switch e := expr.(type) {
+	case *AttributeExpression:
+		ps = append(ps, e)
+		add(e.AttributePath)
+		switch v := e.CompareValue.(type) {
+		case *big.Int, *big.Rat:
+			ps = append(ps, v)
+		}
+	case *ValuePath:
+		ps = append(ps, e)
+		add(e.AttributePath)
+	default:
+		ps = append(ps, e)
+	}
+	return ps
+}
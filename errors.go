@@ -1,47 +1,123 @@
 package filter
 
 import (
+	"bytes"
 	"fmt"
 	"github.com/scim2/filter-parser/v2/internal/types"
+	"strings"
+	"unicode"
+	"unicode/utf8"
 )
 
-func invalidChildTypeError(parentTyp, invalidType int) error {
-	return &internalError{
-		Message: fmt.Sprintf(
-			"invalid child type for %s (%03d): %s (%03d)",
-			typ.Stringer[parentTyp], parentTyp,
-			typ.Stringer[invalidType], invalidType,
-		),
+func newParseError(input []byte, offset int, ruleType int, expected []string) *ParseError {
+	line, column := 1, 1
+	for i, r := range string(input[:offset]) {
+		switch {
+		case r == '\n', r == '\r' && (i+1 == len(input) || input[i+1] != '\n'):
+			line++
+			column = 1
+		case r != '\r':
+			column++
+		}
 	}
+	return &ParseError{
+		Input:    input,
+		Offset:   offset,
+		Line:     line,
+		Column:   column,
+		Rule:     typ.Stringer[ruleType],
+		Expected: expected,
+	}
+}
+
+// LimitError is returned if the raw data exceeds one of the limits of the
+// parser. The raw data is not parsed any further.
+type LimitError struct {
+	// Limit is the limit that was exceeded.
+	Limit Limit
+	// Max is the value of the limit.
+	Max int
+	// Offset is the byte offset within the input at which the limit was
+	// exceeded.
+	Offset int
+}
+
+func (e *LimitError) Error() string {
+	return fmt.Sprintf("limit exceeded at offset %d: %s exceeds the maximum of %d", e.Offset, e.Limit, e.Max)
+}
+
+// ScimType returns the SCIM detail error keyword of the error. Filters that
+// are too long or too complex result in "tooMany", string literals that are too
+// long in "invalidFilter".
+//
+// More info: https://tools.ietf.org/html/rfc7644#section-3.12
+func (e *LimitError) ScimType() string {
+	if e.Limit == StringLengthLimit {
+		return "invalidFilter"
+	}
+	return "tooMany"
+}
+
+// ParseError is returned if the given raw data does not conform to the grammar.
+type ParseError struct {
+	// Input is the raw data that was parsed.
+	Input []byte
+	// Offset is the byte offset within the input at which parsing failed.
+	Offset int
+	// Line and Column are the one-based position of the offset. The column is
+	// counted in runes.
+	Line, Column int
+	// Rule is the name of the grammar rule that was being parsed.
+	Rule string
+	// Expected contains the tokens and rules that were valid at the offset.
+	Expected []string
 }
 
-func invalidLengthError(parentTyp int, len, actual int) error {
-	return &internalError{
-		Message: fmt.Sprintf(
-			"length was not equal to %d for %s (%03d), got %d elements",
-			len, typ.Stringer[parentTyp], parentTyp, actual,
-		),
+// Diagnostic returns the line of the input that contains the error, followed by
+// a caret that points at the offset.
+//
+// Example:
+//
+//	userName eq "x" adn title pr
+//	                ^
+func (e *ParseError) Diagnostic() string {
+	start := bytes.LastIndexAny(e.Input[:e.Offset], "\r\n") + 1
+	end := len(e.Input)
+	if i := bytes.IndexAny(e.Input[e.Offset:], "\r\n"); i != -1 {
+		end = e.Offset + i
 	}
+	padding := utf8.RuneCount(e.Input[start:e.Offset])
+	return fmt.Sprintf("%s\n%s^", e.Input[start:end], strings.Repeat(" ", padding))
 }
 
-func invalidTypeError(expected, actual int) error {
-	return &internalError{
-		Message: fmt.Sprintf(
-			"invalid type: expected %s (%03d), actual %s (%03d)",
-			typ.Stringer[expected], expected,
-			typ.Stringer[actual], actual,
-		),
+func (e *ParseError) Error() string {
+	expected := "a valid " + e.Rule
+	if l := len(e.Expected); l != 0 {
+		expected = strings.Join(e.Expected[:l-1], ", ")
+		if l > 1 {
+			expected += " or "
+		}
+		expected += e.Expected[l-1]
 	}
+	return fmt.Sprintf(
+		"parse error at %d:%d in %s: expected %s, got %s",
+		e.Line, e.Column, e.Rule, expected, e.got(),
+	)
 }
 
-func missingValueError(parentTyp int, valueType int) error {
-	return &internalError{
-		Message: fmt.Sprintf(
-			"missing a required value for %s (%03d): %s (%03d)",
-			typ.Stringer[parentTyp], parentTyp,
-			typ.Stringer[valueType], valueType,
-		),
+// got returns a description of the input at the offset.
+func (e *ParseError) got() string {
+	rest := e.Input[e.Offset:]
+	if len(rest) == 0 {
+		return "end of input"
+	}
+	if i := bytes.IndexFunc(rest, unicode.IsSpace); i != -1 {
+		rest = rest[:max(i, 1)]
+	}
+	if utf8.RuneCount(rest) > 16 {
+		rest = []byte(string([]rune(string(rest))[:16]) + "...")
 	}
+	return fmt.Sprintf("%q", rest)
 }
 
 // internalError represents an internal error. If this error should NEVER occur.
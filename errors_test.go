@@ -0,0 +1,120 @@
+package filter
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"testing"
+)
+
+func ExampleParseError() {
+	_, err := ParseFilter([]byte("userName eq \"x\" adn title pr"))
+	var parseErr *ParseError
+	if errors.As(err, &parseErr) {
+		fmt.Println(parseErr)
+		fmt.Println(parseErr.Diagnostic())
+	}
+	// Output:
+	// parse error at 1:17 in FilterAnd: expected "and" or "or", got "adn"
+	// userName eq "x" adn title pr
+	//                 ^
+}
+
+func TestParseError(t *testing.T) {
+	for _, test := range []struct {
+		parse    func([]byte) error
+		input    string
+		offset   int
+		rule     string
+		expected []string
+	}{
+		{
+			parse:    parseFilterError,
+			input:    "userName eq",
+			offset:   11,
+			rule:     "AttrExp",
+			expected: []string{"SP"},
+		},
+		{
+			parse:    parseFilterError,
+			input:    "userName eq @",
+			offset:   12,
+			rule:     "AttrExp",
+			expected: []string{"False", "Null", "True", "Number", "String"},
+		},
+		{
+			parse:    parseFilterError,
+			input:    "(title pr",
+			offset:   9,
+			rule:     "FilterAnd",
+			expected: []string{"\")\""},
+		},
+		{
+			parse:    parseFilterError,
+			input:    "",
+			offset:   0,
+			rule:     "FilterOr",
+			expected: []string{"FilterOr"},
+		},
+		{
+			parse:    parseFilterError,
+			input:    "title pr\nadn userType pr",
+			offset:   8,
+			rule:     "FilterAnd",
+			expected: []string{"end of input"},
+		},
+		{
+			parse:    parseFilterError,
+			input:    `a eq "\u00zz"`,
+			offset:   10,
+			rule:     "String",
+			expected: []string{"a hex digit"},
+		},
+		{
+			parse:    parseFilterError,
+			input:    `a eq "\x"`,
+			offset:   7,
+			rule:     "String",
+			expected: []string{`"\""`, `"\\"`, `"/"`, `"b"`, `"f"`, `"n"`, `"r"`, `"t"`, `"u"`},
+		},
+		{
+			parse: func(raw []byte) error {
+				_, err := ParsePath(raw)
+				return err
+			},
+			input:    "members[value eq \"x\"].",
+			offset:   22,
+			rule:     "Path",
+			expected: []string{"AttrName"},
+		},
+	} {
+		t.Run(test.input, func(t *testing.T) {
+			err := test.parse([]byte(test.input))
+			var parseErr *ParseError
+			if !errors.As(err, &parseErr) {
+				t.Fatalf("expected a parse error, got %v", err)
+			}
+			if parseErr.Offset != test.offset {
+				t.Errorf("expected offset %d, got %d", test.offset, parseErr.Offset)
+			}
+			if parseErr.Rule != test.rule {
+				t.Errorf("expected rule %s, got %s", test.rule, parseErr.Rule)
+			}
+			if !slices.Equal(parseErr.Expected, test.expected) {
+				t.Errorf("expected %v, got %v", test.expected, parseErr.Expected)
+			}
+		})
+	}
+}
+
+func parseFilterError(raw []byte) error {
+	_, err := ParseFilter(raw)
+	return err
+}
+
+func TestParseError_Error(t *testing.T) {
+	_, err := ParseFilter([]byte(`a eq "\u00zz"`))
+	if expected := `parse error at 1:11 in String: expected a hex digit, got "zz\""`; err == nil || err.Error() != expected {
+		t.Errorf("expected %s, got %v", expected, err)
+	}
+}
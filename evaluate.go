@@ -0,0 +1,504 @@
+package filter
+
+import (
+	"cmp"
+	"encoding/json"
+	"fmt"
+	"math"
+	"math/big"
+	"reflect"
+	"strings"
+	"time"
+)
+
+// Evaluate reports whether the given resource matches the expression. It
+// follows the semantics of RFC 7644, Section 3.4.2.2:
+//   - attribute names are case-insensitive,
+//   - an attribute path with a URI prefix is resolved within the extension
+//     object of that URI, or within the resource itself if no such object is
+//     present (e.g. for the core schema),
+//   - an expression on a multi-valued attribute matches if any of its values
+//     matches.
+//
+// Strings are compared case-insensitively, the default for attributes that are
+// not "caseExact". Strings that are both valid RFC 3339 timestamps are
+// compared chronologically by the ordering operators. The 'ne' operator is the
+// negation of 'eq', so it also matches unassigned attributes. Integers and big
+// numbers (*big.Int, *big.Rat) are compared exactly, other numbers as float64.
+//
+// An error is returned for unknown operators, ordering operators on boolean
+// values and unsupported compare values.
+//
+// More info: https://tools.ietf.org/html/rfc7644#section-3.4.2.2
+func Evaluate(expr Expression, resource map[string]any) (bool, error) {
+	return evaluate(expr, resource)
+}
+
+// compare compares a single attribute value with the compare value of an
+// attribute expression. The 'pr' operator is not handled by compare.
+func compare(op CompareOperator, value, compareValue any) (bool, error) {
+	switch op {
+	case EQ, NE, CO, SW, EW, GT, GE, LT, LE:
+	default:
+		return false, fmt.Errorf("invalid compare operator: %q", op)
+	}
+	if _, ok := value.(bool); ok && isOrdering(op) {
+		return false, fmt.Errorf("operator %q is not supported on boolean attributes", op)
+	}
+
+	switch cv := compareValue.(type) {
+	case bool:
+		if isOrdering(op) {
+			return false, fmt.Errorf("operator %q is not supported on boolean values", op)
+		}
+		v, ok := value.(bool)
+		if !ok {
+			return false, nil
+		}
+		switch op {
+		case EQ:
+			return v == cv, nil
+		case NE:
+			return v != cv, nil
+		}
+	case string:
+		v, ok := value.(string)
+		if !ok {
+			return false, nil
+		}
+		switch op {
+		case EQ:
+			return strings.EqualFold(v, cv), nil
+		case NE:
+			return !strings.EqualFold(v, cv), nil
+		case CO:
+			return strings.Contains(strings.ToLower(v), strings.ToLower(cv)), nil
+		case SW:
+			return strings.HasPrefix(strings.ToLower(v), strings.ToLower(cv)), nil
+		case EW:
+			return strings.HasSuffix(strings.ToLower(v), strings.ToLower(cv)), nil
+		case GT, GE, LT, LE:
+			return order(op, compareStrings(v, cv, false)), nil
+		}
+	default:
+		if _, ok := toFloat(compareValue); !ok {
+			return false, fmt.Errorf("invalid compare value: %v (%T)", compareValue, compareValue)
+		}
+		c, ok := compareNumbers(value, compareValue)
+		if !ok {
+			return false, nil
+		}
+		switch op {
+		case EQ:
+			return c == 0, nil
+		case NE:
+			return c != 0, nil
+		case CO, SW, EW:
+			return false, nil
+		case GT, GE, LT, LE:
+			return order(op, c), nil
+		}
+	}
+	return false, nil
+}
+
+// compareNumbers compares two numeric values. Integers are compared exactly, as
+// are values of which one is a *big.Int or *big.Rat. Other values are compared
+// as float64. Returns false if one of the values is not a number or NaN.
+func compareNumbers(a, b any) (int, bool) {
+	if x, ok := toInt64(a); ok {
+		if y, ok := toInt64(b); ok {
+			return cmp.Compare(x, y), true
+		}
+	}
+	if isBig(a) || isBig(b) {
+		x, ok := toRat(a)
+		if !ok {
+			return 0, false
+		}
+		y, ok := toRat(b)
+		if !ok {
+			return 0, false
+		}
+		return x.Cmp(y), true
+	}
+	x, ok := toFloat(a)
+	if !ok || math.IsNaN(x) {
+		return 0, false
+	}
+	y, ok := toFloat(b)
+	if !ok || math.IsNaN(y) {
+		return 0, false
+	}
+	return cmp.Compare(x, y), true
+}
+
+// compareStrings compares two strings chronologically if both are valid
+// RFC 3339 timestamps, lexicographically otherwise.
+func compareStrings(a, b string, caseExact bool) int {
+	if ta, err := time.Parse(time.RFC3339Nano, a); err == nil {
+		if tb, err := time.Parse(time.RFC3339Nano, b); err == nil {
+			return ta.Compare(tb)
+		}
+	}
+	if caseExact {
+		return strings.Compare(a, b)
+	}
+	return strings.Compare(strings.ToLower(a), strings.ToLower(b))
+}
+
+// evaluate evaluates the given expression against a resource, which is either
+// a map or a struct.
+func evaluate(expr Expression, resource any) (bool, error) {
+	switch e := expr.(type) {
+	case *AttributeExpression:
+		return evaluateAttrExp(e, resource)
+	case *LogicalExpression:
+		left, err := evaluate(e.Left, resource)
+		if err != nil {
+			return false, err
+		}
+		switch LogicalOperator(strings.ToLower(string(e.Operator))) {
+		case AND:
+			if !left {
+				return false, nil
+			}
+		case OR:
+			if left {
+				return true, nil
+			}
+		default:
+			return false, fmt.Errorf("invalid logical operator: %q", e.Operator)
+		}
+		return evaluate(e.Right, resource)
+	case *NotExpression:
+		ok, err := evaluate(e.Expression, resource)
+		if err != nil {
+			return false, err
+		}
+		return !ok, nil
+	case *ValuePath:
+		for _, value := range lookup(e.AttributePath, resource) {
+			if !isComplex(value) {
+				continue
+			}
+			ok, err := evaluate(e.ValueFilter, value)
+			if err != nil {
+				return false, err
+			}
+			if ok {
+				return true, nil
+			}
+		}
+		return false, nil
+	default:
+		return false, fmt.Errorf("invalid expression type: %T", expr)
+	}
+}
+
+func evaluateAttrExp(e *AttributeExpression, resource any) (bool, error) {
+	op := CompareOperator(strings.ToLower(string(e.Operator)))
+	values := lookup(e.AttributePath, resource)
+	switch {
+	case op == PR:
+		for _, value := range values {
+			if present(value) {
+				return true, nil
+			}
+		}
+		return false, nil
+	case e.CompareValue == nil:
+		// Comparing to null is the same as checking whether the attribute is
+		// (not) present.
+		switch op {
+		case EQ, NE:
+			var ok bool
+			for _, value := range values {
+				ok = ok || present(value)
+			}
+			return ok == (op == NE), nil
+		default:
+			return false, fmt.Errorf("operator %q is not supported on null", op)
+		}
+	case op == NE:
+		ok, err := evaluateAttrExp(&AttributeExpression{
+			AttributePath: e.AttributePath,
+			Operator:      EQ,
+			CompareValue:  e.CompareValue,
+		}, resource)
+		return !ok, err
+	}
+
+	if len(values) == 0 {
+		// Still validate the operator and compare value.
+		_, err := compare(op, nil, e.CompareValue)
+		return false, err
+	}
+	for _, value := range values {
+		ok, err := compare(op, value, e.CompareValue)
+		if err != nil {
+			return false, err
+		}
+		if ok {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// field returns the value of the given attribute name of a complex value, which
+// is either a map or a struct. Returns nil for other values.
+func field(value any, name string) any {
+	if complexValue, ok := value.(map[string]any); ok {
+		return get(complexValue, name)
+	}
+	if rv := reflect.ValueOf(value); rv.Kind() == reflect.Struct {
+		return typeOf(rv.Type()).get(rv, name)
+	}
+	return nil
+}
+
+// flatten returns the values of a (multi-valued) attribute.
+func flatten(value any) []any {
+	switch v := value.(type) {
+	case nil:
+		return nil
+	case []any:
+		return v
+	case []map[string]any:
+		values := make([]any, len(v))
+		for i, v := range v {
+			values[i] = v
+		}
+		return values
+	case string, []byte:
+		return []any{v}
+	}
+	if rv := reflect.ValueOf(value); rv.Kind() == reflect.Slice {
+		values := make([]any, rv.Len())
+		for i := range values {
+			values[i] = rv.Index(i).Interface()
+		}
+		return values
+	}
+	return []any{value}
+}
+
+// get returns the value of the given attribute name. Attribute names are case
+// insensitive.
+func get(resource map[string]any, name string) any {
+	if value, ok := resource[name]; ok {
+		return value
+	}
+	for k, value := range resource {
+		if strings.EqualFold(k, name) {
+			return value
+		}
+	}
+	return nil
+}
+
+// isBig checks whether the given value is a *big.Int or *big.Rat.
+func isBig(value any) bool {
+	switch value.(type) {
+	case *big.Int, *big.Rat:
+		return true
+	default:
+		return false
+	}
+}
+
+// isComplex checks whether the given value is a complex value, i.e. a map or a
+// struct.
+func isComplex(value any) bool {
+	if _, ok := value.(map[string]any); ok {
+		return true
+	}
+	return reflect.ValueOf(value).Kind() == reflect.Struct
+}
+
+// isOrdering checks whether the given operator is one of 'gt', 'ge', 'lt' or
+// 'le'.
+func isOrdering(op CompareOperator) bool {
+	switch op {
+	case GT, GE, LT, LE:
+		return true
+	default:
+		return false
+	}
+}
+
+// lookup resolves the attribute path within the given resource and returns all
+// the values it refers to. The values of multi-valued attributes are
+// flattened.
+func lookup(path AttributePath, resource any) []any {
+	if path.URIPrefix != nil {
+		if extension := field(resource, *path.URIPrefix); isComplex(extension) {
+			resource = extension
+		}
+	}
+	values := flatten(field(resource, path.AttributeName))
+	if path.SubAttribute == nil {
+		return values
+	}
+
+	var subValues []any
+	for _, value := range values {
+		subValues = append(subValues, flatten(field(value, *path.SubAttribute))...)
+	}
+	return subValues
+}
+
+// order interprets the result of a comparison for the given ordering operator.
+func order(op CompareOperator, cmp int) bool {
+	switch op {
+	case GT:
+		return cmp > 0
+	case GE:
+		return cmp >= 0
+	case LT:
+		return cmp < 0
+	case LE:
+		return cmp <= 0
+	default:
+		return false
+	}
+}
+
+// present checks whether the given value is non-empty. Complex and multi-valued
+// attributes are present if they contain a non-empty value.
+func present(value any) bool {
+	switch v := value.(type) {
+	case nil:
+		return false
+	case string:
+		return v != ""
+	case []byte:
+		return len(v) != 0
+	case map[string]any:
+		for _, v := range v {
+			if present(v) {
+				return true
+			}
+		}
+		return false
+	}
+	switch rv := reflect.ValueOf(value); rv.Kind() {
+	case reflect.Slice:
+		for i := range rv.Len() {
+			if present(rv.Index(i).Interface()) {
+				return true
+			}
+		}
+		return false
+	case reflect.Struct:
+		st := typeOf(rv.Type())
+		for _, f := range st.fields {
+			if present(f.value(rv)) {
+				return true
+			}
+		}
+		return false
+	}
+	return true
+}
+
+// toFloat converts the given numeric value to a float64.
+func toFloat(value any) (float64, bool) {
+	switch v := value.(type) {
+	case int:
+		return float64(v), true
+	case int8:
+		return float64(v), true
+	case int16:
+		return float64(v), true
+	case int32:
+		return float64(v), true
+	case int64:
+		return float64(v), true
+	case uint:
+		return float64(v), true
+	case uint8:
+		return float64(v), true
+	case uint16:
+		return float64(v), true
+	case uint32:
+		return float64(v), true
+	case uint64:
+		return float64(v), true
+	case float32:
+		return float64(v), true
+	case float64:
+		return v, true
+	case json.Number:
+		f, err := v.Float64()
+		return f, err == nil
+	case *big.Int:
+		f, _ := new(big.Float).SetInt(v).Float64()
+		return f, true
+	case *big.Rat:
+		f, _ := v.Float64()
+		return f, true
+	default:
+		return 0, false
+	}
+}
+
+// toInt64 converts the given integer value to an int64, if it fits.
+func toInt64(value any) (int64, bool) {
+	switch v := value.(type) {
+	case int:
+		return int64(v), true
+	case int8:
+		return int64(v), true
+	case int16:
+		return int64(v), true
+	case int32:
+		return int64(v), true
+	case int64:
+		return v, true
+	case uint:
+		return int64(v), v <= math.MaxInt64
+	case uint8:
+		return int64(v), true
+	case uint16:
+		return int64(v), true
+	case uint32:
+		return int64(v), true
+	case uint64:
+		return int64(v), v <= math.MaxInt64
+	case json.Number:
+		i, err := v.Int64()
+		return i, err == nil
+	case *big.Int:
+		return v.Int64(), v.IsInt64()
+	default:
+		return 0, false
+	}
+}
+
+// toRat converts the given numeric value to a *big.Rat.
+func toRat(value any) (*big.Rat, bool) {
+	switch v := value.(type) {
+	case *big.Rat:
+		return v, true
+	case *big.Int:
+		return new(big.Rat).SetInt(v), true
+	case json.Number:
+		return new(big.Rat).SetString(string(v))
+	}
+	if i, ok := toInt64(value); ok {
+		return new(big.Rat).SetInt64(i), true
+	}
+	if u, ok := value.(uint64); ok {
+		return new(big.Rat).SetUint64(u), true
+	}
+	if u, ok := value.(uint); ok {
+		return new(big.Rat).SetUint64(uint64(u)), true
+	}
+	f, ok := toFloat(value)
+	if !ok || math.IsNaN(f) || math.IsInf(f, 0) {
+		return nil, false
+	}
+	return new(big.Rat).SetFloat64(f), true
+}
@@ -0,0 +1,236 @@
+package filter
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// numbersResource is the resource evaluateNumberTests are evaluated against.
+const numbersResource = `{"id": 9007199254740993, "score": 0.1, "big": 92233720368547758070}`
+
+const testUser = `{
+	"schemas": [
+		"urn:ietf:params:scim:schemas:core:2.0:User",
+		"urn:ietf:params:scim:schemas:extension:enterprise:2.0:User"
+	],
+	"id": "2819c223-7f76-453a-919d-413861904646",
+	"userName": "bjensen",
+	"name": {
+		"familyName": "O'Malley",
+		"givenName": "Barbara"
+	},
+	"title": "Tour Guide",
+	"userType": "Employee",
+	"active": true,
+	"emails": [
+		{
+			"value": "bjensen@example.com",
+			"type": "work",
+			"primary": true
+		},
+		{
+			"value": "babs@jensen.org",
+			"type": "home"
+		}
+	],
+	"meta": {
+		"lastModified": "2011-05-13T04:42:34Z"
+	},
+	"urn:ietf:params:scim:schemas:extension:enterprise:2.0:User": {
+		"employeeNumber": "701984",
+		"costCenter": 4130,
+		"manager": {
+			"value": "26118915-6090-4610-87e4-49d8ca9f808d"
+		}
+	}
+}`
+
+var evaluateTests = []struct {
+	filter string
+	match  bool
+}{
+	{"userName eq \"bjensen\"", true},
+	{"USERNAME eq \"BJENSEN\"", true},
+	{"userName ne \"bjensen\"", false},
+	{"userName co \"jen\"", true},
+	{"userName sw \"bj\"", true},
+	{"userName ew \"sen\"", true},
+	{"userName sw \"jen\"", false},
+	{"userName gt \"a\"", true},
+	{"userName lt \"a\"", false},
+	{"name.familyName co \"O'Malley\"", true},
+	{"name.middleName pr", false},
+	{"title pr", true},
+	{"nickName pr", false},
+	{"nickName ne \"x\"", true},
+	{"nickName eq null", true},
+	{"title eq null", false},
+	{"title ne null", true},
+	{"active eq true", true},
+	{"active eq false", false},
+	{"active ne false", true},
+	{"emails.type eq \"home\"", true},
+	{"emails.type eq \"other\"", false},
+	{"emails.value co \"jensen.org\"", true},
+	{"emails pr", true},
+	{"emails[type eq \"work\" and primary eq true]", true},
+	{"emails[type eq \"home\" and primary eq true]", false},
+	{"emails[not (type eq \"work\")]", true},
+	{"meta.lastModified gt \"2011-05-13T04:42:34Z\"", false},
+	{"meta.lastModified ge \"2011-05-13T04:42:34Z\"", true},
+	{"meta.lastModified lt \"2011-05-13T06:42:34+02:00\"", false},
+	{"meta.lastModified gt \"2011-05-13T06:42:34+03:00\"", true},
+	{"urn:ietf:params:scim:schemas:extension:enterprise:2.0:User:employeeNumber eq \"701984\"", true},
+	{"urn:ietf:params:scim:schemas:extension:enterprise:2.0:User:costCenter ge 4130", true},
+	{"urn:ietf:params:scim:schemas:extension:enterprise:2.0:User:costCenter gt 4130", false},
+	{"urn:ietf:params:scim:schemas:extension:enterprise:2.0:User:costCenter lt 5e3", true},
+	{"urn:ietf:params:scim:schemas:extension:enterprise:2.0:User:manager.value pr", true},
+	{"urn:ietf:params:scim:schemas:core:2.0:User:userName eq \"bjensen\"", true},
+	{"schemas eq \"urn:ietf:params:scim:schemas:extension:enterprise:2.0:User\"", true},
+	{"title pr and userType eq \"Employee\"", true},
+	{"title pr and userType eq \"Intern\"", false},
+	{"title pr or userType eq \"Intern\"", true},
+	{"userType eq \"Employee\" and not (emails co \"example.com\" or emails.value co \"example.org\")", true},
+	{"userType eq \"Employee\" and (emails.value co \"example.com\" or emails.value co \"example.org\")", true},
+	{"not (userName eq \"bjensen\")", false},
+}
+
+var evaluateNumberTests = []struct {
+	filter string
+	match  bool
+}{
+	{"id eq 9007199254740993", true},
+	{"id eq 9007199254740992", false},
+	{"id gt 9007199254740992", true},
+	{"id lt 9007199254740994", true},
+	{"score eq 0.1", true},
+	{"score lt 0.11", true},
+	{"score gt 0.1", false},
+	{"big eq 92233720368547758070", true},
+	{"big gt 92233720368547758069", true},
+	{"big gt 9223372036854775807", true},
+	{"big lt 1e20", true},
+}
+
+func ExampleEvaluate() {
+	var resource map[string]any
+	_ = json.Unmarshal([]byte(`{"userName": "bjensen", "emails": [{"type": "work", "value": "bjensen@example.com"}]}`), &resource)
+
+	expression, _ := ParseFilter([]byte("userName eq \"BJensen\" and emails[type eq \"work\" and value ew \"@example.com\"]"))
+	fmt.Println(Evaluate(expression, resource))
+	// Output:
+	// true <nil>
+}
+
+func TestEvaluate(t *testing.T) {
+	var resource map[string]any
+	if err := json.Unmarshal([]byte(testUser), &resource); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, test := range evaluateTests {
+		t.Run(test.filter, func(t *testing.T) {
+			expression, err := ParseFilterNumber([]byte(test.filter))
+			if err != nil {
+				t.Fatal(err)
+			}
+			match, err := Evaluate(expression, resource)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if match != test.match {
+				t.Errorf("expected %v, got %v", test.match, match)
+			}
+		})
+	}
+}
+
+func TestEvaluate_logicalOperators(t *testing.T) {
+	resource := map[string]any{
+		"active": true,
+	}
+	active := &AttributeExpression{AttributePath: AttributePath{AttributeName: "active"}, Operator: PR}
+	inactive := &AttributeExpression{AttributePath: AttributePath{AttributeName: "title"}, Operator: PR}
+	for _, test := range []struct {
+		expression Expression
+		match      bool
+	}{
+		{&LogicalExpression{Left: active, Right: inactive, Operator: "AND"}, false},
+		{&LogicalExpression{Left: active, Right: active, Operator: "And"}, true},
+		{&LogicalExpression{Left: inactive, Right: active, Operator: "OR"}, true},
+		{&LogicalExpression{Left: inactive, Right: inactive, Operator: "Or"}, false},
+	} {
+		t.Run(fmt.Sprint(test.expression), func(t *testing.T) {
+			match, err := Evaluate(test.expression, resource)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if match != test.match {
+				t.Errorf("expected %v, got %v", test.match, match)
+			}
+		})
+	}
+}
+
+func TestEvaluate_numbers(t *testing.T) {
+	decoder := json.NewDecoder(strings.NewReader(numbersResource))
+	decoder.UseNumber()
+	var resource map[string]any
+	if err := decoder.Decode(&resource); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, test := range evaluateNumberTests {
+		t.Run(test.filter, func(t *testing.T) {
+			for _, p := range []*Parser{NewParser(), NewParser(UseRat())} {
+				expression, err := p.ParseFilter([]byte(test.filter))
+				if err != nil {
+					t.Fatal(err)
+				}
+				match, err := Evaluate(expression, resource)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if match != test.match {
+					t.Errorf("expected %v, got %v", test.match, match)
+				}
+			}
+		})
+	}
+}
+
+func TestEvaluate_invalid(t *testing.T) {
+	resource := map[string]any{
+		"active": true,
+	}
+	for _, expression := range []Expression{
+		&AttributeExpression{
+			AttributePath: AttributePath{AttributeName: "active"},
+			Operator:      GT,
+			CompareValue:  false,
+		},
+		&AttributeExpression{
+			AttributePath: AttributePath{AttributeName: "active"},
+			Operator:      "xx",
+			CompareValue:  "x",
+		},
+		&AttributeExpression{
+			AttributePath: AttributePath{AttributeName: "active"},
+			Operator:      GT,
+			CompareValue:  nil,
+		},
+		&LogicalExpression{
+			Left:     &AttributeExpression{AttributePath: AttributePath{AttributeName: "active"}, Operator: PR},
+			Right:    &AttributeExpression{AttributePath: AttributePath{AttributeName: "active"}, Operator: PR},
+			Operator: "xor",
+		},
+	} {
+		t.Run(fmt.Sprint(expression), func(t *testing.T) {
+			if _, err := Evaluate(expression, resource); err == nil {
+				t.Error("expected an error")
+			}
+		})
+	}
+}
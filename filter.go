@@ -1,9 +1,6 @@
 package filter
 
 import (
-	"github.com/di-wu/parser"
-	"github.com/di-wu/parser/ast"
-	"github.com/scim2/filter-parser/v2/internal/grammar"
 	"github.com/scim2/filter-parser/v2/internal/types"
 )
 
@@ -13,137 +10,142 @@ func ParseFilter(raw []byte) (Expression, error) {
 }
 
 // ParseFilterNumber parses the given raw data as an Expression with json.Number.
+//
+// Deprecated: Use NewParser(UseNumber()).ParseFilter instead.
 func ParseFilterNumber(raw []byte) (Expression, error) {
 	return parseFilter(raw, config{useNumber: true})
 }
 
 func parseFilter(raw []byte, c config) (Expression, error) {
-	p, err := ast.New(raw)
-	if err != nil {
-		return nil, err
-	}
-	node, err := grammar.Filter(p)
-	if err != nil {
-		return nil, err
+	return parse(raw, c, typ.FilterOr, (*parser).filterOr)
+}
+
+// filterAnd parses one or more filter values separated by 'and'.
+func (p *parser) filterAnd() (Expression, bool) {
+	f := p.push(typ.FilterAnd)
+	begin := p.pos
+	exp, ok := p.filterValue()
+	if !ok {
+		p.pop(f, false)
+		return nil, false
 	}
-	if _, err := p.Expect(parser.EOD); err != nil {
-		return nil, err
+	for {
+		start := p.pos
+		if !p.spaces(1) || !p.keyword("and") || !p.spaces(1) {
+			p.pos = start
+			break
+		}
+		right, ok := p.filterValue()
+		if !ok {
+			p.pos = start
+			break
+		}
+		logExp := LogicalExpression{
+			Left:     exp,
+			Right:    right,
+			Operator: AND,
+		}
+		if p.positions {
+			logExp.Span = Span{Start: begin, End: p.pos}
+		}
+		exp = &logExp
 	}
-	return c.parseFilterOr(node)
+	p.pop(f, true)
+	return exp, true
 }
 
-func (p config) parseFilterAnd(node *ast.Node) (Expression, error) {
-	if node.Type != typ.FilterAnd {
-		return nil, invalidTypeError(typ.FilterAnd, node.Type)
+func (p *parser) filterNot() (Expression, bool) {
+	f := p.push(typ.FilterNot)
+	begin := p.pos
+	if !p.keyword("not") {
+		p.pop(f, false)
+		return nil, false
 	}
-
-	children := node.Children()
-	if len(children) == 0 {
-		return nil, invalidLengthError(typ.FilterAnd, 1, 0)
+	p.notSpaces()
+	exp, ok := p.filterParentheses()
+	p.pop(f, ok)
+	if !ok {
+		return nil, false
 	}
-
-	if len(children) == 1 {
-		return p.parseFilterValue(children[0])
+	notExp := NotExpression{
+		Expression: exp,
 	}
+	if p.positions {
+		notExp.Span = Span{Start: begin, End: p.pos}
+	}
+	return &notExp, true
+}
 
-	var and LogicalExpression
-	for _, node := range children {
-		exp, err := p.parseFilterValue(node)
-		if err != nil {
-			return nil, err
+// filterOr parses one or more filterAnd separated by 'or'.
+func (p *parser) filterOr() (Expression, bool) {
+	f := p.push(typ.FilterOr)
+	begin := p.pos
+	exp, ok := p.filterAnd()
+	if !ok {
+		p.pop(f, false)
+		return nil, false
+	}
+	for {
+		start := p.pos
+		if !p.spaces(1) || !p.keyword("or") || !p.spaces(1) {
+			p.pos = start
+			break
+		}
+		right, ok := p.filterAnd()
+		if !ok {
+			p.pos = start
+			break
+		}
+		logExp := LogicalExpression{
+			Left:     exp,
+			Right:    right,
+			Operator: OR,
 		}
-		switch {
-		case and.Left == nil:
-			and.Left = exp
-		case and.Right == nil:
-			and.Right = exp
-			and.Operator = AND
-		default:
-			and = LogicalExpression{
-				Left: &LogicalExpression{
-					Left:     and.Left,
-					Right:    and.Right,
-					Operator: AND,
-				},
-				Right:    exp,
-				Operator: AND,
-			}
+		if p.positions {
+			logExp.Span = Span{Start: begin, End: p.pos}
 		}
+		exp = &logExp
 	}
-	return &and, nil
+	p.pop(f, true)
+	return exp, true
 }
 
-func (p config) parseFilterOr(node *ast.Node) (Expression, error) {
-	if node.Type != typ.FilterOr {
-		return nil, invalidTypeError(typ.FilterOr, node.Type)
+func (p *parser) filterParentheses() (Expression, bool) {
+	if !p.char('(') {
+		return nil, false
 	}
-
-	children := node.Children()
-	if len(children) == 0 {
-		return nil, invalidLengthError(typ.FilterOr, 1, 0)
+	defer p.leave()
+	if !p.enter() {
+		return nil, false
 	}
-
-	if len(children) == 1 {
-		return p.parseFilterAnd(children[0])
+	p.spaces(0)
+	exp, ok := p.filterOr()
+	if !ok {
+		return nil, false
 	}
-
-	var or LogicalExpression
-	for _, node := range children {
-		exp, err := p.parseFilterAnd(node)
-		if err != nil {
-			return nil, err
-		}
-		switch {
-		case or.Left == nil:
-			or.Left = exp
-		case or.Right == nil:
-			or.Right = exp
-			or.Operator = OR
-		default:
-			or = LogicalExpression{
-				Left: &LogicalExpression{
-					Left:     or.Left,
-					Right:    or.Right,
-					Operator: OR,
-				},
-				Right:    exp,
-				Operator: OR,
-			}
-		}
+	p.spaces(0)
+	if !p.char(')') {
+		return nil, false
 	}
-	return &or, nil
+	return exp, true
 }
 
-func (p config) parseFilterValue(node *ast.Node) (Expression, error) {
-	switch t := node.Type; t {
-	case typ.ValuePath:
-		valuePath, err := p.parseValuePath(node)
-		if err != nil {
-			return nil, err
-		}
-		return &valuePath, nil
-	case typ.AttrExp:
-		attrExp, err := p.parseAttrExp(node)
-		if err != nil {
-			return nil, err
-		}
-		return &attrExp, nil
-	case typ.FilterNot:
-		children := node.Children()
-		if l := len(children); l != 1 {
-			return nil, invalidLengthError(typ.FilterNot, 1, l)
-		}
-
-		exp, err := p.parseFilterOr(children[0])
-		if err != nil {
-			return nil, err
-		}
-		return &NotExpression{
-			Expression: exp,
-		}, nil
-	case typ.FilterOr:
-		return p.parseFilterOr(node)
-	default:
-		return nil, invalidChildTypeError(typ.FilterAnd, t)
+func (p *parser) filterValue() (Expression, bool) {
+	if p.err != nil {
+		return nil, false
+	}
+	start := p.pos
+	if valuePath, ok := p.valuePath(); ok {
+		return new(valuePath), true
+	}
+	p.pos = start
+	if attrExp, ok := p.attrExp(); ok {
+		return attrExp, true
+	}
+	p.pos = start
+	if exp, ok := p.filterNot(); ok {
+		return exp, true
 	}
+	p.pos = start
+	return p.filterParentheses()
 }
@@ -0,0 +1,182 @@
+package filter
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+)
+
+// Format returns the canonical representation of the given expression. The
+// result is guaranteed to parse to an equal expression with ParseFilter (or a
+// parser with UseNumber or UseRat if the expression contains json.Number or
+// *big.Rat values), given that the expression itself could be the result of
+// parsing, i.e. contains valid operators and compare values that are
+// representable in JSON.
+//
+// Operators and literals are written in lowercase, strings are written as JSON
+// string literals and parentheses are only added where they are needed to
+// preserve the structure of the expression.
+func Format(expr Expression) string {
+	var b strings.Builder
+	writeExpression(&b, expr)
+	return b.String()
+}
+
+// FormatPath returns the canonical representation of the given path. See
+// Format for more information.
+func FormatPath(path Path) string {
+	var b strings.Builder
+	b.WriteString(path.AttributePath.String())
+	if path.ValueExpression != nil {
+		b.WriteByte('[')
+		writeExpression(&b, path.ValueExpression)
+		b.WriteByte(']')
+	}
+	if path.SubAttribute != nil {
+		b.WriteByte('.')
+		b.WriteString(*path.SubAttribute)
+	}
+	return b.String()
+}
+
+// FormatValuePath returns the canonical representation of the given value path.
+// See Format for more information.
+func FormatValuePath(valuePath ValuePath) string {
+	return Format(&valuePath)
+}
+
+// needsParentheses checks whether the given operand of a logical expression
+// with the given operator needs to be grouped. Logical expressions are parsed
+// left-associative and 'and' takes precedence over 'or'.
+func needsParentheses(operator LogicalOperator, operand Expression, right bool) bool {
+	e, ok := operand.(*LogicalExpression)
+	if !ok {
+		return false
+	}
+	op := LogicalOperator(strings.ToLower(string(e.Operator)))
+	if op == OR && operator == AND {
+		return true
+	}
+	return right && op == operator
+}
+
+func writeCompareValue(b *strings.Builder, value any) {
+	switch v := value.(type) {
+	case nil:
+		b.WriteString("null")
+	case bool:
+		b.WriteString(strconv.FormatBool(v))
+	case string:
+		writeString(b, v)
+	case json.Number:
+		b.WriteString(v.String())
+	case float32:
+		writeFloat(b, float64(v), 32)
+	case float64:
+		writeFloat(b, v, 64)
+	case *big.Rat:
+		writeRat(b, v)
+	default:
+		fmt.Fprintf(b, "%v", v)
+	}
+}
+
+func writeExpression(b *strings.Builder, expr Expression) {
+	switch e := expr.(type) {
+	case *AttributeExpression:
+		b.WriteString(e.AttributePath.String())
+		b.WriteByte(' ')
+		b.WriteString(strings.ToLower(string(e.Operator)))
+		if !strings.EqualFold(string(e.Operator), string(PR)) {
+			b.WriteByte(' ')
+			writeCompareValue(b, e.CompareValue)
+		}
+	case *LogicalExpression:
+		op := LogicalOperator(strings.ToLower(string(e.Operator)))
+		writeOperand(b, e.Left, needsParentheses(op, e.Left, false))
+		b.WriteByte(' ')
+		b.WriteString(string(op))
+		b.WriteByte(' ')
+		writeOperand(b, e.Right, needsParentheses(op, e.Right, true))
+	case *NotExpression:
+		b.WriteString("not (")
+		writeExpression(b, e.Expression)
+		b.WriteByte(')')
+	case *ValuePath:
+		b.WriteString(e.AttributePath.String())
+		b.WriteByte('[')
+		writeExpression(b, e.ValueFilter)
+		b.WriteByte(']')
+	default:
+		fmt.Fprintf(b, "%v", e)
+	}
+}
+
+// writeFloat writes the given float so that it gets parsed as a float again,
+// integral values get a fractional part.
+func writeFloat(b *strings.Builder, f float64, bitSize int) {
+	s := strconv.FormatFloat(f, 'g', -1, bitSize)
+	b.WriteString(s)
+	if !strings.ContainsAny(s, ".eEIN") {
+		b.WriteString(".0")
+	}
+}
+
+// writeRat writes the given rational number as a decimal, so that it gets
+// parsed as a decimal again. Rational numbers that have no finite decimal
+// representation are rounded to 30 digits after the point.
+func writeRat(b *strings.Builder, r *big.Rat) {
+	prec, exact := r.FloatPrec()
+	if !exact {
+		prec = 30
+	}
+	b.WriteString(r.FloatString(max(prec, 1)))
+}
+
+func writeOperand(b *strings.Builder, expr Expression, parentheses bool) {
+	if parentheses {
+		b.WriteByte('(')
+	}
+	writeExpression(b, expr)
+	if parentheses {
+		b.WriteByte(')')
+	}
+}
+
+// writeString writes the given string as a JSON string literal. Only the
+// characters that are not allowed to be unescaped are escaped. Invalid UTF-8 is
+// replaced by the replacement character.
+// More info: https://tools.ietf.org/html/rfc8259#section-7
+func writeString(b *strings.Builder, s string) {
+	const hex = "0123456789ABCDEF"
+	b.WriteByte('"')
+	for _, r := range s {
+		switch r {
+		case '"':
+			b.WriteString(`\"`)
+		case '\\':
+			b.WriteString(`\\`)
+		case '\b':
+			b.WriteString(`\b`)
+		case '\f':
+			b.WriteString(`\f`)
+		case '\n':
+			b.WriteString(`\n`)
+		case '\r':
+			b.WriteString(`\r`)
+		case '\t':
+			b.WriteString(`\t`)
+		default:
+			if r < 0x20 {
+				b.WriteString(`\u00`)
+				b.WriteByte(hex[r>>4])
+				b.WriteByte(hex[r&0xF])
+				continue
+			}
+			b.WriteRune(r)
+		}
+	}
+	b.WriteByte('"')
+}
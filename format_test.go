@@ -0,0 +1,209 @@
+package filter
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+func ExampleFormat() {
+	expression, _ := ParseFilter([]byte("NOT (title PR) AND (userType EQ \"Employee\" OR name.familyName CO \"O\\u0027Malley\")"))
+	fmt.Println(Format(expression))
+	// Output:
+	// not (title pr) and (userType eq "Employee" or name.familyName co "O'Malley")
+}
+
+func ExampleFormatPath() {
+	path, _ := ParsePath([]byte("members[value EQ \"2819c223\"].displayName"))
+	fmt.Println(FormatPath(path))
+	// Output:
+	// members[value eq "2819c223"].displayName
+}
+
+func TestFormat(t *testing.T) {
+	attrExp := func(name string, value any) Expression {
+		return &AttributeExpression{
+			AttributePath: AttributePath{AttributeName: name},
+			Operator:      EQ,
+			CompareValue:  value,
+		}
+	}
+	for _, test := range []struct {
+		expression Expression
+		expected   string
+	}{
+		{
+			expression: attrExp("a", "\"\\/\b\f\n\r\t\x00\x1féö😀 <>&"),
+			expected:   `a eq "\"\\/\b\f\n\r\t\u0000\u001Féö😀` + " " + `<>&"`,
+		},
+		{
+			expression: attrExp("a", 1.0),
+			expected:   "a eq 1.0",
+		},
+		{
+			expression: attrExp("a", -0.5),
+			expected:   "a eq -0.5",
+		},
+		{
+			expression: attrExp("a", 1e21),
+			expected:   "a eq 1e+21",
+		},
+		{
+			expression: attrExp("a", 10),
+			expected:   "a eq 10",
+		},
+		{
+			expression: attrExp("a", nil),
+			expected:   "a eq null",
+		},
+		{
+			expression: &LogicalExpression{
+				Left: attrExp("a", true),
+				Right: &LogicalExpression{
+					Left:     attrExp("b", true),
+					Right:    attrExp("c", true),
+					Operator: OR,
+				},
+				Operator: OR,
+			},
+			expected: "a eq true or (b eq true or c eq true)",
+		},
+		{
+			expression: &LogicalExpression{
+				Left: &LogicalExpression{
+					Left:     attrExp("a", true),
+					Right:    attrExp("b", true),
+					Operator: OR,
+				},
+				Right:    attrExp("c", true),
+				Operator: OR,
+			},
+			expected: "a eq true or b eq true or c eq true",
+		},
+		{
+			expression: &LogicalExpression{
+				Left: &LogicalExpression{
+					Left:     attrExp("a", true),
+					Right:    attrExp("b", true),
+					Operator: OR,
+				},
+				Right: &LogicalExpression{
+					Left:     attrExp("c", true),
+					Right:    attrExp("d", true),
+					Operator: AND,
+				},
+				Operator: AND,
+			},
+			expected: "(a eq true or b eq true) and (c eq true and d eq true)",
+		},
+		{
+			expression: &LogicalExpression{
+				Left: &LogicalExpression{
+					Left:     attrExp("a", true),
+					Right:    attrExp("b", true),
+					Operator: AND,
+				},
+				Right: &NotExpression{
+					Expression: &LogicalExpression{
+						Left:     attrExp("c", true),
+						Right:    attrExp("d", true),
+						Operator: OR,
+					},
+				},
+				Operator: OR,
+			},
+			expected: "a eq true and b eq true or not (c eq true or d eq true)",
+		},
+	} {
+		t.Run(test.expected, func(t *testing.T) {
+			s := Format(test.expression)
+			if s != test.expected {
+				t.Errorf("expected %s, got %s", test.expected, s)
+			}
+			expression, err := ParseFilter([]byte(s))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(expression, test.expression) {
+				t.Errorf("expected %v, got %v", test.expression, expression)
+			}
+		})
+	}
+}
+
+func TestFormat_roundTrip(t *testing.T) {
+	for _, example := range []string{
+		"userName Eq \"bjensen\"",
+		"name.familyName co \"O'Malley\"",
+		"name.familyName co \"O\\\"Malley \\u00E9 \\\\ \\/\"",
+		"urn:ietf:params:scim:schemas:core:2.0:User:userName sw \"J\"",
+		"title pr",
+		"meta.lastModified gt \"2011-05-13T04:42:34Z\"",
+		"title pr and userType eq \"Employee\" or userType eq \"Intern\"",
+		"userType ne \"Employee\" and not (emails co \"example.com\" or emails.value co \"example.org\")",
+		"(a eq 1 or b eq 2.5) and (c eq -3e2 or d eq NULL) and e eq TRUE",
+		"a eq 1 or (b eq 2 or (c eq 3 and (d eq 4 and e eq 5)))",
+		"emails[type eq \"work\" and value co \"@example.com\"] or ims[not (type eq \"xmpp\")]",
+		"emails[(type eq \"a\" or type eq \"b\") and primary eq true and not (value pr or display pr)]",
+		"emails[a pr or (b pr or c pr)]",
+	} {
+		t.Run(example, func(t *testing.T) {
+			for _, parse := range []func([]byte) (Expression, error){ParseFilter, ParseFilterNumber} {
+				expression, err := parse([]byte(example))
+				if err != nil {
+					t.Fatal(err)
+				}
+				s := Format(expression)
+				formatted, err := parse([]byte(s))
+				if err != nil {
+					t.Fatal(s, err)
+				}
+				if !reflect.DeepEqual(expression, formatted) {
+					t.Errorf("expected %v, got %v", expression, formatted)
+				}
+				if s != Format(formatted) {
+					t.Errorf("format is not stable: %s, %s", s, Format(formatted))
+				}
+			}
+		})
+	}
+}
+
+func TestFormatPath(t *testing.T) {
+	for _, example := range []string{
+		"members",
+		"name.familyName",
+		"urn:ietf:params:scim:schemas:extension:enterprise:2.0:User:manager.value",
+		"addresses[type eq \"work\"]",
+		"members[value eq \"2819c223-7f76-453a-919d-413861904646\"].displayName",
+	} {
+		t.Run(example, func(t *testing.T) {
+			path, err := ParsePathNumber([]byte(example))
+			if err != nil {
+				t.Fatal(err)
+			}
+			s := FormatPath(path)
+			if s != example {
+				t.Errorf("expected %s, got %s", example, s)
+			}
+			formatted, err := ParsePathNumber([]byte(s))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(path, formatted) {
+				t.Errorf("expected %v, got %v", path, formatted)
+			}
+		})
+	}
+}
+
+func TestFormatValuePath(t *testing.T) {
+	valuePath, err := ParseValuePath([]byte("emails[type EQ \"work\" OR value EW \"@example.com\"]"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := "emails[type eq \"work\" or value ew \"@example.com\"]"
+	if s := FormatValuePath(valuePath); s != expected {
+		t.Errorf("expected %s, got %s", expected, s)
+	}
+}
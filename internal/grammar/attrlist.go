@@ -0,0 +1,23 @@
+package grammar
+
+import (
+	"github.com/di-wu/parser/ast"
+	"github.com/di-wu/parser/op"
+	"github.com/scim2/filter-parser/v2/internal/types"
+)
+
+func AttrList(p *ast.Parser) (*ast.Node, error) {
+	return p.Expect(ast.Capture{
+		Type:        typ.AttrList,
+		TypeStrings: typ.Stringer,
+		Value: op.And{
+			AttrPath,
+			op.MinZero(op.And{
+				op.MinZero(SP),
+				',',
+				op.MinZero(SP),
+				AttrPath,
+			}),
+		},
+	})
+}
@@ -0,0 +1,13 @@
+package grammar
+
+import (
+	"fmt"
+	"github.com/di-wu/parser/ast"
+)
+
+func ExampleAttrList() {
+	p, _ := ast.New([]byte("userName, name.givenName,urn:ietf:params:scim:schemas:extension:enterprise:2.0:User:employeeNumber"))
+	fmt.Println(AttrList(p))
+	// Output:
+	// ["AttrList",[["AttrPath",[["AttrName","userName"]]],["AttrPath",[["AttrName","name"],["AttrName","givenName"]]],["AttrPath",[["URI","urn:ietf:params:scim:schemas:extension:enterprise:2.0:User:"],["AttrName","employeeNumber"]]]]] <nil>
+}
@@ -36,6 +36,7 @@ func Escaped(p *ast.Parser) (*ast.Node, error) {
 					op.Or{
 						parser.CheckRuneRange('0', '9'),
 						parser.CheckRuneRange('A', 'F'),
+						parser.CheckRuneRange('a', 'f'),
 					},
 				),
 			},
@@ -8,18 +8,7 @@ import (
 )
 
 func ValueFilter(p *ast.Parser) (*ast.Node, error) {
-	return p.Expect(op.Or{
-		ValueLogExpOr,
-		ValueLogExpAnd,
-		AttrExp,
-	})
-}
-
-func ValueFilterAll(p *ast.Parser) (*ast.Node, error) {
-	return p.Expect(op.Or{
-		ValueFilter,
-		ValueFilterNot,
-	})
+	return ValueLogExpOr(p)
 }
 
 func ValueFilterNot(p *ast.Parser) (*ast.Node, error) {
@@ -29,41 +18,63 @@ func ValueFilterNot(p *ast.Parser) (*ast.Node, error) {
 		Value: op.And{
 			parser.CheckStringCI("not"),
 			op.MinZero(SP),
-			'(',
-			op.MinZero(SP),
-			ValueFilter,
-			op.MinZero(SP),
-			')',
+			ValueFilterParentheses,
 		},
 	})
 }
 
+func ValueFilterParentheses(p *ast.Parser) (*ast.Node, error) {
+	return p.Expect(op.And{
+		'(',
+		op.MinZero(SP),
+		ValueFilter,
+		op.MinZero(SP),
+		')',
+	})
+}
+
+func ValueFilterValue(p *ast.Parser) (*ast.Node, error) {
+	return p.Expect(op.Or{
+		AttrExp,
+		ValueFilterNot,
+		ValueFilterParentheses,
+	})
+}
+
+// ValueLogExpAnd parses one or more value filters separated by 'and'. A single
+// value filter is returned as is.
 func ValueLogExpAnd(p *ast.Parser) (*ast.Node, error) {
-	return p.Expect(ast.Capture{
+	return collapse(p.Expect(ast.Capture{
 		Type:        typ.ValueLogExpAnd,
 		TypeStrings: typ.Stringer,
 		Value: op.And{
-			AttrExp,
-			op.MinZero(SP),
-			parser.CheckStringCI("and"),
-			op.MinZero(SP),
-			AttrExp,
+			ValueFilterValue,
+			op.MinZero(op.And{
+				op.MinZero(SP),
+				parser.CheckStringCI("and"),
+				op.MinZero(SP),
+				ValueFilterValue,
+			}),
 		},
-	})
+	}))
 }
 
+// ValueLogExpOr parses one or more ValueLogExpAnd separated by 'or'. A single
+// ValueLogExpAnd is returned as is.
 func ValueLogExpOr(p *ast.Parser) (*ast.Node, error) {
-	return p.Expect(ast.Capture{
+	return collapse(p.Expect(ast.Capture{
 		Type:        typ.ValueLogExpOr,
 		TypeStrings: typ.Stringer,
 		Value: op.And{
-			AttrExp,
-			op.MinZero(SP),
-			parser.CheckStringCI("or"),
-			op.MinZero(SP),
-			AttrExp,
+			ValueLogExpAnd,
+			op.MinZero(op.And{
+				op.MinZero(SP),
+				parser.CheckStringCI("or"),
+				op.MinZero(SP),
+				ValueLogExpAnd,
+			}),
 		},
-	})
+	}))
 }
 
 func ValuePath(p *ast.Parser) (*ast.Node, error) {
@@ -75,9 +86,20 @@ func ValuePath(p *ast.Parser) (*ast.Node, error) {
 			op.MinZero(SP),
 			'[',
 			op.MinZero(SP),
-			ValueFilterAll,
+			ValueFilter,
 			op.MinZero(SP),
 			']',
 		},
 	})
 }
+
+// collapse replaces a node with a single child by that child.
+func collapse(node *ast.Node, err error) (*ast.Node, error) {
+	if err != nil {
+		return nil, err
+	}
+	if child := node.FirstChild; child != nil && child == node.LastChild {
+		return child.Remove(), nil
+	}
+	return node, nil
+}
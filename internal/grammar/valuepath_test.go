@@ -0,0 +1,18 @@
+package grammar
+
+import (
+	"fmt"
+	"github.com/di-wu/parser/ast"
+)
+
+func ExampleValuePath() {
+	p := func(s string) {
+		p, _ := ast.New([]byte(s))
+		fmt.Println(ValuePath(p))
+	}
+	p("emails[type eq \"work\" and primary eq true and value pr]")
+	p("emails[(type eq \"work\" or type eq \"home\") and not (primary eq true)]")
+	// Output:
+	// ["ValuePath",[["AttrPath",[["AttrName","emails"]]],["ValueLogExpAnd",[["AttrExp",[["AttrPath",[["AttrName","type"]]],["CompareOp","eq"],["String","\"work\""]]],["AttrExp",[["AttrPath",[["AttrName","primary"]]],["CompareOp","eq"],["True","true"]]],["AttrExp",[["AttrPath",[["AttrName","value"]]]]]]]]] <nil>
+	// ["ValuePath",[["AttrPath",[["AttrName","emails"]]],["ValueLogExpAnd",[["ValueLogExpOr",[["AttrExp",[["AttrPath",[["AttrName","type"]]],["CompareOp","eq"],["String","\"work\""]]],["AttrExp",[["AttrPath",[["AttrName","type"]]],["CompareOp","eq"],["String","\"home\""]]]]],["ValueFilterNot",[["AttrExp",[["AttrPath",[["AttrName","primary"]]],["CompareOp","eq"],["True","true"]]]]]]]]] <nil>
+}
@@ -8,6 +8,7 @@ const (
 	FilterNot
 
 	Path
+	AttrList
 
 	AttrExp
 	AttrPath
@@ -44,6 +45,7 @@ var Stringer = []string{
 	"FilterNot",
 
 	"Path",
+	"AttrList",
 
 	"AttrExp",
 	"AttrPath",
@@ -0,0 +1,212 @@
+// Package ldap translates filter expressions to LDAP search filters as defined
+// in RFC 4515.
+package ldap
+
+import (
+	"errors"
+	"fmt"
+	filter "github.com/scim2/filter-parser/v2"
+	"math/big"
+	"strconv"
+	"strings"
+)
+
+// ErrUnsupported is returned for expressions that can not be expressed in an
+// LDAP search filter, e.g. strict ordering operators or value paths.
+var ErrUnsupported = errors.New("unsupported by LDAP")
+
+// Translate translates the given expression to an LDAP search filter. Attribute
+// paths are resolved with the given mapping.
+//
+// Since LDAP has no strict ordering operators, 'gt' and 'lt' are not supported.
+// The same goes for value paths, since LDAP has no complex attributes. An
+// error that wraps ErrUnsupported is returned for these expressions.
+//
+// More info: https://tools.ietf.org/html/rfc4515
+func Translate(expr filter.Expression, mapping Mapping) (string, error) {
+	var b strings.Builder
+	if err := mapping.normalize().translate(&b, expr); err != nil {
+		return "", err
+	}
+	return b.String(), nil
+}
+
+// escape escapes the given value as an assertion value. The characters '*',
+// '(', ')', '\' and NUL must always be escaped.
+// More info: https://tools.ietf.org/html/rfc4515#section-3
+func escape(value string) string {
+	return strings.NewReplacer(
+		`\`, `\5c`,
+		`*`, `\2a`,
+		`(`, `\28`,
+		`)`, `\29`,
+		"\x00", `\00`,
+	).Replace(value)
+}
+
+// value returns the (escaped) assertion value of the given compare value.
+func value(compareValue any) (string, error) {
+	switch v := compareValue.(type) {
+	case string:
+		return escape(v), nil
+	case bool:
+		// More info: https://tools.ietf.org/html/rfc4517#section-3.3.3
+		return strings.ToUpper(strconv.FormatBool(v)), nil
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64), nil
+	case float32:
+		return strconv.FormatFloat(float64(v), 'f', -1, 32), nil
+	case *big.Rat:
+		prec, _ := v.FloatPrec()
+		return v.FloatString(prec), nil
+	case int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64, fmt.Stringer:
+		return escape(fmt.Sprint(v)), nil
+	default:
+		return "", fmt.Errorf("invalid compare value: %v (%T)", compareValue, compareValue)
+	}
+}
+
+// Mapping maps attribute paths to LDAP attribute descriptions, e.g.
+// "name.familyName" to "sn". The keys are attribute paths as accepted by
+// filter.ParseAttrPath and are matched case-insensitively. An attribute path
+// with a URI prefix falls back to the mapping of the path without the prefix.
+type Mapping map[string]string
+
+// lookup returns the LDAP attribute that is mapped to the given attribute path.
+func (m Mapping) lookup(path filter.AttributePath) (string, error) {
+	if attribute, ok := m[strings.ToLower(path.String())]; ok {
+		return attribute, nil
+	}
+	if path.URIPrefix != nil {
+		p := path
+		p.URIPrefix = nil
+		if attribute, ok := m[strings.ToLower(p.String())]; ok {
+			return attribute, nil
+		}
+	}
+	return "", fmt.Errorf("no LDAP attribute mapped for %q", path)
+}
+
+// normalize returns a copy of the mapping with lowercase keys.
+func (m Mapping) normalize() Mapping {
+	normalized := make(Mapping, len(m))
+	for k, v := range m {
+		normalized[strings.ToLower(k)] = v
+	}
+	return normalized
+}
+
+func (m Mapping) translate(b *strings.Builder, expr filter.Expression) error {
+	switch e := expr.(type) {
+	case *filter.AttributeExpression:
+		return m.translateAttrExp(b, e)
+	case *filter.LogicalExpression:
+		var op byte
+		switch filter.LogicalOperator(strings.ToLower(string(e.Operator))) {
+		case filter.AND:
+			op = '&'
+		case filter.OR:
+			op = '|'
+		default:
+			return fmt.Errorf("invalid logical operator: %q", e.Operator)
+		}
+		b.WriteByte('(')
+		b.WriteByte(op)
+		if err := m.translateOperands(b, e.Operator, e); err != nil {
+			return err
+		}
+		b.WriteByte(')')
+		return nil
+	case *filter.NotExpression:
+		b.WriteString("(!")
+		if err := m.translate(b, e.Expression); err != nil {
+			return err
+		}
+		b.WriteByte(')')
+		return nil
+	case *filter.ValuePath:
+		return fmt.Errorf("%w: value path %q", ErrUnsupported, e)
+	default:
+		return fmt.Errorf("invalid expression type: %T", expr)
+	}
+}
+
+func (m Mapping) translateAttrExp(b *strings.Builder, e *filter.AttributeExpression) error {
+	attribute, err := m.lookup(e.AttributePath)
+	if err != nil {
+		return err
+	}
+
+	op := filter.CompareOperator(strings.ToLower(string(e.Operator)))
+	if op == filter.PR {
+		fmt.Fprintf(b, "(%s=*)", attribute)
+		return nil
+	}
+	if e.CompareValue == nil {
+		switch op {
+		case filter.EQ:
+			fmt.Fprintf(b, "(!(%s=*))", attribute)
+		case filter.NE:
+			fmt.Fprintf(b, "(%s=*)", attribute)
+		default:
+			return fmt.Errorf("operator %q is not supported on null", op)
+		}
+		return nil
+	}
+
+	v, err := value(e.CompareValue)
+	if err != nil {
+		return err
+	}
+	_, isString := e.CompareValue.(string)
+	if _, ok := e.CompareValue.(bool); ok && op != filter.EQ && op != filter.NE {
+		return fmt.Errorf("operator %q is not supported on boolean values", op)
+	}
+	switch op {
+	case filter.EQ:
+		fmt.Fprintf(b, "(%s=%s)", attribute, v)
+	case filter.NE:
+		fmt.Fprintf(b, "(!(%s=%s))", attribute, v)
+	case filter.CO, filter.SW, filter.EW:
+		if !isString {
+			return fmt.Errorf("operator %q is only supported on strings", op)
+		}
+		switch op {
+		case filter.CO:
+			// Every value contains the empty string, "**" is not a valid
+			// substring filter.
+			if v == "" {
+				v = "*"
+			} else {
+				v = "*" + v + "*"
+			}
+		case filter.SW:
+			v = v + "*"
+		case filter.EW:
+			v = "*" + v
+		}
+		fmt.Fprintf(b, "(%s=%s)", attribute, v)
+	case filter.GE:
+		fmt.Fprintf(b, "(%s>=%s)", attribute, v)
+	case filter.LE:
+		fmt.Fprintf(b, "(%s<=%s)", attribute, v)
+	case filter.GT, filter.LT:
+		return fmt.Errorf("%w: strict ordering operator %q", ErrUnsupported, op)
+	default:
+		return fmt.Errorf("invalid compare operator: %q", op)
+	}
+	return nil
+}
+
+// translateOperands translates the operands of the given logical expression.
+// Operands with the same operator are flattened, e.g. (&(a=1)(b=2)(c=3)).
+func (m Mapping) translateOperands(b *strings.Builder, operator filter.LogicalOperator, expr filter.Expression) error {
+	e, ok := expr.(*filter.LogicalExpression)
+	if !ok || !strings.EqualFold(string(e.Operator), string(operator)) {
+		return m.translate(b, expr)
+	}
+	if err := m.translateOperands(b, operator, e.Left); err != nil {
+		return err
+	}
+	return m.translateOperands(b, operator, e.Right)
+}
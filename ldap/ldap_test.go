@@ -0,0 +1,102 @@
+package ldap
+
+import (
+	"errors"
+	"fmt"
+	filter "github.com/scim2/filter-parser/v2"
+	"testing"
+)
+
+var mapping = Mapping{
+	"userName":        "uid",
+	"name.familyName": "sn",
+	"name.givenName":  "givenName",
+	"displayName":     "cn",
+	"emails.value":    "mail",
+	"active":          "accountEnabled",
+	"meta.created":    "createTimestamp",
+	"urn:ietf:params:scim:schemas:extension:enterprise:2.0:User:employeeNumber": "employeeNumber",
+}
+
+func ExampleTranslate() {
+	expression, _ := filter.ParseFilter([]byte("userName sw \"j\" and not (name.familyName eq \"O'Malley (Jr.)\")"))
+	fmt.Println(Translate(expression, Mapping{
+		"userName":        "uid",
+		"name.familyName": "sn",
+	}))
+	// Output:
+	// (&(uid=j*)(!(sn=O'Malley \28Jr.\29))) <nil>
+}
+
+func TestTranslate(t *testing.T) {
+	for _, test := range []struct {
+		filter   string
+		expected string
+	}{
+		{"userName eq \"bjensen\"", "(uid=bjensen)"},
+		{"USERNAME EQ \"bjensen\"", "(uid=bjensen)"},
+		{"userName ne \"bjensen\"", "(!(uid=bjensen))"},
+		{"userName co \"a*b\"", "(uid=*a\\2ab*)"},
+		{"userName sw \"j\"", "(uid=j*)"},
+		{"userName ew \"\\\\\"", "(uid=*\\5c)"},
+		{"userName co \"\"", "(uid=*)"},
+		{"userName sw \"\"", "(uid=*)"},
+		{"userName ew \"\"", "(uid=*)"},
+		{"userName eq \"\\u0000\"", "(uid=\\00)"},
+		{"displayName eq \"Babs Jensen é\"", "(cn=Babs Jensen é)"},
+		{"userName pr", "(uid=*)"},
+		{"userName eq null", "(!(uid=*))"},
+		{"userName ne null", "(uid=*)"},
+		{"active eq true", "(accountEnabled=TRUE)"},
+		{"meta.created ge \"20110513044234Z\"", "(createTimestamp>=20110513044234Z)"},
+		{"meta.created le \"20110513044234Z\"", "(createTimestamp<=20110513044234Z)"},
+		{"urn:ietf:params:scim:schemas:extension:enterprise:2.0:User:employeeNumber eq 701984", "(employeeNumber=701984)"},
+		{"urn:ietf:params:scim:schemas:core:2.0:User:userName eq \"bjensen\"", "(uid=bjensen)"},
+		{"emails.value ew \"@example.com\"", "(mail=*@example.com)"},
+		{"userName pr and displayName pr and active eq true", "(&(uid=*)(cn=*)(accountEnabled=TRUE))"},
+		{"userName pr or displayName pr and active eq true", "(|(uid=*)(&(cn=*)(accountEnabled=TRUE)))"},
+		{"(userName pr or displayName pr) and not (active eq true or name.givenName pr)", "(&(|(uid=*)(cn=*))(!(|(accountEnabled=TRUE)(givenName=*))))"},
+	} {
+		t.Run(test.filter, func(t *testing.T) {
+			expression, err := filter.ParseFilter([]byte(test.filter))
+			if err != nil {
+				t.Fatal(err)
+			}
+			ldapFilter, err := Translate(expression, mapping)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if ldapFilter != test.expected {
+				t.Errorf("expected %s, got %s", test.expected, ldapFilter)
+			}
+		})
+	}
+}
+
+func TestTranslate_unsupported(t *testing.T) {
+	for _, test := range []struct {
+		filter      string
+		unsupported bool
+	}{
+		{"meta.created gt \"20110513044234Z\"", true},
+		{"meta.created lt \"20110513044234Z\"", true},
+		{"emails[type eq \"work\"]", true},
+		{"title pr", false},
+		{"active ge true", false},
+		{"userName co 1", false},
+	} {
+		t.Run(test.filter, func(t *testing.T) {
+			expression, err := filter.ParseFilter([]byte(test.filter))
+			if err != nil {
+				t.Fatal(err)
+			}
+			_, err = Translate(expression, mapping)
+			if err == nil {
+				t.Fatal("expected an error")
+			}
+			if errors.Is(err, ErrUnsupported) != test.unsupported {
+				t.Errorf("unexpected error: %v", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,340 @@
+// Package mongo translates filter expressions to MongoDB query documents. The
+// documents are plain maps, in the shape of bson.M, so that no driver is
+// needed to build them.
+//
+// More info: https://www.mongodb.com/docs/manual/reference/operator/query/
+package mongo
+
+import (
+	"encoding/json"
+	"fmt"
+	filter "github.com/scim2/filter-parser/v2"
+	"math/big"
+	"regexp"
+	"strings"
+)
+
+// operators maps compare operators to MongoDB query operators.
+var operators = map[filter.CompareOperator]string{
+	filter.NE: "$ne",
+	filter.GT: "$gt",
+	filter.GE: "$gte",
+	filter.LT: "$lt",
+	filter.LE: "$lte",
+}
+
+// Translate translates the given expression to a MongoDB query. Attribute paths
+// are resolved with the given mapping.
+//
+// String comparisons are case-insensitive unless the field is marked as case
+// exact. The 'co', 'sw' and 'ew' operators are translated to regular
+// expressions with the "i" option, the other operators rely on the collation
+// of the query. Case exact strings are compared with regular expressions
+// without the "i" option if the query needs a case-insensitive collation,
+// except for the ordering operators which follow the collation.
+//
+// An attribute expression that is negated matches documents in which the field
+// is missing, like the 'ne' operator does.
+func Translate(expr filter.Expression, mapping Mapping) (Query, error) {
+	t := translator{
+		fields: make(Mapping, len(mapping)),
+	}
+	for k, v := range mapping {
+		t.fields[key(k)] = v
+	}
+	doc, err := t.translate(expr, nil, "")
+	if err != nil {
+		return Query{}, err
+	}
+	if !t.insensitive {
+		return Query{Filter: doc}, nil
+	}
+	if t.exact {
+		// Case exact strings were compared with the (case-insensitive)
+		// collation, so the expression needs to be translated again.
+		t.collation = true
+		if doc, err = t.translate(expr, nil, ""); err != nil {
+			return Query{}, err
+		}
+	}
+	return Query{
+		Filter: doc,
+		Collation: map[string]any{
+			"locale":   "en",
+			"strength": 2,
+		},
+	}, nil
+}
+
+// fullPath returns the given attribute path, which is a sub-attribute of the
+// given value path if the parent is not nil.
+func fullPath(path filter.AttributePath, parent *filter.AttributePath) filter.AttributePath {
+	if parent == nil {
+		return path
+	}
+	full := *parent
+	full.SubAttribute = &path.AttributeName
+	return full
+}
+
+// key returns the normalized key of the given attribute path.
+func key(path string) string {
+	return strings.ToLower(path)
+}
+
+// regex returns a $regex operator with the given pattern.
+func regex(pattern string, caseInsensitive bool) map[string]any {
+	doc := map[string]any{
+		"$regex": pattern,
+	}
+	if caseInsensitive {
+		doc["$options"] = "i"
+	}
+	return doc
+}
+
+// value converts the given compare value to a value that can be encoded as
+// BSON. Decimals are converted to doubles.
+func value(compareValue any) (any, error) {
+	switch v := compareValue.(type) {
+	case json.Number:
+		if i, err := v.Int64(); err == nil {
+			return i, nil
+		}
+		if f, err := v.Float64(); err == nil {
+			return f, nil
+		}
+	case *big.Int:
+		if v.IsInt64() {
+			return v.Int64(), nil
+		}
+	case *big.Rat:
+		f, _ := v.Float64()
+		return f, nil
+	case nil, bool, string, int, int8, int16, int32, int64, uint8, uint16, uint32, float32, float64:
+		return v, nil
+	}
+	return nil, fmt.Errorf("invalid compare value: %v (%T)", compareValue, compareValue)
+}
+
+// Field describes the field of a document in which the value of an attribute
+// is stored.
+type Field struct {
+	// Name is the (dotted) name of the field, e.g. "name.familyName".
+	Name string
+	// CaseExact indicates that string values are compared case-sensitively.
+	CaseExact bool
+}
+
+// Mapping maps attribute paths to fields. The keys are attribute paths as
+// accepted by filter.ParseAttrPath (e.g. "name.familyName") and are matched
+// case-insensitively. An attribute path with a URI prefix falls back to the
+// field of the path without the prefix.
+//
+// The sub-attributes within value paths need to be mapped to fields within the
+// field of the value path, e.g. "emails.type" to "emails.type" if "emails" is
+// mapped to "emails".
+type Mapping map[string]Field
+
+// Query is a MongoDB query.
+type Query struct {
+	// Filter is the query document.
+	Filter map[string]any
+	// Collation is the collation that the query needs to be executed with, or
+	// nil if the query does not compare strings case-insensitively. It is a
+	// case-insensitive collation, i.e. {locale: "en", strength: 2}.
+	Collation map[string]any
+}
+
+type translator struct {
+	fields Mapping
+	// insensitive indicates that strings are compared case-insensitively by
+	// the collation.
+	insensitive bool
+	// exact indicates that case exact strings are compared by the collation.
+	exact bool
+	// collation indicates that the query uses a case-insensitive collation.
+	collation bool
+}
+
+// lookup returns the field that is mapped to the given attribute path. The
+// name of the field is relative to the given parent field.
+func (t *translator) lookup(path filter.AttributePath, parent string) (Field, error) {
+	field, ok := t.fields[key(path.String())]
+	if !ok && path.URIPrefix != nil {
+		path.URIPrefix = nil
+		field, ok = t.fields[key(path.String())]
+	}
+	if !ok {
+		return Field{}, fmt.Errorf("no field mapped for attribute %q", path)
+	}
+	if parent != "" {
+		name, ok := strings.CutPrefix(field.Name, parent+".")
+		if !ok {
+			return Field{}, fmt.Errorf("field %q of attribute %q is not within field %q", field.Name, path, parent)
+		}
+		field.Name = name
+	}
+	return field, nil
+}
+
+// translate translates the given expression. If the parent is not nil, the
+// expression is the value filter of that attribute path, of which the values
+// are stored in the given field.
+func (t *translator) translate(expr filter.Expression, parent *filter.AttributePath, field string) (map[string]any, error) {
+	switch e := expr.(type) {
+	case *filter.AttributeExpression:
+		if parent != nil && (e.AttributePath.URIPrefix != nil || e.AttributePath.SubAttribute != nil) {
+			return nil, fmt.Errorf("value filters can only contain sub-attributes: %q", e.AttributePath)
+		}
+		f, err := t.lookup(fullPath(e.AttributePath, parent), field)
+		if err != nil {
+			return nil, err
+		}
+		return t.translateAttrExp(f, e)
+	case *filter.LogicalExpression:
+		var op string
+		switch filter.LogicalOperator(strings.ToLower(string(e.Operator))) {
+		case filter.AND:
+			op = "$and"
+		case filter.OR:
+			op = "$or"
+		default:
+			return nil, fmt.Errorf("invalid logical operator: %q", e.Operator)
+		}
+		operands, err := t.translateOperands(e.Operator, e, parent, field)
+		if err != nil {
+			return nil, err
+		}
+		return map[string]any{
+			op: operands,
+		}, nil
+	case *filter.NotExpression:
+		doc, err := t.translate(e.Expression, parent, field)
+		if err != nil {
+			return nil, err
+		}
+		return map[string]any{
+			"$nor": []any{doc},
+		}, nil
+	case *filter.ValuePath:
+		if parent != nil {
+			return nil, fmt.Errorf("value paths can not be nested: %q", e)
+		}
+		f, err := t.lookup(e.AttributePath, "")
+		if err != nil {
+			return nil, err
+		}
+		doc, err := t.translate(e.ValueFilter, &e.AttributePath, f.Name)
+		if err != nil {
+			return nil, err
+		}
+		return map[string]any{
+			f.Name: map[string]any{
+				"$elemMatch": doc,
+			},
+		}, nil
+	default:
+		return nil, fmt.Errorf("invalid expression type: %T", expr)
+	}
+}
+
+func (t *translator) translateAttrExp(field Field, e *filter.AttributeExpression) (map[string]any, error) {
+	op := filter.CompareOperator(strings.ToLower(string(e.Operator)))
+	if op == filter.PR {
+		return map[string]any{
+			field.Name: map[string]any{
+				"$exists": true,
+				"$ne":     nil,
+			},
+		}, nil
+	}
+
+	v, err := value(e.CompareValue)
+	if err != nil {
+		return nil, err
+	}
+	str, isString := v.(string)
+	if _, ok := v.(bool); ok {
+		switch op {
+		case filter.EQ, filter.NE:
+		default:
+			return nil, fmt.Errorf("operator %q is not supported on boolean values", op)
+		}
+	}
+
+	switch op {
+	case filter.CO, filter.SW, filter.EW:
+		if !isString {
+			return nil, fmt.Errorf("operator %q is only supported on strings", op)
+		}
+		pattern := regexp.QuoteMeta(str)
+		switch op {
+		case filter.SW:
+			pattern = "^" + pattern
+		case filter.EW:
+			pattern = pattern + "$"
+		}
+		return map[string]any{
+			field.Name: regex(pattern, !field.CaseExact),
+		}, nil
+	case filter.EQ, filter.NE:
+		if isString && field.CaseExact && t.collation {
+			exact := regex("^"+regexp.QuoteMeta(str)+"$", false)
+			if op == filter.NE {
+				exact = map[string]any{
+					"$not": exact,
+				}
+			}
+			return map[string]any{
+				field.Name: exact,
+			}, nil
+		}
+	case filter.GT, filter.GE, filter.LT, filter.LE:
+		if v == nil {
+			return nil, fmt.Errorf("operator %q is not supported on null", op)
+		}
+	default:
+		return nil, fmt.Errorf("invalid compare operator: %q", op)
+	}
+
+	if isString {
+		if field.CaseExact {
+			t.exact = true
+		} else {
+			t.insensitive = true
+		}
+	}
+	if op == filter.EQ {
+		return map[string]any{
+			field.Name: v,
+		}, nil
+	}
+	return map[string]any{
+		field.Name: map[string]any{
+			operators[op]: v,
+		},
+	}, nil
+}
+
+// translateOperands translates the operands of the given logical expression.
+// Operands with the same operator are flattened, e.g. {$and: [a, b, c]}.
+func (t *translator) translateOperands(operator filter.LogicalOperator, expr filter.Expression, parent *filter.AttributePath, field string) ([]any, error) {
+	e, ok := expr.(*filter.LogicalExpression)
+	if !ok || !strings.EqualFold(string(e.Operator), string(operator)) {
+		doc, err := t.translate(expr, parent, field)
+		if err != nil {
+			return nil, err
+		}
+		return []any{doc}, nil
+	}
+	left, err := t.translateOperands(operator, e.Left, parent, field)
+	if err != nil {
+		return nil, err
+	}
+	right, err := t.translateOperands(operator, e.Right, parent, field)
+	if err != nil {
+		return nil, err
+	}
+	return append(left, right...), nil
+}
@@ -0,0 +1,133 @@
+package mongo
+
+import (
+	"encoding/json"
+	"fmt"
+	filter "github.com/scim2/filter-parser/v2"
+	"testing"
+)
+
+var mapping = Mapping{
+	"id":              {Name: "_id", CaseExact: true},
+	"userName":        {Name: "userName"},
+	"name.familyName": {Name: "name.familyName"},
+	"active":          {Name: "active"},
+	"meta.created":    {Name: "meta.created", CaseExact: true},
+	"emails":          {Name: "emails"},
+	"emails.type":     {Name: "emails.type"},
+	"emails.value":    {Name: "emails.value"},
+	"emails.primary":  {Name: "emails.primary"},
+	"urn:ietf:params:scim:schemas:extension:enterprise:2.0:User:employeeNumber": {Name: "enterprise.employeeNumber", CaseExact: true},
+}
+
+func ExampleTranslate() {
+	expression, _ := filter.ParseFilter([]byte("userName eq \"bjensen\" and emails[type eq \"work\" and value ew \"@example.com\"]"))
+	query, _ := Translate(expression, mapping)
+	raw, _ := json.Marshal(query.Filter)
+	fmt.Println(string(raw))
+	fmt.Println(query.Collation)
+	// Output:
+	// {"$and":[{"userName":"bjensen"},{"emails":{"$elemMatch":{"$and":[{"type":"work"},{"value":{"$options":"i","$regex":"@example\\.com$"}}]}}}]}
+	// map[locale:en strength:2]
+}
+
+func TestTranslate(t *testing.T) {
+	for _, test := range []struct {
+		filter    string
+		expected  string
+		collation bool
+	}{
+		{filter: `userName eq "bjensen"`, expected: `{"userName":"bjensen"}`, collation: true},
+		{filter: `USERNAME EQ "bjensen"`, expected: `{"userName":"bjensen"}`, collation: true},
+		{filter: `userName ne "bjensen"`, expected: `{"userName":{"$ne":"bjensen"}}`, collation: true},
+		{filter: `userName gt "a"`, expected: `{"userName":{"$gt":"a"}}`, collation: true},
+		{filter: `userName co "a.b*"`, expected: `{"userName":{"$options":"i","$regex":"a\\.b\\*"}}`},
+		{filter: `userName sw "^j"`, expected: `{"userName":{"$options":"i","$regex":"^\\^j"}}`},
+		{filter: `userName ew "$"`, expected: `{"userName":{"$options":"i","$regex":"\\$$"}}`},
+		{filter: `id sw "2819"`, expected: `{"_id":{"$regex":"^2819"}}`},
+		{filter: `id eq "2819c223"`, expected: `{"_id":"2819c223"}`},
+		{filter: `id ne "2819c223"`, expected: `{"_id":{"$ne":"2819c223"}}`},
+		{
+			filter:    `id eq "2819c223" or userName eq "bjensen"`,
+			expected:  `{"$or":[{"_id":{"$regex":"^2819c223$"}},{"userName":"bjensen"}]}`,
+			collation: true,
+		},
+		{
+			filter:    `id ne "2819c223" and userName eq "bjensen"`,
+			expected:  `{"$and":[{"_id":{"$not":{"$regex":"^2819c223$"}}},{"userName":"bjensen"}]}`,
+			collation: true,
+		},
+		{filter: `userName pr`, expected: `{"userName":{"$exists":true,"$ne":null}}`},
+		{filter: `userName eq null`, expected: `{"userName":null}`},
+		{filter: `userName ne null`, expected: `{"userName":{"$ne":null}}`},
+		{filter: `active eq true`, expected: `{"active":true}`},
+		{filter: `active ne false`, expected: `{"active":{"$ne":false}}`},
+		{filter: `meta.created ge "2011-05-13T04:42:34Z"`, expected: `{"meta.created":{"$gte":"2011-05-13T04:42:34Z"}}`},
+		{filter: `meta.created le "2011-05-13T04:42:34Z"`, expected: `{"meta.created":{"$lte":"2011-05-13T04:42:34Z"}}`},
+		{filter: `urn:ietf:params:scim:schemas:extension:enterprise:2.0:User:employeeNumber lt 701984`, expected: `{"enterprise.employeeNumber":{"$lt":701984}}`},
+		{filter: `urn:ietf:params:scim:schemas:core:2.0:User:active eq true`, expected: `{"active":true}`},
+		{filter: `emails.value ew "@example.com"`, expected: `{"emails.value":{"$options":"i","$regex":"@example\\.com$"}}`},
+		{
+			filter:   `active eq true and userName pr and name.familyName pr`,
+			expected: `{"$and":[{"active":true},{"userName":{"$exists":true,"$ne":null}},{"name.familyName":{"$exists":true,"$ne":null}}]}`,
+		},
+		{
+			filter:   `active eq true or userName pr and name.familyName pr`,
+			expected: `{"$or":[{"active":true},{"$and":[{"userName":{"$exists":true,"$ne":null}},{"name.familyName":{"$exists":true,"$ne":null}}]}]}`,
+		},
+		{filter: `not (active eq true)`, expected: `{"$nor":[{"active":true}]}`},
+		{
+			filter:   `emails[type co "work" and not (primary eq true)]`,
+			expected: `{"emails":{"$elemMatch":{"$and":[{"type":{"$options":"i","$regex":"work"}},{"$nor":[{"primary":true}]}]}}}`,
+		},
+	} {
+		t.Run(test.filter, func(t *testing.T) {
+			expression, err := filter.ParseFilter([]byte(test.filter))
+			if err != nil {
+				t.Fatal(err)
+			}
+			query, err := Translate(expression, mapping)
+			if err != nil {
+				t.Fatal(err)
+			}
+			raw, err := json.Marshal(query.Filter)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(raw) != test.expected {
+				t.Errorf("expected %s, got %s", test.expected, raw)
+			}
+			if (query.Collation != nil) != test.collation {
+				t.Errorf("expected collation to be %t, got %v", test.collation, query.Collation)
+			}
+		})
+	}
+}
+
+func TestTranslate_invalid(t *testing.T) {
+	for _, test := range []struct {
+		filter  string
+		mapping Mapping
+	}{
+		{filter: `nickName eq "Babs"`},
+		{filter: `active gt true`},
+		{filter: `userName co 1`},
+		{filter: `userName lt null`},
+		{filter: `emails[display eq "x"]`},
+		{filter: `emails[type eq "work"]`, mapping: Mapping{"emails": {Name: "emails"}, "emails.type": {Name: "types"}}},
+		{filter: `userName eq 92233720368547758070`},
+	} {
+		t.Run(test.filter, func(t *testing.T) {
+			if test.mapping == nil {
+				test.mapping = mapping
+			}
+			expression, err := filter.ParseFilter([]byte(test.filter))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, err := Translate(expression, test.mapping); err == nil {
+				t.Error("expected an error")
+			}
+		})
+	}
+}
@@ -0,0 +1,257 @@
+// Package normalize rewrites filter expressions to equivalent expressions in a
+// normal form, e.g. for storage backends that only accept disjunctions of
+// conjunctions.
+//
+// Logical expressions are binary, chains of the same logical operator are
+// represented as left-nested expressions: "a and b and c" is "(a and b) and c".
+// Operands and Join convert between chains and their operands.
+//
+// None of the functions modify the given expression, the attribute paths of the
+// result still share their URI prefix and sub-attribute with it.
+package normalize
+
+import (
+	"fmt"
+	filter "github.com/scim2/filter-parser/v2"
+	"strings"
+)
+
+// CNF returns the conjunctive normal form of the given expression, i.e. a
+// conjunction ('and') of disjunctions ('or') of literals. A literal is an
+// attribute expression, a value path or the negation of one of these. The
+// value filters of value paths are converted to negation normal form.
+//
+// An error is returned if the result has more than the given maximum number of
+// terms (disjunctions), since the normal form can be exponentially larger than
+// the expression. A maximum of zero means that there is no limit.
+func CNF(expr filter.Expression, maxTerms int) (filter.Expression, error) {
+	terms, err := normalForm(NNF(expr), filter.OR, maxTerms)
+	if err != nil {
+		return nil, err
+	}
+	return join(filter.AND, filter.OR, terms), nil
+}
+
+// DNF returns the disjunctive normal form of the given expression, i.e. a
+// disjunction ('or') of conjunctions ('and') of literals. A literal is an
+// attribute expression, a value path or the negation of one of these. The
+// value filters of value paths are converted to negation normal form.
+//
+// An error is returned if the result has more than the given maximum number of
+// terms (conjunctions), since the normal form can be exponentially larger than
+// the expression. A maximum of zero means that there is no limit.
+func DNF(expr filter.Expression, maxTerms int) (filter.Expression, error) {
+	terms, err := normalForm(NNF(expr), filter.AND, maxTerms)
+	if err != nil {
+		return nil, err
+	}
+	return join(filter.OR, filter.AND, terms), nil
+}
+
+// Flatten returns the given expression in which all chains of the same logical
+// operator are left-nested, e.g. "a and (b and c)" becomes "(a and b) and c".
+// This includes the chains within 'not' and value filters.
+func Flatten(expr filter.Expression) filter.Expression {
+	switch e := expr.(type) {
+	case *filter.AttributeExpression:
+		attrExp := *e
+		return &attrExp
+	case *filter.LogicalExpression:
+		operands := Operands(e, e.Operator)
+		for i, operand := range operands {
+			operands[i] = Flatten(operand)
+		}
+		return Join(e.Operator, operands...)
+	case *filter.NotExpression:
+		return &filter.NotExpression{
+			Expression: Flatten(e.Expression),
+			Span:       e.Span,
+		}
+	case *filter.ValuePath:
+		return &filter.ValuePath{
+			AttributePath: e.AttributePath,
+			ValueFilter:   Flatten(e.ValueFilter),
+			Span:          e.Span,
+		}
+	default:
+		return expr
+	}
+}
+
+// Join joins the given operands with the given logical operator into a
+// left-nested chain. It returns the operand itself if there is only one, and
+// nil if there are none.
+func Join(operator filter.LogicalOperator, operands ...filter.Expression) filter.Expression {
+	if len(operands) == 0 {
+		return nil
+	}
+	expr := operands[0]
+	for _, operand := range operands[1:] {
+		expr = &filter.LogicalExpression{
+			Left:     expr,
+			Right:    operand,
+			Operator: operator,
+		}
+	}
+	return expr
+}
+
+// NNF returns the negation normal form of the given expression, in which 'not'
+// only applies to attribute expressions and value paths. Negations are pushed
+// down with De Morgan's laws and double negations are removed:
+//
+//	not (a and b) => not a or not b
+//	not (a or b)  => not a and not b
+//	not (not a)   => a
+//
+// The 'eq' and 'ne' operators are inverted instead of negated, since 'ne' is
+// the negation of 'eq' (it also matches unassigned attributes). Other
+// operators can not be inverted, e.g. "not (age gt 5)" also matches resources
+// without an age, while "age le 5" does not. The negation of a value path is
+// not pushed into its value filter: "not (emails[type eq "work"])" does not
+// match if any email is of type work, "emails[type ne "work"]" matches if any
+// email is not.
+//
+// Logical operators are lowercased and chains are flattened.
+func NNF(expr filter.Expression) filter.Expression {
+	return Flatten(nnf(expr, false))
+}
+
+// Operands returns the operands of the chain of the given logical operator, e.g.
+// [a, b, c] for "a and (b and c)" and the 'and' operator. It returns the
+// expression itself if it is not a logical expression with that operator.
+func Operands(expr filter.Expression, operator filter.LogicalOperator) []filter.Expression {
+	e, ok := expr.(*filter.LogicalExpression)
+	if !ok || !strings.EqualFold(string(e.Operator), string(operator)) {
+		return []filter.Expression{expr}
+	}
+	return append(Operands(e.Left, operator), Operands(e.Right, operator)...)
+}
+
+// dual returns the other logical operator.
+func dual(operator filter.LogicalOperator) filter.LogicalOperator {
+	if operator == filter.AND {
+		return filter.OR
+	}
+	return filter.AND
+}
+
+// join joins the given terms, which are joined with the inner operator, with the
+// outer operator.
+func join(outer, inner filter.LogicalOperator, terms [][]filter.Expression) filter.Expression {
+	operands := make([]filter.Expression, len(terms))
+	for i, term := range terms {
+		operands[i] = Join(inner, term...)
+	}
+	return Join(outer, operands...)
+}
+
+// nnf pushes the negation down to the literals of the given expression, if
+// negate is true.
+func nnf(expr filter.Expression, negate bool) filter.Expression {
+	switch e := expr.(type) {
+	case *filter.AttributeExpression:
+		attrExp := *e
+		if !negate {
+			return &attrExp
+		}
+		switch filter.CompareOperator(strings.ToLower(string(e.Operator))) {
+		case filter.EQ:
+			attrExp.Operator = filter.NE
+		case filter.NE:
+			attrExp.Operator = filter.EQ
+		default:
+			return &filter.NotExpression{
+				Expression: &attrExp,
+			}
+		}
+		return &attrExp
+	case *filter.LogicalExpression:
+		operator := filter.LogicalOperator(strings.ToLower(string(e.Operator)))
+		if negate {
+			operator = dual(operator)
+		}
+		return &filter.LogicalExpression{
+			Left:     nnf(e.Left, negate),
+			Right:    nnf(e.Right, negate),
+			Operator: operator,
+			Span:     e.Span,
+		}
+	case *filter.NotExpression:
+		return nnf(e.Expression, !negate)
+	case *filter.ValuePath:
+		valuePath := &filter.ValuePath{
+			AttributePath: e.AttributePath,
+			ValueFilter:   nnf(e.ValueFilter, false),
+			Span:          e.Span,
+		}
+		if negate {
+			return &filter.NotExpression{
+				Expression: valuePath,
+			}
+		}
+		return valuePath
+	default:
+		if negate {
+			return &filter.NotExpression{
+				Expression: expr,
+			}
+		}
+		return expr
+	}
+}
+
+// normalForm returns the terms of the normal form of the given expression, which
+// is in negation normal form. The literals of each term are joined with the
+// given inner operator, the terms with its dual.
+func normalForm(expr filter.Expression, inner filter.LogicalOperator, maxTerms int) ([][]filter.Expression, error) {
+	e, ok := expr.(*filter.LogicalExpression)
+	if !ok {
+		return [][]filter.Expression{{expr}}, nil
+	}
+	left, err := normalForm(e.Left, inner, maxTerms)
+	if err != nil {
+		return nil, err
+	}
+	right, err := normalForm(e.Right, inner, maxTerms)
+	if err != nil {
+		return nil, err
+	}
+
+	if e.Operator != inner {
+		// e.g. DNF: (a and b) or (c and d) => [[a, b], [c, d]]
+		if maxTerms > 0 && len(left)+len(right) > maxTerms {
+			return nil, &LimitError{Max: maxTerms}
+		}
+		return append(left, right...), nil
+	}
+	// e.g. DNF: (a or b) and (c or d) => [[a, c], [a, d], [b, c], [b, d]]
+	if maxTerms > 0 && len(left)*len(right) > maxTerms {
+		return nil, &LimitError{Max: maxTerms}
+	}
+	terms := make([][]filter.Expression, 0, len(left)*len(right))
+	for _, l := range left {
+		for _, r := range right {
+			term := make([]filter.Expression, 0, len(l)+len(r))
+			terms = append(terms, append(append(term, l...), r...))
+		}
+	}
+	return terms, nil
+}
+
+// LimitError is returned if a normal form has more terms than allowed.
+type LimitError struct {
+	// Max is the maximum number of terms.
+	Max int
+}
+
+func (e *LimitError) Error() string {
+	return fmt.Sprintf("normal form exceeds the maximum of %d terms", e.Max)
+}
+
+// ScimType returns the SCIM detail error keyword of the error, "tooMany".
+//
+// More info: https://tools.ietf.org/html/rfc7644#section-3.12
+func (e *LimitError) ScimType() string {
+	return "tooMany"
+}
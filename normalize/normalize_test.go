@@ -0,0 +1,236 @@
+package normalize
+
+import (
+	"errors"
+	"fmt"
+	filter "github.com/scim2/filter-parser/v2"
+	"testing"
+)
+
+func ExampleDNF() {
+	expression, _ := filter.ParseFilter([]byte("title pr and (userType eq \"Employee\" or not (emails co \"example.com\" and active eq true))"))
+	dnf, _ := DNF(expression, 16)
+	fmt.Println(filter.Format(dnf))
+	// Output:
+	// title pr and userType eq "Employee" or title pr and not (emails co "example.com") or title pr and active ne true
+}
+
+func ExampleNNF() {
+	expression, _ := filter.ParseFilter([]byte("not (userName eq \"bjensen\" or not (emails[type eq \"work\"] and age gt 21))"))
+	fmt.Println(filter.Format(NNF(expression)))
+	// Output:
+	// userName ne "bjensen" and emails[type eq "work"] and age gt 21
+}
+
+func ExampleOperands() {
+	expression, _ := filter.ParseFilter([]byte("a pr and (b pr and c pr) or d pr"))
+	for _, operand := range Operands(expression, filter.OR) {
+		fmt.Println(filter.Format(operand))
+	}
+	// Output:
+	// a pr and (b pr and c pr)
+	// d pr
+}
+
+func TestCNF(t *testing.T) {
+	for _, test := range []struct {
+		filter   string
+		expected string
+	}{
+		{filter: `a pr`, expected: `a pr`},
+		{filter: `a pr or b pr and c pr`, expected: `(a pr or b pr) and (a pr or c pr)`},
+		{filter: `(a pr and b pr) or (c pr and d pr)`, expected: `(a pr or c pr) and (a pr or d pr) and (b pr or c pr) and (b pr or d pr)`},
+		{filter: `not (a pr and b pr)`, expected: `not (a pr) or not (b pr)`},
+		{filter: `not (a pr or b eq 1)`, expected: `not (a pr) and b ne 1`},
+	} {
+		t.Run(test.filter, func(t *testing.T) {
+			expression, err := filter.ParseFilter([]byte(test.filter))
+			if err != nil {
+				t.Fatal(err)
+			}
+			cnf, err := CNF(expression, 0)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if s := filter.Format(cnf); s != test.expected {
+				t.Errorf("expected %s, got %s", test.expected, s)
+			}
+		})
+	}
+}
+
+func TestDNF(t *testing.T) {
+	for _, test := range []struct {
+		filter   string
+		expected string
+	}{
+		{filter: `a pr`, expected: `a pr`},
+		{filter: `a pr and (b pr or c pr)`, expected: `a pr and b pr or a pr and c pr`},
+		{filter: `(a pr or b pr) and (c pr or d pr)`, expected: `a pr and c pr or a pr and d pr or b pr and c pr or b pr and d pr`},
+		{filter: `a pr and b pr and c pr`, expected: `a pr and b pr and c pr`},
+		{filter: `a pr or (b pr or c pr)`, expected: `a pr or b pr or c pr`},
+		{filter: `not (a pr or b pr)`, expected: `not (a pr) and not (b pr)`},
+		{filter: `not (not (a pr))`, expected: `a pr`},
+		{filter: `not (a eq "x" and b ne "y")`, expected: `a ne "x" or b eq "y"`},
+		{filter: `not (a gt 1 or a EQ null)`, expected: `not (a gt 1) and a ne null`},
+		{filter: `emails[not (type eq "work" or primary eq true)]`, expected: `emails[type ne "work" and primary ne true]`},
+		{filter: `not (emails[type eq "work"] and a pr)`, expected: `not (emails[type eq "work"]) or not (a pr)`},
+		{
+			filter:   `emails[type eq "work" and (value co "a" or value co "b")] and (a pr or b pr)`,
+			expected: `emails[type eq "work" and (value co "a" or value co "b")] and a pr or emails[type eq "work" and (value co "a" or value co "b")] and b pr`,
+		},
+	} {
+		t.Run(test.filter, func(t *testing.T) {
+			expression, err := filter.ParseFilter([]byte(test.filter))
+			if err != nil {
+				t.Fatal(err)
+			}
+			dnf, err := DNF(expression, 0)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if s := filter.Format(dnf); s != test.expected {
+				t.Errorf("expected %s, got %s", test.expected, s)
+			}
+		})
+	}
+}
+
+func TestDNF_limit(t *testing.T) {
+	expression, err := filter.ParseFilter([]byte("(a pr or b pr) and (c pr or d pr) and (e pr or f pr)"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := DNF(expression, 8); err != nil {
+		t.Fatal(err)
+	}
+	_, err = DNF(expression, 7)
+	if limitErr, ok := errors.AsType[*LimitError](err); !ok || limitErr.Max != 7 {
+		t.Errorf("expected a limit error, got %v", err)
+	}
+	// The CNF of the expression is the expression itself.
+	if _, err := CNF(expression, 3); err != nil {
+		t.Error(err)
+	}
+	if _, err := CNF(expression, 2); err == nil {
+		t.Error("expected an error")
+	}
+}
+
+// TestEquivalence checks that the normal forms match the same resources as the
+// original expression.
+func TestEquivalence(t *testing.T) {
+	for _, raw := range []string{
+		`not (a eq "x" and b gt 1)`,
+		`not (a pr or not (b ne 2 and c eq "y"))`,
+		`(a pr or b lt 3) and not (c ne "x" or a eq null)`,
+		`not (c eq "x") and (a ne "x" or not (b ge 2))`,
+		`not (emails[type eq "work" and not (primary eq true)] or c co "z")`,
+		`emails[not (type ne "home" or primary pr)] or not (b eq 2 and (a pr or c sw "y"))`,
+	} {
+		t.Run(raw, func(t *testing.T) {
+			expression, err := filter.ParseFilter([]byte(raw))
+			if err != nil {
+				t.Fatal(err)
+			}
+			dnf, err := DNF(expression, 0)
+			if err != nil {
+				t.Fatal(err)
+			}
+			cnf, err := CNF(expression, 0)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, resource := range testResources() {
+				expected, err := filter.Evaluate(expression, resource)
+				if err != nil {
+					t.Fatal(err)
+				}
+				for _, normalized := range []filter.Expression{NNF(expression), dnf, cnf} {
+					match, err := filter.Evaluate(normalized, resource)
+					if err != nil {
+						t.Fatal(err)
+					}
+					if match != expected {
+						t.Errorf("%s: expected %v, got %v for %v", filter.Format(normalized), expected, match, resource)
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestFlatten(t *testing.T) {
+	for _, test := range []struct {
+		filter   string
+		expected string
+	}{
+		{filter: `a pr and (b pr and (c pr and d pr))`, expected: `a pr and b pr and c pr and d pr`},
+		{filter: `(a pr or b pr) and (c pr or (d pr or e pr))`, expected: `(a pr or b pr) and (c pr or d pr or e pr)`},
+		{filter: `not (a pr and (b pr and c pr))`, expected: `not (a pr and b pr and c pr)`},
+		{filter: `emails[a pr or (b pr or c pr)]`, expected: `emails[a pr or b pr or c pr]`},
+	} {
+		t.Run(test.filter, func(t *testing.T) {
+			expression, err := filter.ParseFilter([]byte(test.filter))
+			if err != nil {
+				t.Fatal(err)
+			}
+			flat := Flatten(expression)
+			if s := filter.Format(flat); s != test.expected {
+				t.Errorf("expected %s, got %s", test.expected, s)
+			}
+			// Every operand of the chain is reachable through the left side.
+			e, ok := flat.(*filter.LogicalExpression)
+			for ok {
+				if r, isLogical := e.Right.(*filter.LogicalExpression); isLogical && r.Operator == e.Operator {
+					t.Errorf("right operand is not flattened: %s", filter.Format(r))
+				}
+				e, ok = e.Left.(*filter.LogicalExpression)
+			}
+		})
+	}
+}
+
+func TestJoin(t *testing.T) {
+	if Join(filter.AND) != nil {
+		t.Error("expected nil")
+	}
+	a := &filter.AttributeExpression{AttributePath: filter.AttributePath{AttributeName: "a"}, Operator: filter.PR}
+	if Join(filter.AND, a) != a {
+		t.Error("expected the operand itself")
+	}
+	b := &filter.AttributeExpression{AttributePath: filter.AttributePath{AttributeName: "b"}, Operator: filter.PR}
+	if s := filter.Format(Join(filter.OR, a, b, a)); s != "a pr or b pr or a pr" {
+		t.Errorf("unexpected join: %s", s)
+	}
+}
+
+// testResources returns resources with combinations of values for the
+// attributes that are used in the equivalence tests.
+func testResources() []map[string]any {
+	var resources []map[string]any
+	for i := range 1 << 5 {
+		for _, b := range []any{nil, 2, 7, []any{2, 7}} {
+			resource := map[string]any{
+				"emails": []any{
+					map[string]any{"type": "work", "primary": i&1 != 0},
+					map[string]any{"type": "home"},
+				},
+			}
+			if i&2 != 0 {
+				resource["a"] = "x"
+			}
+			if b != nil {
+				resource["b"] = b
+			}
+			if i&4 != 0 {
+				resource["c"] = []any{"x", "y"}
+			}
+			if i&8 != 0 {
+				resource["active"] = i&16 != 0
+			}
+			resources = append(resources, resource)
+		}
+	}
+	return resources
+}
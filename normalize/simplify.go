@@ -0,0 +1,435 @@
+package normalize
+
+import (
+	"encoding/json"
+	filter "github.com/scim2/filter-parser/v2"
+	"math"
+	"math/big"
+	"strings"
+	"time"
+)
+
+const (
+	// Unknown means that whether the expression matches depends on the
+	// resource.
+	Unknown Truth = iota
+	// AlwaysTrue means that the expression matches every resource.
+	AlwaysTrue
+	// AlwaysFalse means that the expression never matches. A query with such a
+	// filter does not need to be executed.
+	AlwaysFalse
+)
+
+var (
+	// trueExpr is the expression that Simplify returns for filters that match
+	// every resource: "id pr or not (id pr)".
+	trueExpr filter.Expression = &filter.LogicalExpression{
+		Left:     &filter.AttributeExpression{AttributePath: filter.AttributePath{AttributeName: "id"}, Operator: filter.PR},
+		Right:    &filter.NotExpression{Expression: &filter.AttributeExpression{AttributePath: filter.AttributePath{AttributeName: "id"}, Operator: filter.PR}},
+		Operator: filter.OR,
+	}
+	// falseExpr is the expression that Simplify returns for filters that never
+	// match: "id pr and not (id pr)".
+	falseExpr filter.Expression = &filter.LogicalExpression{
+		Left:     &filter.AttributeExpression{AttributePath: filter.AttributePath{AttributeName: "id"}, Operator: filter.PR},
+		Right:    &filter.NotExpression{Expression: &filter.AttributeExpression{AttributePath: filter.AttributePath{AttributeName: "id"}, Operator: filter.PR}},
+		Operator: filter.AND,
+	}
+)
+
+// SingleValued marks the given attributes (e.g. "active" or "name.givenName")
+// as single-valued. The attribute paths are matched case-insensitively. An
+// attribute path with a URI prefix falls back to the attribute without the
+// prefix.
+func SingleValued(paths ...string) Option {
+	return func(s *simplifier) {
+		for _, path := range paths {
+			s.singleValued[strings.ToLower(path)] = true
+		}
+	}
+}
+
+// Simplify returns a simplified expression that matches the same resources as
+// the given expression (as evaluated by filter.Evaluate). The expression is
+// converted to negation normal form (see NNF), after which the operands of each
+// chain are simplified:
+//   - duplicate operands are removed, attribute names and operators are
+//     compared case-insensitively,
+//   - "x and not (x)" is folded to false and "x or not (x)" to true, which
+//     includes "x eq v and x ne v" and "x pr and x eq null",
+//   - ranges on the same attribute are merged, e.g. "x gt 5 and x ge 7" to
+//     "x ge 7" and "x gt 5 or x ge 7" to "x gt 5". Only numbers and RFC 3339
+//     timestamps are merged.
+//
+// Conflicting conditions, e.g. "x eq 1 and x eq 2" or "x gt 5 and x lt 3", are
+// folded to false if the attribute is single-valued, since a multi-valued
+// attribute can have a value that matches each condition. The sub-attributes
+// within value filters are single-valued, other attributes can be marked as
+// single-valued with the SingleValued option.
+//
+// Simplify also returns whether the expression always or never matches, in
+// which case the returned expression is "id pr or not (id pr)" or "id pr and
+// not (id pr)" respectively.
+func Simplify(expr filter.Expression, opts ...Option) (filter.Expression, Truth) {
+	s := simplifier{
+		singleValued: make(map[string]bool),
+	}
+	for _, opt := range opts {
+		opt(&s)
+	}
+	simplified, value := s.simplify(NNF(expr), nil)
+	switch value {
+	case AlwaysTrue:
+		return trueExpr, value
+	case AlwaysFalse:
+		return falseExpr, value
+	default:
+		return simplified, value
+	}
+}
+
+// compareValues compares two compare values, if both are numbers or both are
+// RFC 3339 timestamps.
+func compareValues(a, b any) (int, bool) {
+	if x, ok := toRat(a); ok {
+		if y, ok := toRat(b); ok {
+			return x.Cmp(y), true
+		}
+		return 0, false
+	}
+	sa, ok := a.(string)
+	if !ok {
+		return 0, false
+	}
+	sb, ok := b.(string)
+	if !ok {
+		return 0, false
+	}
+	ta, err := time.Parse(time.RFC3339Nano, sa)
+	if err != nil {
+		return 0, false
+	}
+	tb, err := time.Parse(time.RFC3339Nano, sb)
+	if err != nil {
+		return 0, false
+	}
+	return ta.Compare(tb), true
+}
+
+// conflicts checks whether the given compare values of the 'eq' operator can
+// not both match a single value.
+func conflicts(a, b any) bool {
+	switch a := a.(type) {
+	case bool:
+		b, ok := b.(bool)
+		return ok && a != b
+	case string:
+		b, ok := b.(string)
+		return ok && !strings.EqualFold(a, b)
+	}
+	c, ok := compareValues(a, b)
+	return ok && c != 0
+}
+
+// key returns a canonical representation of the given expression, in which
+// attribute names and operators are lowercased and comparisons with null are
+// replaced by (negated) 'pr' operators.
+func key(expr filter.Expression) string {
+	return filter.Format(filter.Rewrite(expr, func(expr filter.Expression) filter.Expression {
+		switch e := expr.(type) {
+		case *filter.AttributeExpression:
+			e.AttributePath = lowerPath(e.AttributePath)
+			e.Operator = filter.CompareOperator(strings.ToLower(string(e.Operator)))
+			if e.CompareValue == nil {
+				switch e.Operator {
+				case filter.EQ:
+					e.Operator = filter.PR
+					return &filter.NotExpression{Expression: e}
+				case filter.NE:
+					e.Operator = filter.PR
+				}
+			}
+		case *filter.LogicalExpression:
+			e.Operator = filter.LogicalOperator(strings.ToLower(string(e.Operator)))
+		case *filter.ValuePath:
+			e.AttributePath = lowerPath(e.AttributePath)
+		}
+		return expr
+	}))
+}
+
+// lowerPath returns the given attribute path in lower case.
+func lowerPath(path filter.AttributePath) filter.AttributePath {
+	lower := func(s *string) *string {
+		if s == nil {
+			return nil
+		}
+		l := strings.ToLower(*s)
+		return &l
+	}
+	path.URIPrefix = lower(path.URIPrefix)
+	path.AttributeName = strings.ToLower(path.AttributeName)
+	path.SubAttribute = lower(path.SubAttribute)
+	return path
+}
+
+// toRat converts the given numeric compare value to a *big.Rat.
+func toRat(value any) (*big.Rat, bool) {
+	switch v := value.(type) {
+	case int:
+		return new(big.Rat).SetInt64(int64(v)), true
+	case int64:
+		return new(big.Rat).SetInt64(v), true
+	case float64:
+		if math.IsNaN(v) || math.IsInf(v, 0) {
+			return nil, false
+		}
+		return new(big.Rat).SetFloat64(v), true
+	case json.Number:
+		return new(big.Rat).SetString(string(v))
+	case *big.Int:
+		return new(big.Rat).SetInt(v), true
+	case *big.Rat:
+		return v, true
+	default:
+		return nil, false
+	}
+}
+
+// Option configures how an expression is simplified.
+type Option func(*simplifier)
+
+// Truth tells whether an expression always or never matches.
+type Truth int
+
+// bound is a lower ('gt', 'ge') or upper ('lt', 'le') bound on an attribute.
+type bound struct {
+	// index is the index of the operand.
+	index     int
+	value     any
+	inclusive bool
+}
+
+// bounds holds the conditions on a single attribute within a chain.
+type bounds struct {
+	path         filter.AttributePath
+	lower, upper *bound
+	// equal holds the indices of the operands with the 'eq' operator.
+	equal []int
+}
+
+// conflict checks whether the conditions on a single-valued attribute can not
+// all match.
+func (b *bounds) conflict(operands []filter.Expression) bool {
+	if b.lower != nil && b.upper != nil {
+		c, ok := compareValues(b.lower.value, b.upper.value)
+		if ok && (c > 0 || c == 0 && !(b.lower.inclusive && b.upper.inclusive)) {
+			return true
+		}
+	}
+	for i, x := range b.equal {
+		v := operands[x].(*filter.AttributeExpression).CompareValue
+		for _, y := range b.equal[i+1:] {
+			if conflicts(v, operands[y].(*filter.AttributeExpression).CompareValue) {
+				return true
+			}
+		}
+		if b.lower != nil {
+			c, ok := compareValues(v, b.lower.value)
+			if ok && (c < 0 || c == 0 && !b.lower.inclusive) {
+				return true
+			}
+		}
+		if b.upper != nil {
+			c, ok := compareValues(v, b.upper.value)
+			if ok && (c > 0 || c == 0 && !b.upper.inclusive) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+type simplifier struct {
+	// singleValued contains the (lowercased) attribute paths of single-valued
+	// attributes.
+	singleValued map[string]bool
+}
+
+// isSingleValued checks whether the given attribute path refers to a
+// single-valued attribute. If the parent is not nil, the path is within the
+// value filter of that attribute path.
+func (s *simplifier) isSingleValued(path filter.AttributePath, parent *filter.AttributePath) bool {
+	if parent != nil {
+		if path.URIPrefix == nil && path.SubAttribute == nil {
+			return true
+		}
+		return false
+	}
+	if s.singleValued[strings.ToLower(path.String())] {
+		return true
+	}
+	if path.URIPrefix == nil {
+		return false
+	}
+	path.URIPrefix = nil
+	return s.singleValued[strings.ToLower(path.String())]
+}
+
+// merge merges the ranges on the same attribute within the given operands of a
+// chain of the given operator and detects conflicting conditions.
+func (s *simplifier) merge(operator filter.LogicalOperator, operands []filter.Expression, parent *filter.AttributePath) ([]filter.Expression, Truth) {
+	isOr := operator == filter.OR
+	removed := make([]bool, len(operands))
+	// tighten replaces the given bound by b if b is the one to keep, and
+	// removes the other one.
+	tighten := func(current **bound, b *bound, less bool) {
+		if *current == nil {
+			*current = b
+			return
+		}
+		c, ok := compareValues(b.value, (*current).value)
+		if !ok {
+			return
+		}
+		if (less && c < 0) || (!less && c > 0) || (c == 0 && b.inclusive == isOr) {
+			removed[(*current).index] = true
+			*current = b
+		} else {
+			removed[b.index] = true
+		}
+	}
+
+	var attributes []*bounds
+	byPath := make(map[string]*bounds)
+	for i, operand := range operands {
+		e, ok := operand.(*filter.AttributeExpression)
+		if !ok {
+			continue
+		}
+		k := strings.ToLower(e.AttributePath.String())
+		b, ok := byPath[k]
+		if !ok {
+			b = &bounds{path: e.AttributePath}
+			byPath[k] = b
+			attributes = append(attributes, b)
+		}
+		switch op := filter.CompareOperator(strings.ToLower(string(e.Operator))); op {
+		case filter.GT, filter.GE, filter.LT, filter.LE:
+			if _, ok := compareValues(e.CompareValue, e.CompareValue); !ok {
+				continue
+			}
+			bound := &bound{index: i, value: e.CompareValue, inclusive: op == filter.GE || op == filter.LE}
+			if op == filter.GT || op == filter.GE {
+				// The most restrictive lower bound for 'and', the least
+				// restrictive for 'or'.
+				tighten(&b.lower, bound, isOr)
+			} else {
+				tighten(&b.upper, bound, !isOr)
+			}
+		case filter.EQ:
+			if e.CompareValue != nil {
+				b.equal = append(b.equal, i)
+			}
+		}
+	}
+
+	if !isOr {
+		for _, b := range attributes {
+			if s.isSingleValued(b.path, parent) && b.conflict(operands) {
+				return nil, AlwaysFalse
+			}
+		}
+	}
+	var merged []filter.Expression
+	for i, operand := range operands {
+		if !removed[i] {
+			merged = append(merged, operand)
+		}
+	}
+	return merged, Unknown
+}
+
+// simplify simplifies the given expression, which is in negation normal form.
+// If the parent is not nil, the expression is the value filter of that
+// attribute path. The returned expression is nil if it is always true or false.
+func (s *simplifier) simplify(expr filter.Expression, parent *filter.AttributePath) (filter.Expression, Truth) {
+	switch e := expr.(type) {
+	case *filter.LogicalExpression:
+		return s.simplifyOperands(e.Operator, Operands(e, e.Operator), parent)
+	case *filter.NotExpression:
+		expression, value := s.simplify(e.Expression, parent)
+		switch value {
+		case AlwaysTrue:
+			return nil, AlwaysFalse
+		case AlwaysFalse:
+			return nil, AlwaysTrue
+		}
+		return &filter.NotExpression{
+			Expression: expression,
+			Span:       e.Span,
+		}, Unknown
+	case *filter.ValuePath:
+		valueFilter, value := s.simplify(e.ValueFilter, &e.AttributePath)
+		switch value {
+		case AlwaysTrue:
+			// The value path still requires a complex value to be present.
+			return e, Unknown
+		case AlwaysFalse:
+			return nil, AlwaysFalse
+		}
+		return &filter.ValuePath{
+			AttributePath: e.AttributePath,
+			ValueFilter:   valueFilter,
+			Span:          e.Span,
+		}, Unknown
+	default:
+		return expr, Unknown
+	}
+}
+
+// simplifyOperands simplifies the operands of a chain of the given operator.
+func (s *simplifier) simplifyOperands(operator filter.LogicalOperator, operands []filter.Expression, parent *filter.AttributePath) (filter.Expression, Truth) {
+	operator = filter.LogicalOperator(strings.ToLower(string(operator)))
+	// e.g. 'false' absorbs 'and', 'true' is its identity.
+	absorbing, identity := AlwaysFalse, AlwaysTrue
+	if operator == filter.OR {
+		absorbing, identity = AlwaysTrue, AlwaysFalse
+	}
+
+	var simplified []filter.Expression
+	for _, operand := range operands {
+		expr, value := s.simplify(operand, parent)
+		switch value {
+		case absorbing:
+			return nil, absorbing
+		case identity:
+			continue
+		}
+		simplified = append(simplified, Operands(expr, operator)...)
+	}
+
+	seen := make(map[string]bool)
+	var unique []filter.Expression
+	for _, operand := range simplified {
+		k := key(operand)
+		if seen[k] {
+			continue
+		}
+		if seen[key(nnf(operand, true))] {
+			// x and not (x) => false, x or not (x) => true
+			return nil, absorbing
+		}
+		seen[k] = true
+		unique = append(unique, operand)
+	}
+
+	merged, value := s.merge(operator, unique, parent)
+	if value != Unknown {
+		return nil, value
+	}
+	if len(merged) == 0 {
+		return nil, identity
+	}
+	return Join(operator, merged...), Unknown
+}
@@ -0,0 +1,169 @@
+package normalize
+
+import (
+	"fmt"
+	filter "github.com/scim2/filter-parser/v2"
+	"testing"
+)
+
+func ExampleSimplify() {
+	for _, raw := range []string{
+		`userName eq "a" or userName eq "a"`,
+		`age gt 5 and age gt 7 and title pr`,
+		`active eq true and active eq false`,
+		`title pr or not (title pr)`,
+	} {
+		expression, _ := filter.ParseFilter([]byte(raw))
+		switch simplified, truth := Simplify(expression, SingleValued("active")); truth {
+		case AlwaysFalse:
+			fmt.Println("unsatisfiable")
+		case AlwaysTrue:
+			fmt.Println("matches every resource")
+		default:
+			fmt.Println(filter.Format(simplified))
+		}
+	}
+	// Output:
+	// userName eq "a"
+	// age gt 7 and title pr
+	// unsatisfiable
+	// matches every resource
+}
+
+func TestSimplify(t *testing.T) {
+	for _, test := range []struct {
+		filter   string
+		expected string
+	}{
+		{filter: `a pr`, expected: `a pr`},
+		{filter: `a pr and A PR and b pr`, expected: `a pr and b pr`},
+		{filter: `a eq "x" or (b pr or a eq "x")`, expected: `a eq "x" or b pr`},
+		{filter: `a eq "x" or a eq "X"`, expected: `a eq "x" or a eq "X"`},
+		{filter: `a pr and not (a pr)`, expected: `false`},
+		{filter: `a pr or not (a pr)`, expected: `true`},
+		{filter: `a eq "x" and a ne "x"`, expected: `false`},
+		{filter: `a pr and a eq null`, expected: `false`},
+		{filter: `a ne null or not (a pr)`, expected: `true`},
+		{filter: `b pr and (a pr or not (a pr))`, expected: `b pr`},
+		{filter: `b pr or (a pr and not (a pr))`, expected: `b pr`},
+		{filter: `not (a pr and not (a pr))`, expected: `true`},
+		{filter: `b gt 5 and b gt 7`, expected: `b gt 7`},
+		{filter: `b gt 7 and b ge 7`, expected: `b gt 7`},
+		{filter: `b ge 7 or b gt 7`, expected: `b ge 7`},
+		{filter: `b gt 5 or b gt 7`, expected: `b gt 5`},
+		{filter: `b lt 5 and b le 3 and b lt 4`, expected: `b le 3`},
+		{filter: `b lt 5 or b le 3`, expected: `b lt 5`},
+		{filter: `b gt 5 and b lt 3`, expected: `b gt 5 and b lt 3`},
+		{filter: `b gt 5 and b lt "x"`, expected: `b gt 5 and b lt "x"`},
+		{filter: `b gt "2011-05-13T04:42:34Z" and b gt "2011-05-13T06:42:34+01:00"`, expected: `b gt "2011-05-13T06:42:34+01:00"`},
+		{filter: `b gt "a" and b gt "b"`, expected: `b gt "a" and b gt "b"`},
+		{filter: `emails.type eq "work" and emails.type eq "home"`, expected: `emails.type eq "work" and emails.type eq "home"`},
+		{filter: `emails[type eq "work" and type eq "home"]`, expected: `false`},
+		{filter: `emails[type eq "work" and type eq "WORK"]`, expected: `emails[type eq "work" and type eq "WORK"]`},
+		{filter: `emails[value gt 5 and value lt 3] or a pr`, expected: `a pr`},
+		{filter: `emails[value ge 5 and value le 5]`, expected: `emails[value ge 5 and value le 5]`},
+		{filter: `emails[value eq 4 and value ge 5]`, expected: `false`},
+		{filter: `emails[type pr or not (type pr)]`, expected: `emails[type pr or not (type pr)]`},
+		{filter: `not (emails[type eq "work" and type ne "work"])`, expected: `true`},
+		{filter: `not (a eq "x" or not (b pr))`, expected: `a ne "x" and b pr`},
+		{filter: `id pr or not (id pr)`, expected: `true`},
+		{filter: `id pr and not (id pr)`, expected: `false`},
+		{filter: `id pr and (id pr or not (id pr))`, expected: `id pr`},
+	} {
+		t.Run(test.filter, func(t *testing.T) {
+			expression, err := filter.ParseFilter([]byte(test.filter))
+			if err != nil {
+				t.Fatal(err)
+			}
+			var s string
+			switch simplified, truth := Simplify(expression); truth {
+			case AlwaysTrue:
+				s = "true"
+			case AlwaysFalse:
+				s = "false"
+			default:
+				s = filter.Format(simplified)
+			}
+			if s != test.expected {
+				t.Errorf("expected %s, got %s", test.expected, s)
+			}
+		})
+	}
+}
+
+func TestSimplify_equivalence(t *testing.T) {
+	for _, raw := range []string{
+		`a eq "x" or a eq "x" or b gt 1`,
+		`b gt 1 and b ge 2 and (b lt 7 or b le 3)`,
+		`b gt 2 or b ge 2 or b lt 7 or b le 7`,
+		`(b gt 5 and b lt 3) or a pr`,
+		`not (b le 2 or a eq null) and c ne "x"`,
+		`c eq "x" and c eq "y"`,
+		`active eq true and active eq false or a pr`,
+		`emails[type eq "work" and not (type eq "work")] or active ne false`,
+		`emails[primary eq true or primary ne true] and b ge 2 and b gt 1`,
+		`a pr or not (a pr)`,
+		`c eq "x" and c ne "x"`,
+	} {
+		t.Run(raw, func(t *testing.T) {
+			expression, err := filter.ParseFilter([]byte(raw))
+			if err != nil {
+				t.Fatal(err)
+			}
+			simplified, truth := Simplify(expression, SingleValued("a", "active"))
+			for _, resource := range testResources() {
+				expected, err := filter.Evaluate(expression, resource)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if truth != Unknown && expected != (truth == AlwaysTrue) {
+					t.Errorf("%s: expected %v for %v", raw, expected, resource)
+				}
+				match, err := filter.Evaluate(simplified, resource)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if match != expected {
+					t.Errorf("%s: expected %v, got %v for %v", filter.Format(simplified), expected, match, resource)
+				}
+			}
+		})
+	}
+}
+
+func TestSingleValued(t *testing.T) {
+	for _, test := range []struct {
+		filter   string
+		expected string
+	}{
+		{filter: `active eq true and active eq false`, expected: `false`},
+		{filter: `ACTIVE eq true and active eq false`, expected: `false`},
+		{filter: `active eq true and active eq true`, expected: `active eq true`},
+		{filter: `active eq true or active eq false`, expected: `active eq true or active eq false`},
+		{filter: `age gt 5 and age lt 3`, expected: `false`},
+		{filter: `age ge 5 and age le 5`, expected: `age ge 5 and age le 5`},
+		{filter: `age gt 5 and age le 5`, expected: `false`},
+		{filter: `age eq 7 and age gt 5`, expected: `age eq 7 and age gt 5`},
+		{filter: `age eq 5 and age gt 5`, expected: `false`},
+		{filter: `age eq 9 and age le 8`, expected: `false`},
+		{filter: `name.givenName eq "Babs" and name.givenName eq "Barbara"`, expected: `false`},
+		{filter: `urn:ietf:params:scim:schemas:core:2.0:User:name.givenName eq "a" and name.givenName eq "b"`, expected: `urn:ietf:params:scim:schemas:core:2.0:User:name.givenName eq "a" and name.givenName eq "b"`},
+		{filter: `urn:ietf:params:scim:schemas:core:2.0:User:age gt 5 and urn:ietf:params:scim:schemas:core:2.0:User:age lt 3`, expected: `false`},
+		{filter: `emails.type eq "work" and emails.type eq "home"`, expected: `emails.type eq "work" and emails.type eq "home"`},
+	} {
+		t.Run(test.filter, func(t *testing.T) {
+			expression, err := filter.ParseFilter([]byte(test.filter))
+			if err != nil {
+				t.Fatal(err)
+			}
+			simplified, truth := Simplify(expression, SingleValued("active", "Age", "name.givenName"))
+			s := filter.Format(simplified)
+			if truth == AlwaysFalse {
+				s = "false"
+			}
+			if s != test.expected {
+				t.Errorf("expected %s, got %s", test.expected, s)
+			}
+		})
+	}
+}
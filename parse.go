@@ -0,0 +1,262 @@
+package filter
+
+import (
+	"github.com/scim2/filter-parser/v2/internal/types"
+	"slices"
+	"strconv"
+)
+
+// describe returns a human-readable description of the given character.
+func describe(c byte) string {
+	if c == ' ' {
+		return "SP"
+	}
+	return strconv.Quote(string(c))
+}
+
+func isAlpha(c byte) bool {
+	return 'A' <= c && c <= 'Z' || 'a' <= c && c <= 'z'
+}
+
+func isDigit(c byte) bool {
+	return '0' <= c && c <= '9'
+}
+
+// parse parses the given raw data with the given grammar rule and makes sure
+// that all data is consumed. The type of the rule is used to describe errors
+// that occur outside the rule itself.
+//
+// The first pass does not keep track of failures, so that valid data is parsed
+// without any overhead. Invalid data is parsed a second time to find the
+// furthest position at which the parser failed, together with everything that
+// was expected at that position.
+func parse[T any](raw []byte, c config, ruleType int, rule func(*parser) (T, bool)) (T, error) {
+	if max := c.limits.MaxLength; max > 0 && len(raw) > max {
+		var zero T
+		return zero, &LimitError{
+			Limit:  LengthLimit,
+			Max:    max,
+			Offset: max,
+		}
+	}
+
+	p := newParser(raw, c, ruleType)
+	v, ok := rule(p)
+	if p.err != nil {
+		var zero T
+		return zero, p.err
+	}
+	if ok && p.pos == len(raw) {
+		return v, nil
+	}
+
+	p = newParser(raw, c, ruleType)
+	p.track = true
+	if _, ok := rule(p); ok {
+		p.fail(p.pos, "end of input")
+	}
+	var zero T
+	if p.furthest < 0 {
+		return zero, newParseError(raw, 0, ruleType, nil)
+	}
+	expected := p.expected
+	if len(expected) > 1 {
+		// Optional spaces are valid almost everywhere, only report them if
+		// nothing else was expected.
+		expected = slices.DeleteFunc(expected, func(s string) bool {
+			return s == "SP"
+		})
+	}
+	return zero, newParseError(raw, p.furthest, p.failed, expected)
+}
+
+// frame is the state of the parser at the start of a grammar rule.
+type frame struct {
+	start  int
+	before int
+	mark   int
+	parent int
+}
+
+// parser is a recursive descent parser for the grammar that is defined in
+// internal/grammar. Every grammar rule is implemented by a method that returns
+// whether the rule was matched. Methods do not restore the position of the
+// parser when they fail, that is up to the caller.
+type parser struct {
+	config
+
+	raw []byte
+	pos int
+	// err is an error that is not caused by the syntax of the raw data. Rules
+	// that contain other rules stop parsing once it is set.
+	err error
+
+	// valueFilter indicates that the parser is within a value filter.
+	valueFilter bool
+	// depth is the current nesting depth.
+	depth int
+	// terms is the number of attribute expressions that were parsed.
+	terms int
+
+	// track indicates that failures need to be tracked.
+	track bool
+	// rule is the type of the rule that is being parsed.
+	rule int
+
+	furthest int
+	failed   int
+	expected []string
+}
+
+func newParser(raw []byte, c config, ruleType int) *parser {
+	return &parser{
+		config:   c,
+		raw:      raw,
+		rule:     ruleType,
+		furthest: -1,
+	}
+}
+
+// char consumes the given character.
+func (p *parser) char(c byte) bool {
+	if p.pos < len(p.raw) && p.raw[p.pos] == c {
+		p.pos++
+		return true
+	}
+	if p.track {
+		p.fail(p.pos, describe(c))
+	}
+	return false
+}
+
+// enter increases the nesting depth. It returns false if the maximum depth is
+// exceeded, every call must be followed by a call to leave.
+func (p *parser) enter() bool {
+	p.depth++
+	if max := p.limits.MaxDepth; max > 0 && p.depth > max {
+		p.exceed(DepthLimit, max)
+		return false
+	}
+	return true
+}
+
+// exceed stops the parser because the given limit was exceeded.
+func (p *parser) exceed(limit Limit, max int) {
+	if p.err == nil {
+		p.err = &LimitError{
+			Limit:  limit,
+			Max:    max,
+			Offset: p.pos,
+		}
+	}
+}
+
+// fail records that the given value was expected at the given offset.
+func (p *parser) fail(offset int, expected string) {
+	switch {
+	case offset > p.furthest:
+		p.furthest = offset
+		p.failed = p.rule
+		p.expected = []string{expected}
+	case offset == p.furthest:
+		if !slices.Contains(p.expected, expected) {
+			p.expected = append(p.expected, expected)
+		}
+	}
+}
+
+// keyword consumes the given (case-insensitive) keyword. The keyword must be
+// lowercase.
+func (p *parser) keyword(keyword string) bool {
+	if end := p.pos + len(keyword); end <= len(p.raw) {
+		match := true
+		for i := range len(keyword) {
+			c := p.raw[p.pos+i]
+			if 'A' <= c && c <= 'Z' {
+				c += 'a' - 'A'
+			}
+			if c != keyword[i] {
+				match = false
+				break
+			}
+		}
+		if match {
+			p.pos = end
+			return true
+		}
+	}
+	if p.track {
+		p.fail(p.pos, strconv.Quote(keyword))
+	}
+	return false
+}
+
+// leave decreases the nesting depth.
+func (p *parser) leave() {
+	p.depth--
+}
+
+// notSpaces consumes the spaces between 'not' and '('.
+func (p *parser) notSpaces() {
+	if p.mode == Strict {
+		p.spaces(1)
+		return
+	}
+	p.spaces(0)
+}
+
+// peek checks whether the current character matches the given function.
+func (p *parser) peek(fn func(byte) bool) bool {
+	return p.pos < len(p.raw) && fn(p.raw[p.pos])
+}
+
+// pop ends the rule that was started with push. If the rule failed and the
+// parser did not get past its start, the rule itself is reported as expected
+// in the parent rule.
+func (p *parser) pop(f frame, ok bool) {
+	rule := p.rule
+	p.rule = f.parent
+	if ok || !p.track || p.furthest > f.start {
+		return
+	}
+	if f.before != f.start {
+		p.furthest = -1
+	} else {
+		p.expected = p.expected[:f.mark]
+	}
+	p.fail(f.start, typ.Stringer[rule])
+}
+
+// push starts parsing the rule of the given type.
+func (p *parser) push(ruleType int) frame {
+	f := frame{
+		start:  p.pos,
+		before: p.furthest,
+		mark:   len(p.expected),
+		parent: p.rule,
+	}
+	p.rule = ruleType
+	return f
+}
+
+// spaces consumes at least n spaces. In strict mode it consumes exactly n
+// spaces.
+func (p *parser) spaces(n int) bool {
+	start := p.pos
+	for p.pos < len(p.raw) && p.raw[p.pos] == ' ' {
+		if p.mode == Strict && p.pos-start == n {
+			break
+		}
+		p.pos++
+	}
+	if p.track && (p.mode != Strict || p.pos-start < n) {
+		p.fail(p.pos, "SP")
+	}
+	return p.pos-start >= n
+}
+
+// text returns the raw data between the given offsets as a string. Only the
+// text that is retained is copied, not the raw data as a whole.
+func (p *parser) text(start, end int) string {
+	return string(p.raw[start:end])
+}
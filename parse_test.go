@@ -0,0 +1,311 @@
+package filter
+
+import (
+	diwu "github.com/di-wu/parser"
+	"github.com/di-wu/parser/ast"
+	"github.com/scim2/filter-parser/v2/internal/grammar"
+	"strings"
+	"testing"
+)
+
+var benchmarkFilters = []struct {
+	name   string
+	filter string
+}{
+	{name: "attrExp", filter: `userName eq "bjensen"`},
+	{name: "logExp", filter: `title pr and userType eq "Employee" or meta.lastModified gt "2011-05-13T04:42:34Z"`},
+	{name: "valuePath", filter: `userType eq "Employee" and emails[type eq "work" and value co "@example.com"]`},
+	{name: "not", filter: `userType ne "Employee" and not (emails co "example.com" or emails.value co "example.org")`},
+	{name: "uri", filter: `urn:ietf:params:scim:schemas:extension:enterprise:2.0:User:employeeNumber ge 1000 and urn:ietf:params:scim:schemas:core:2.0:User:userName sw "J"`},
+}
+
+// grammarTests are inputs of which the result is compared with the grammar of
+// internal/grammar.
+var grammarTests = []string{
+	"",
+	" ",
+	"userName eq \"bjensen\"",
+	"userName Eq \"bjensen\"",
+	"userName eq  \"bjensen\"",
+	"userName  eq \"bjensen\"",
+	"userName eq\"bjensen\"",
+	"userNameeq \"bjensen\"",
+	" userName eq \"bjensen\"",
+	"userName eq \"bjensen\" ",
+	"$ref eq \"x\"",
+	"$ eq \"x\"",
+	"$$ref eq \"x\"",
+	"_x pr",
+	"x-y_z9 pr",
+	"9x pr",
+	"name.familyName co \"O'Malley\"",
+	"name.familyName.x co \"O'Malley\"",
+	"name. pr",
+	"urn:ietf:params:scim:schemas:core:2.0:User:userName sw \"J\"",
+	"urn:ietf:params:scim:schemas:core:2.0:User: pr",
+	"urn:ietf:params:scim:schemas:core:2.0:User:name.givenName pr",
+	"urn:example:scim:schemas:extension:my-custom-ext:1.0:User:name.familyName pr",
+	"a:b:9c pr",
+	":a pr",
+	"a::b pr",
+	"title pr",
+	"title PR",
+	"title prx",
+	"title  pr",
+	"title pr and userType eq \"Employee\"",
+	"title pr AND userType eq \"Employee\"",
+	"title pr andb pr",
+	"title pr and  b pr",
+	"title pr or userType eq \"Intern\"",
+	"title pr or",
+	"title pr and",
+	"title pr adn b pr",
+	"a pr or b pr and c pr or d pr",
+	"not (a pr)",
+	"not(a pr)",
+	"NOT  ( a pr )",
+	"not a pr",
+	"not pr",
+	"not eq 1",
+	"not (a pr) and not (b pr)",
+	"(a pr)",
+	"( a pr )",
+	"((a pr))",
+	"(a pr",
+	"a pr)",
+	"(a pr) and (b pr or c pr)",
+	"()",
+	"a eq true",
+	"a eq TRUE",
+	"a eq false",
+	"a eq null",
+	"a eq nul",
+	"a eq truex",
+	"a eq 0",
+	"a eq -0",
+	"a eq 01",
+	"a eq 10",
+	"a eq -1.5",
+	"a eq 1.",
+	"a eq .5",
+	"a eq 1e10",
+	"a eq 1E+10",
+	"a eq 1e-10",
+	"a eq 1e",
+	"a eq -",
+	"a eq 1.5e-3",
+	"a eq \"\"",
+	"a eq \"\\\"\"",
+	"a eq \"\\\\\"",
+	"a eq \"\\/\\b\\f\\n\\r\\t\"",
+	"a eq \"\\u00E9\"",
+	"a eq \"\\u00e9\"",
+	"a eq \"\\u00E\"",
+	"a eq \"\\x\"",
+	"a eq \"é\"",
+	"a eq \"\t\"",
+	"a eq \"abc",
+	"a eq 'abc'",
+	"a eq abc",
+	"a xx 1",
+	"a pr 1",
+	"emails[type eq \"work\"]",
+	"emails [type eq \"work\"]",
+	"emails[ type eq \"work\" ]",
+	"emails[type eq \"work\" and value co \"@example.com\"]",
+	"emails[type eq \"work\"and value co \"@example.com\"]",
+	"emails[type eq \"work\" or type eq \"home\"]",
+	"emails[(type eq \"work\" or type eq \"home\") and primary eq true]",
+	"emails[not (type eq \"work\")]",
+	"emails[not(type eq \"work\")]",
+	"emails[type eq \"work\"] or ims[type eq \"xmpp\" and value co \"@foo.com\"]",
+	"emails[type eq \"work\"].value eq \"x\"",
+	"emails[]",
+	"emails[a pr and]",
+	"emails[(a pr]",
+	"emails[a pr)]",
+	"emails[not a pr]",
+	"emails[a[b pr]]",
+	"emails[a pr",
+	"urn:a:emails[type pr]",
+}
+
+// grammarParse parses the given raw data with the given rule of the grammar of
+// internal/grammar.
+func grammarParse(raw []byte, rule ast.ParseNode) error {
+	p, err := ast.New(raw)
+	if err != nil {
+		return err
+	}
+	if _, err := p.Expect(rule); err != nil {
+		return err
+	}
+	_, err = p.Expect(diwu.EOD)
+	return err
+}
+
+func BenchmarkParseFilter(b *testing.B) {
+	for _, test := range benchmarkFilters {
+		b.Run(test.name, func(b *testing.B) {
+			raw := []byte(test.filter)
+			b.ReportAllocs()
+			for b.Loop() {
+				if _, err := ParseFilter(raw); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+// BenchmarkParseFilter_grammar parses the same filters with the grammar of
+// internal/grammar, which ParseFilter used before. It does not include the
+// conversion of the nodes to expressions.
+func BenchmarkParseFilter_grammar(b *testing.B) {
+	for _, test := range benchmarkFilters {
+		b.Run(test.name, func(b *testing.B) {
+			raw := []byte(test.filter)
+			b.ReportAllocs()
+			for b.Loop() {
+				if err := grammarParse(raw, grammar.Filter); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkParseFilter_invalid(b *testing.B) {
+	raw := []byte(`title pr and userType eq "Employee" adn emails[type eq "work"]`)
+	b.ReportAllocs()
+	for b.Loop() {
+		if _, err := ParseFilter(raw); err == nil {
+			b.Fatal("expected an error")
+		}
+	}
+}
+
+func FuzzParseFilter(f *testing.F) {
+	for _, test := range grammarTests {
+		f.Add(test)
+	}
+	f.Fuzz(func(t *testing.T, raw string) {
+		expr, err := ParseFilter([]byte(raw))
+		if grammarErr := grammarParse([]byte(raw), grammar.Filter); (err == nil) != (grammarErr == nil) {
+			t.Fatalf("%q: got %v, grammar got %v", raw, err, grammarErr)
+		}
+		if err != nil {
+			return
+		}
+		formatted := Format(expr)
+		again, err := ParseFilter([]byte(formatted))
+		if err != nil {
+			t.Fatalf("%q: formatted %q: %v", raw, formatted, err)
+		}
+		if s := Format(again); s != formatted {
+			t.Fatalf("%q: formatted %q, then %q", raw, formatted, s)
+		}
+	})
+}
+
+func TestParseFilter_allocs(t *testing.T) {
+	// The raw data is not copied, only the names and values that are
+	// retained are. Both the value path and the attribute expression parse
+	// the attribute name, hence the two copies of "title".
+	for _, test := range []struct {
+		filter string
+		allocs float64
+	}{
+		{filter: `title pr`, allocs: 4},
+		{filter: "title" + strings.Repeat(" ", 1000) + "pr", allocs: 4},
+		{filter: `a pr`, allocs: 2},
+		{filter: `title eq 1`, allocs: 4},
+		{filter: `title eq true`, allocs: 4},
+	} {
+		raw := []byte(test.filter)
+		allocs := testing.AllocsPerRun(100, func() {
+			if _, err := ParseFilter(raw); err != nil {
+				t.Fatal(err)
+			}
+		})
+		if allocs != test.allocs {
+			t.Errorf("%q: expected %v allocations, got %v", test.filter, test.allocs, allocs)
+		}
+	}
+}
+
+func TestParse_grammar(t *testing.T) {
+	for _, rule := range []struct {
+		name    string
+		parse   func([]byte) error
+		grammar ast.ParseNode
+	}{
+		{
+			name: "Filter",
+			parse: func(raw []byte) error {
+				_, err := ParseFilter(raw)
+				return err
+			},
+			grammar: grammar.Filter,
+		},
+		{
+			name: "AttrExp",
+			parse: func(raw []byte) error {
+				_, err := ParseAttrExp(raw)
+				return err
+			},
+			grammar: grammar.AttrExp,
+		},
+		{
+			name: "ValuePath",
+			parse: func(raw []byte) error {
+				_, err := ParseValuePath(raw)
+				return err
+			},
+			grammar: grammar.ValuePath,
+		},
+		{
+			name: "Path",
+			parse: func(raw []byte) error {
+				_, err := ParsePath(raw)
+				return err
+			},
+			grammar: grammar.Path,
+		},
+		{
+			name: "AttrPath",
+			parse: func(raw []byte) error {
+				_, err := ParseAttrPath(raw)
+				return err
+			},
+			grammar: grammar.AttrPath,
+		},
+		{
+			name: "AttrList",
+			parse: func(raw []byte) error {
+				_, err := ParseAttributeList(raw)
+				return err
+			},
+			grammar: grammar.AttrList,
+		},
+	} {
+		inputs := append([]string{
+			"members",
+			"members[value pr].displayName",
+			"members[value pr].",
+			"members[value pr]displayName",
+			"a, b,c ,d",
+			"a,",
+			",a",
+		}, grammarTests...)
+		for _, input := range inputs {
+			t.Run(rule.name+"/"+input, func(t *testing.T) {
+				err := rule.parse([]byte(input))
+				grammarErr := grammarParse([]byte(input), rule.grammar)
+				if (err == nil) != (grammarErr == nil) {
+					t.Errorf("got %v, grammar got %v", err, grammarErr)
+				}
+			})
+		}
+	}
+}
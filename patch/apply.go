@@ -0,0 +1,398 @@
+package patch
+
+import (
+	"fmt"
+	filter "github.com/scim2/filter-parser/v2"
+	"maps"
+	"reflect"
+	"slices"
+	"strings"
+)
+
+// Apply applies the given operations to the resource, in order. Either all
+// operations are applied or, if one of them fails, none of them. The returned
+// error is always an *Error.
+//
+// The operations follow the rules of RFC 7644, Section 3.5.2:
+//   - add merges the value into the target: values are appended to
+//     multi-valued attributes (skipping duplicates), sub-attributes are merged
+//     into complex attributes and other attributes are replaced. An add
+//     without a path merges every attribute of the value into the resource.
+//   - replace replaces the target, but merges sub-attributes into complex
+//     attributes. A replace without a path replaces every attribute of the
+//     value.
+//   - remove removes the target. Removing all values of a multi-valued
+//     attribute makes it unassigned.
+//
+// A value filter (e.g. emails[type eq "work"]) selects the values of a
+// multi-valued attribute that match the filter, evaluated with
+// filter.Evaluate. Values that are not objects are evaluated as their "value"
+// sub-attribute. A filter that matches no value results in a "noTarget" error.
+//
+// Attribute paths with a URI prefix target the extension object of that URI.
+// The prefix is ignored if it matches the first URI of the "schemas" of the
+// resource, i.e. the core schema. Missing extension objects are created by add
+// and replace operations. The URI of an extension itself (e.g.
+// "urn:ietf:params:scim:schemas:extension:enterprise:2.0:User") targets the
+// extension object as a whole, if the resource contains the object or lists
+// the URI in its "schemas". The URI is added to or removed from the "schemas"
+// accordingly.
+//
+// Setting "primary" to true on a value of a multi-valued attribute sets
+// "primary" of all other values to false.
+func Apply(resource map[string]any, operations ...Operation) error {
+	patched := clone(resource).(map[string]any)
+	for i, o := range operations {
+		if err := o.apply(patched); err != nil {
+			err.Detail = fmt.Sprintf("operation %d: %s", i, err.Detail)
+			return err
+		}
+	}
+	clear(resource)
+	maps.Copy(resource, patched)
+	return nil
+}
+
+// add adds the value to the attribute with the given name.
+func add(m map[string]any, name string, value any) {
+	k := key(m, name)
+	switch current := m[k].(type) {
+	case []any:
+		values, ok := value.([]any)
+		if !ok {
+			values = []any{value}
+		}
+		for _, v := range values {
+			i := slices.IndexFunc(current, func(c any) bool {
+				return reflect.DeepEqual(c, v)
+			})
+			if i < 0 {
+				i = len(current)
+				current = append(current, v)
+			}
+			if isPrimary(v) {
+				resetPrimary(current, i)
+			}
+		}
+		m[k] = current
+	case map[string]any:
+		if v, ok := value.(map[string]any); ok {
+			for name, v := range v {
+				add(current, name, v)
+			}
+			return
+		}
+		m[k] = value
+	default:
+		m[k] = value
+	}
+}
+
+// clone returns a deep copy of the given JSON value.
+func clone(value any) any {
+	switch v := value.(type) {
+	case map[string]any:
+		m := make(map[string]any, len(v))
+		for k, v := range v {
+			m[k] = clone(v)
+		}
+		return m
+	case []any:
+		s := make([]any, len(v))
+		for i, v := range v {
+			s[i] = clone(v)
+		}
+		return s
+	default:
+		return v
+	}
+}
+
+// container returns the object that contains the attributes of the given URI
+// prefix. Missing extension objects are created if create is true, otherwise
+// nil is returned.
+func container(resource map[string]any, uri *string, create bool) map[string]any {
+	if uri == nil {
+		return resource
+	}
+	k := key(resource, *uri)
+	if extension, ok := resource[k].(map[string]any); ok {
+		return extension
+	}
+	if schemas, ok := resource["schemas"].([]any); ok && len(schemas) != 0 {
+		if core, ok := schemas[0].(string); ok && strings.EqualFold(core, *uri) {
+			return resource
+		}
+	}
+	if !create {
+		return nil
+	}
+
+	extension := make(map[string]any)
+	resource[k] = extension
+	if schemas, ok := resource["schemas"].([]any); ok {
+		resource["schemas"] = append(schemas, *uri)
+	}
+	return extension
+}
+
+// extension returns the key of the extension object that the given attribute
+// path refers to as a whole, if any. The URI of an extension is parsed as a URI
+// prefix and an attribute name, e.g. "urn:...:enterprise:2.0" and "User".
+func extension(resource map[string]any, attrPath filter.AttributePath) (string, bool) {
+	if attrPath.URIPrefix == nil || attrPath.SubAttribute != nil {
+		return "", false
+	}
+	uri := *attrPath.URIPrefix + ":" + attrPath.AttributeName
+	if k := key(resource, uri); resource[k] != nil {
+		return k, true
+	}
+	// The first schema is the core schema, not an extension.
+	schemas, _ := resource["schemas"].([]any)
+	for i, s := range schemas {
+		if s, ok := s.(string); ok && i != 0 && strings.EqualFold(s, uri) {
+			return s, true
+		}
+	}
+	return "", false
+}
+
+func invalidPath(format string, a ...any) *Error {
+	return &Error{
+		ScimType: "invalidPath",
+		Detail:   fmt.Sprintf(format, a...),
+	}
+}
+
+// isPrimary checks whether the given value is an object of which "primary" is
+// true.
+func isPrimary(value any) bool {
+	m, ok := value.(map[string]any)
+	if !ok {
+		return false
+	}
+	primary, _ := m[key(m, "primary")].(bool)
+	return primary
+}
+
+// key returns the key of the given map that matches the (case-insensitive)
+// name. Returns the name itself if no key matches.
+func key(m map[string]any, name string) string {
+	if _, ok := m[name]; ok {
+		return name
+	}
+	for k := range m {
+		if strings.EqualFold(k, name) {
+			return k
+		}
+	}
+	return name
+}
+
+func noTarget(format string, a ...any) *Error {
+	return &Error{
+		ScimType: "noTarget",
+		Detail:   fmt.Sprintf(format, a...),
+	}
+}
+
+// replace replaces the value of the attribute with the given name.
+func replace(m map[string]any, name string, value any) {
+	k := key(m, name)
+	if current, ok := m[k].(map[string]any); ok {
+		if v, ok := value.(map[string]any); ok {
+			for name, v := range v {
+				replace(current, name, v)
+			}
+			return
+		}
+	}
+	m[k] = value
+	if values, ok := value.([]any); ok {
+		for i, v := range values {
+			if isPrimary(v) {
+				resetPrimary(values, i)
+			}
+		}
+	}
+}
+
+// resetPrimary sets "primary" to false for all values except the i-th value.
+func resetPrimary(values []any, i int) {
+	for j, v := range values {
+		if j != i && isPrimary(v) {
+			m := v.(map[string]any)
+			m[key(m, "primary")] = false
+		}
+	}
+}
+
+func (o Operation) apply(resource map[string]any) *Error {
+	if err := o.validate(); err != nil {
+		return err
+	}
+	op := o.op()
+	if o.Path == nil {
+		for name, v := range clone(o.Value).(map[string]any) {
+			if op == Add {
+				add(resource, name, v)
+			} else {
+				replace(resource, name, v)
+			}
+		}
+		return nil
+	}
+
+	path := *o.Path
+	attrPath := path.AttributePath
+	if k, ok := extension(resource, attrPath); ok && path.ValueExpression == nil {
+		return o.applyExtension(resource, k)
+	}
+	c := container(resource, attrPath.URIPrefix, op != Remove)
+	if c == nil {
+		// Removing an attribute of a missing extension.
+		if path.ValueExpression != nil {
+			return noTarget("no values match %q", path)
+		}
+		return nil
+	}
+
+	if path.ValueExpression == nil {
+		if attrPath.SubAttribute == nil {
+			o.applyAttribute(c, attrPath.AttributeName, o.Value)
+			return nil
+		}
+
+		k := key(c, attrPath.AttributeName)
+		switch v := c[k].(type) {
+		case nil:
+			if op == Remove {
+				return nil
+			}
+			m := make(map[string]any)
+			c[k] = m
+			o.applyAttribute(m, *attrPath.SubAttribute, o.Value)
+		case map[string]any:
+			o.applyAttribute(v, *attrPath.SubAttribute, o.Value)
+		case []any:
+			for _, e := range v {
+				if m, ok := e.(map[string]any); ok {
+					o.applyAttribute(m, *attrPath.SubAttribute, o.Value)
+				}
+			}
+		default:
+			return invalidPath("%q is not a complex attribute", attrPath.AttributeName)
+		}
+		return nil
+	}
+
+	k := key(c, attrPath.AttributeName)
+	values, ok := c[k].([]any)
+	if !ok {
+		if _, ok := c[k]; ok {
+			return invalidPath("%q is not a multi-valued attribute", attrPath.AttributeName)
+		}
+		return noTarget("no values match %q", path)
+	}
+
+	var matched bool
+	remove := make([]bool, len(values))
+	for i, v := range values {
+		element, ok := v.(map[string]any)
+		if !ok {
+			element = map[string]any{"value": v}
+		}
+		match, err := filter.Evaluate(path.ValueExpression, element)
+		if err != nil {
+			return &Error{
+				ScimType: "invalidFilter",
+				Detail:   err.Error(),
+			}
+		}
+		if !match {
+			continue
+		}
+		matched = true
+
+		if path.SubAttribute != nil {
+			if !ok {
+				return invalidPath("%q is not a complex attribute", attrPath.AttributeName)
+			}
+			o.applyAttribute(element, *path.SubAttribute, o.Value)
+			if isPrimary(element) {
+				resetPrimary(values, i)
+			}
+			continue
+		}
+		switch op {
+		case Add:
+			m, isMap := o.Value.(map[string]any)
+			if !ok || !isMap {
+				return invalidValue("the value of an add operation on %q must be an object", path)
+			}
+			for name, v := range clone(m).(map[string]any) {
+				add(element, name, v)
+			}
+		case Replace:
+			values[i] = clone(o.Value)
+		case Remove:
+			remove[i] = true
+		}
+		if isPrimary(values[i]) {
+			resetPrimary(values, i)
+		}
+	}
+	if !matched {
+		return noTarget("no values match %q", path)
+	}
+
+	if op == Remove && path.SubAttribute == nil {
+		var remaining []any
+		for i, v := range values {
+			if !remove[i] {
+				remaining = append(remaining, v)
+			}
+		}
+		if len(remaining) == 0 {
+			delete(c, k)
+			return nil
+		}
+		c[k] = remaining
+	}
+	return nil
+}
+
+// applyAttribute applies the operation to the attribute with the given name.
+func (o Operation) applyAttribute(m map[string]any, name string, value any) {
+	switch o.op() {
+	case Add:
+		add(m, name, clone(value))
+	case Replace:
+		replace(m, name, clone(value))
+	case Remove:
+		delete(m, key(m, name))
+	}
+}
+
+// applyExtension applies the operation to the extension object with the given
+// key as a whole.
+func (o Operation) applyExtension(resource map[string]any, k string) *Error {
+	op := o.op()
+	if _, ok := o.Value.(map[string]any); !ok && op != Remove {
+		return invalidValue("the value of a %s operation on %q must be an object", o.Op, k)
+	}
+	o.applyAttribute(resource, k, o.Value)
+
+	schemas, _ := resource["schemas"].([]any)
+	i := slices.IndexFunc(schemas, func(s any) bool {
+		uri, _ := s.(string)
+		return strings.EqualFold(uri, k)
+	})
+	switch {
+	case op == Remove && i > 0:
+		resource["schemas"] = slices.Delete(schemas, i, i+1)
+	case op != Remove && i < 0 && schemas != nil:
+		resource["schemas"] = append(schemas, k)
+	}
+	return nil
+}
@@ -0,0 +1,290 @@
+package patch
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+const testUser = `{
+	"schemas": ["urn:ietf:params:scim:schemas:core:2.0:User"],
+	"id": "2819c223-7f76-453a-919d-413861904646",
+	"userName": "bjensen",
+	"name": {
+		"familyName": "Jensen",
+		"givenName": "Barbara"
+	},
+	"emails": [
+		{"value": "bjensen@example.com", "type": "work", "primary": true},
+		{"value": "babs@jensen.org", "type": "home"}
+	],
+	"roles": ["admin", "user"]
+}`
+
+func ExampleApply() {
+	var resource map[string]any
+	_ = json.Unmarshal([]byte(testUser), &resource)
+
+	operations, _ := Parse([]byte(`{"Operations": [
+		{"op": "replace", "path": "emails[type eq \"home\"].primary", "value": true},
+		{"op": "remove", "path": "emails[type eq \"work\"].primary"}
+	]}`))
+	_ = Apply(resource, operations...)
+	fmt.Println(resource["emails"])
+	// Output:
+	// [map[type:work value:bjensen@example.com] map[primary:true type:home value:babs@jensen.org]]
+}
+
+func TestApply(t *testing.T) {
+	for _, test := range []struct {
+		name       string
+		operations string
+		expected   map[string]any
+	}{
+		{
+			name:       "add attribute",
+			operations: `{"op": "add", "path": "title", "value": "Tour Guide"}`,
+			expected:   map[string]any{"title": "Tour Guide"},
+		},
+		{
+			name:       "add sub-attribute",
+			operations: `{"op": "add", "path": "NAME.middleName", "value": "Jane"}`,
+			expected: map[string]any{"name": map[string]any{
+				"familyName": "Jensen", "givenName": "Barbara", "middleName": "Jane",
+			}},
+		},
+		{
+			name:       "add sub-attribute of missing attribute",
+			operations: `{"op": "add", "path": "meta.version", "value": "W/\"1\""}`,
+			expected:   map[string]any{"meta": map[string]any{"version": `W/"1"`}},
+		},
+		{
+			name:       "add values",
+			operations: `{"op": "add", "path": "roles", "value": ["user", "auditor"]}`,
+			expected:   map[string]any{"roles": []any{"admin", "user", "auditor"}},
+		},
+		{
+			name:       "add primary value",
+			operations: `{"op": "add", "path": "emails", "value": {"value": "b@example.org", "primary": true}}`,
+			expected: map[string]any{"emails": []any{
+				map[string]any{"value": "bjensen@example.com", "type": "work", "primary": false},
+				map[string]any{"value": "babs@jensen.org", "type": "home"},
+				map[string]any{"value": "b@example.org", "primary": true},
+			}},
+		},
+		{
+			name:       "add without path",
+			operations: `{"op": "add", "value": {"nickName": "Babs", "name": {"honorificPrefix": "Ms."}, "roles": ["guest"]}}`,
+			expected: map[string]any{
+				"nickName": "Babs",
+				"name": map[string]any{
+					"familyName": "Jensen", "givenName": "Barbara", "honorificPrefix": "Ms.",
+				},
+				"roles": []any{"admin", "user", "guest"},
+			},
+		},
+		{
+			name:       "add to extension",
+			operations: `{"op": "add", "path": "urn:ietf:params:scim:schemas:extension:enterprise:2.0:User:employeeNumber", "value": "701984"}`,
+			expected: map[string]any{
+				"schemas": []any{"urn:ietf:params:scim:schemas:core:2.0:User", "urn:ietf:params:scim:schemas:extension:enterprise:2.0:User"},
+				"urn:ietf:params:scim:schemas:extension:enterprise:2.0:User": map[string]any{"employeeNumber": "701984"},
+			},
+		},
+		{
+			name: "add to extension object",
+			operations: `{"op": "add", "path": "schemas", "value": ["urn:ietf:params:scim:schemas:extension:enterprise:2.0:User"]},
+				{"op": "add", "path": "urn:ietf:params:scim:schemas:extension:enterprise:2.0:User", "value": {"employeeNumber": "701984"}}`,
+			expected: map[string]any{
+				"schemas": []any{"urn:ietf:params:scim:schemas:core:2.0:User", "urn:ietf:params:scim:schemas:extension:enterprise:2.0:User"},
+				"urn:ietf:params:scim:schemas:extension:enterprise:2.0:User": map[string]any{"employeeNumber": "701984"},
+			},
+		},
+		{
+			name:       "add with core schema",
+			operations: `{"op": "add", "path": "urn:ietf:params:scim:schemas:core:2.0:User:nickName", "value": "Babs"}`,
+			expected:   map[string]any{"nickName": "Babs"},
+		},
+		{
+			name:       "add to filtered values",
+			operations: `{"op": "add", "path": "emails[type eq \"home\"]", "value": {"display": "Home"}}`,
+			expected: map[string]any{"emails": []any{
+				map[string]any{"value": "bjensen@example.com", "type": "work", "primary": true},
+				map[string]any{"value": "babs@jensen.org", "type": "home", "display": "Home"},
+			}},
+		},
+		{
+			name:       "replace attribute",
+			operations: `{"op": "replace", "path": "userName", "value": "babs"}`,
+			expected:   map[string]any{"userName": "babs"},
+		},
+		{
+			name:       "replace complex attribute",
+			operations: `{"op": "replace", "path": "name", "value": {"givenName": "Babs"}}`,
+			expected:   map[string]any{"name": map[string]any{"familyName": "Jensen", "givenName": "Babs"}},
+		},
+		{
+			name:       "replace multi-valued attribute",
+			operations: `{"op": "replace", "path": "roles", "value": ["guest"]}`,
+			expected:   map[string]any{"roles": []any{"guest"}},
+		},
+		{
+			name:       "replace without path",
+			operations: `{"op": "replace", "value": {"userName": "babs", "name": {"familyName": "Smith"}}}`,
+			expected: map[string]any{
+				"userName": "babs",
+				"name":     map[string]any{"familyName": "Smith", "givenName": "Barbara"},
+			},
+		},
+		{
+			name: "replace extension object",
+			operations: `{"op": "add", "path": "urn:ietf:params:scim:schemas:extension:enterprise:2.0:User:employeeNumber", "value": "701984"},
+				{"op": "replace", "path": "urn:ietf:params:scim:schemas:extension:enterprise:2.0:User", "value": {"department": "Tour Operations"}}`,
+			expected: map[string]any{
+				"schemas": []any{"urn:ietf:params:scim:schemas:core:2.0:User", "urn:ietf:params:scim:schemas:extension:enterprise:2.0:User"},
+				"urn:ietf:params:scim:schemas:extension:enterprise:2.0:User": map[string]any{
+					"employeeNumber": "701984", "department": "Tour Operations",
+				},
+			},
+		},
+		{
+			name:       "replace filtered values",
+			operations: `{"op": "replace", "path": "emails[type eq \"home\"]", "value": {"value": "babs@example.org", "type": "home", "primary": true}}`,
+			expected: map[string]any{"emails": []any{
+				map[string]any{"value": "bjensen@example.com", "type": "work", "primary": false},
+				map[string]any{"value": "babs@example.org", "type": "home", "primary": true},
+			}},
+		},
+		{
+			name:       "replace sub-attribute of filtered values",
+			operations: `{"op": "replace", "path": "emails[type eq \"work\" or type eq \"home\"].type", "value": "other"}`,
+			expected: map[string]any{"emails": []any{
+				map[string]any{"value": "bjensen@example.com", "type": "other", "primary": true},
+				map[string]any{"value": "babs@jensen.org", "type": "other"},
+			}},
+		},
+		{
+			name:       "replace sub-attribute of all values",
+			operations: `{"op": "replace", "path": "emails.type", "value": "other"}`,
+			expected: map[string]any{"emails": []any{
+				map[string]any{"value": "bjensen@example.com", "type": "other", "primary": true},
+				map[string]any{"value": "babs@jensen.org", "type": "other"},
+			}},
+		},
+		{
+			name:       "remove attribute",
+			operations: `{"op": "remove", "path": "name"}`,
+			expected:   map[string]any{"name": nil},
+		},
+		{
+			name:       "remove missing attribute",
+			operations: `{"op": "remove", "path": "urn:ietf:params:scim:schemas:extension:enterprise:2.0:User:manager"}`,
+			expected:   map[string]any{},
+		},
+		{
+			name: "remove extension object",
+			operations: `{"op": "add", "path": "urn:ietf:params:scim:schemas:extension:enterprise:2.0:User:employeeNumber", "value": "701984"},
+				{"op": "remove", "path": "urn:ietf:params:scim:schemas:extension:enterprise:2.0:User"}`,
+			expected: map[string]any{},
+		},
+		{
+			name:       "remove sub-attribute",
+			operations: `{"op": "remove", "path": "name.givenName"}`,
+			expected:   map[string]any{"name": map[string]any{"familyName": "Jensen"}},
+		},
+		{
+			name:       "remove filtered values",
+			operations: `{"op": "remove", "path": "emails[value ew \"example.com\"]"}`,
+			expected: map[string]any{"emails": []any{
+				map[string]any{"value": "babs@jensen.org", "type": "home"},
+			}},
+		},
+		{
+			name:       "remove all values",
+			operations: `{"op": "remove", "path": "emails[value pr]"}`,
+			expected:   map[string]any{"emails": nil},
+		},
+		{
+			name:       "remove simple values",
+			operations: `{"op": "remove", "path": "roles[value eq \"ADMIN\"]"}`,
+			expected:   map[string]any{"roles": []any{"user"}},
+		},
+		{
+			name: "multiple operations",
+			operations: `{"op": "add", "path": "emails", "value": [{"value": "b@example.org", "type": "other"}]},
+				{"op": "remove", "path": "emails[type eq \"other\"]"}`,
+			expected: map[string]any{},
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			var resource map[string]any
+			_ = json.Unmarshal([]byte(testUser), &resource)
+			operations, err := Parse([]byte(`{"Operations": [` + test.operations + `]}`))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := Apply(resource, operations...); err != nil {
+				t.Fatal(err)
+			}
+
+			var expected map[string]any
+			_ = json.Unmarshal([]byte(testUser), &expected)
+			for k, v := range test.expected {
+				if v == nil {
+					delete(expected, k)
+					continue
+				}
+				expected[k] = v
+			}
+			if !reflect.DeepEqual(resource, expected) {
+				t.Errorf("expected %v, got %v", expected, resource)
+			}
+		})
+	}
+}
+
+func TestApply_invalid(t *testing.T) {
+	for _, test := range []struct {
+		operations string
+		scimType   string
+	}{
+		{operations: `{"op": "replace", "path": "emails[type eq \"other\"].value", "value": "x"}`, scimType: "noTarget"},
+		{operations: `{"op": "remove", "path": "addresses[type eq \"work\"]"}`, scimType: "noTarget"},
+		{operations: `{"op": "remove", "path": "userName[value eq \"bjensen\"]"}`, scimType: "invalidPath"},
+		{operations: `{"op": "replace", "path": "userName.first", "value": "x"}`, scimType: "invalidPath"},
+		{operations: `{"op": "add", "path": "schemas", "value": ["urn:ietf:params:scim:schemas:extension:enterprise:2.0:User"]}, {"op": "replace", "path": "urn:ietf:params:scim:schemas:extension:enterprise:2.0:User", "value": "x"}`, scimType: "invalidValue"},
+		{operations: `{"op": "replace", "path": "roles[value eq \"user\"].name", "value": "x"}`, scimType: "invalidPath"},
+		{operations: `{"op": "add", "path": "emails[type eq \"work\"]", "value": "x"}`, scimType: "invalidValue"},
+		{operations: `{"op": "remove", "path": "emails[primary gt true]"}`, scimType: "invalidFilter"},
+		{
+			// The first operation is not applied if the second one fails.
+			operations: `{"op": "replace", "path": "userName", "value": "babs"},
+				{"op": "remove", "path": "emails[type eq \"other\"]"}`,
+			scimType: "noTarget",
+		},
+	} {
+		t.Run(test.operations, func(t *testing.T) {
+			var resource map[string]any
+			_ = json.Unmarshal([]byte(testUser), &resource)
+			operations, err := Parse([]byte(`{"Operations": [` + test.operations + `]}`))
+			if err != nil {
+				t.Fatal(err)
+			}
+			err = Apply(resource, operations...)
+			e, ok := err.(*Error)
+			if !ok {
+				t.Fatalf("expected an *Error, got %v", err)
+			}
+			if e.ScimType != test.scimType {
+				t.Errorf("expected %s, got %s", test.scimType, e)
+			}
+
+			var expected map[string]any
+			_ = json.Unmarshal([]byte(testUser), &expected)
+			if !reflect.DeepEqual(resource, expected) {
+				t.Errorf("resource was modified: %v", resource)
+			}
+		})
+	}
+}
@@ -0,0 +1,159 @@
+// Package patch parses SCIM PATCH requests and applies their operations to
+// resources.
+//
+// More info: https://tools.ietf.org/html/rfc7644#section-3.5.2
+package patch
+
+import (
+	"encoding/json"
+	"fmt"
+	filter "github.com/scim2/filter-parser/v2"
+	"strings"
+)
+
+const (
+	// Add adds a value to an attribute.
+	Add Op = "add"
+	// Remove removes an attribute or a value of a multi-valued attribute.
+	Remove Op = "remove"
+	// Replace replaces the value of an attribute.
+	Replace Op = "replace"
+)
+
+// PatchOpSchema is the schema URI of a PATCH request.
+const PatchOpSchema = "urn:ietf:params:scim:api:messages:2.0:PatchOp"
+
+// parser parses the paths of operations, which come from untrusted clients.
+var parser = filter.NewParser(filter.WithLimits(filter.DefaultLimits))
+
+// Parse parses the given raw data as the body of a PATCH request. Operations
+// are case-insensitive, e.g. "Add" is accepted as "add". The returned error is
+// always an *Error.
+func Parse(raw []byte) ([]Operation, error) {
+	var request struct {
+		Schemas    []string `json:"schemas"`
+		Operations []struct {
+			Op    string          `json:"op"`
+			Path  *string         `json:"path"`
+			Value json.RawMessage `json:"value"`
+		} `json:"Operations"`
+	}
+	if err := json.Unmarshal(raw, &request); err != nil {
+		return nil, invalidSyntax("invalid patch request: %v", err)
+	}
+	if request.Schemas != nil && !containsFold(request.Schemas, PatchOpSchema) {
+		return nil, invalidSyntax("schemas must contain %q", PatchOpSchema)
+	}
+	if len(request.Operations) == 0 {
+		return nil, invalidSyntax("no operations")
+	}
+
+	operations := make([]Operation, len(request.Operations))
+	for i, o := range request.Operations {
+		operation := Operation{
+			Op: Op(strings.ToLower(o.Op)),
+		}
+		if o.Path != nil {
+			path, err := parser.ParsePath([]byte(*o.Path))
+			if err != nil {
+				return nil, &Error{
+					ScimType: "invalidPath",
+					Detail:   fmt.Sprintf("operation %d: %v", i, err),
+				}
+			}
+			operation.Path = &path
+		}
+		if o.Value != nil {
+			if err := json.Unmarshal(o.Value, &operation.Value); err != nil {
+				return nil, invalidSyntax("operation %d: %v", i, err)
+			}
+		}
+		if err := operation.validate(); err != nil {
+			err.Detail = fmt.Sprintf("operation %d: %s", i, err.Detail)
+			return nil, err
+		}
+		operations[i] = operation
+	}
+	return operations, nil
+}
+
+func containsFold(s []string, v string) bool {
+	for _, s := range s {
+		if strings.EqualFold(s, v) {
+			return true
+		}
+	}
+	return false
+}
+
+func invalidSyntax(format string, a ...any) *Error {
+	return &Error{
+		ScimType: "invalidSyntax",
+		Detail:   fmt.Sprintf(format, a...),
+	}
+}
+
+func invalidValue(format string, a ...any) *Error {
+	return &Error{
+		ScimType: "invalidValue",
+		Detail:   fmt.Sprintf(format, a...),
+	}
+}
+
+// Error is an error of a PATCH request. All errors should result in a SCIM
+// error response with status 400 and the given SCIM type.
+//
+// More info: https://tools.ietf.org/html/rfc7644#section-3.12
+type Error struct {
+	// ScimType is the SCIM detail error keyword, e.g. "noTarget".
+	ScimType string
+	// Detail is a human-readable description of the error.
+	Detail string
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("%s: %s", e.ScimType, e.Detail)
+}
+
+// Op is the operation to perform.
+type Op string
+
+// Operation is a single operation of a PATCH request.
+type Operation struct {
+	// Op is the operation to perform.
+	Op Op
+	// Path is the target of the operation. A nil path targets the resource
+	// itself, which is not allowed for remove operations.
+	Path *filter.Path
+	// Value is the value of the operation, as decoded by encoding/json. It is
+	// required for add and replace operations.
+	Value any
+}
+
+// op returns the lowercase operation.
+func (o Operation) op() Op {
+	return Op(strings.ToLower(string(o.Op)))
+}
+
+// validate checks whether the operation is valid by itself.
+func (o Operation) validate() *Error {
+	switch o.op() {
+	case Add, Replace:
+		if o.Value == nil {
+			return invalidValue("%s operation without a value", o.Op)
+		}
+		if _, ok := o.Value.(map[string]any); !ok && o.Path == nil {
+			return invalidValue("the value of a %s operation without a path must be an object", o.Op)
+		}
+	case Remove:
+		if o.Path == nil {
+			return &Error{
+				ScimType: "noTarget",
+				Detail:   "remove operation without a path",
+			}
+		}
+	default:
+		return invalidSyntax("invalid operation %q", o.Op)
+	}
+	return nil
+}
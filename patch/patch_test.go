@@ -0,0 +1,65 @@
+package patch
+
+import (
+	"fmt"
+	"testing"
+)
+
+func ExampleParse() {
+	operations, _ := Parse([]byte(`{
+		"schemas": ["urn:ietf:params:scim:api:messages:2.0:PatchOp"],
+		"Operations": [
+			{"op": "Add", "path": "emails", "value": [{"type": "home", "value": "babs@example.com"}]},
+			{"op": "replace", "path": "emails[type eq \"work\"].value", "value": "bjensen@example.com"},
+			{"op": "remove", "path": "phoneNumbers"}
+		]
+	}`))
+	for _, o := range operations {
+		fmt.Println(o.Op, o.Path, o.Value)
+	}
+	// Output:
+	// add emails [map[type:home value:babs@example.com]]
+	// replace emails[type eq "work"].value bjensen@example.com
+	// remove phoneNumbers <nil>
+}
+
+func TestParse(t *testing.T) {
+	operations, err := Parse([]byte(`{"Operations": [{"op": "REPLACE", "value": {"active": false}}]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(operations) != 1 {
+		t.Fatalf("expected 1 operation, got %d", len(operations))
+	}
+	if o := operations[0]; o.Op != Replace || o.Path != nil {
+		t.Errorf("unexpected operation: %v", o)
+	}
+}
+
+func TestParse_invalid(t *testing.T) {
+	for _, test := range []struct {
+		raw      string
+		scimType string
+	}{
+		{raw: `{"Operations": [`, scimType: "invalidSyntax"},
+		{raw: `{"schemas": ["urn:ietf:params:scim:api:messages:2.0:SearchRequest"], "Operations": [{"op": "remove", "path": "title"}]}`, scimType: "invalidSyntax"},
+		{raw: `{"Operations": []}`, scimType: "invalidSyntax"},
+		{raw: `{"Operations": [{"op": "move", "path": "title"}]}`, scimType: "invalidSyntax"},
+		{raw: `{"Operations": [{"op": "remove", "path": "emails[type eq]"}]}`, scimType: "invalidPath"},
+		{raw: `{"Operations": [{"op": "remove"}]}`, scimType: "noTarget"},
+		{raw: `{"Operations": [{"op": "add", "path": "title"}]}`, scimType: "invalidValue"},
+		{raw: `{"Operations": [{"op": "add", "path": "title", "value": null}]}`, scimType: "invalidValue"},
+		{raw: `{"Operations": [{"op": "replace", "value": "Tour Guide"}]}`, scimType: "invalidValue"},
+	} {
+		t.Run(test.raw, func(t *testing.T) {
+			_, err := Parse([]byte(test.raw))
+			e, ok := err.(*Error)
+			if !ok {
+				t.Fatalf("expected an *Error, got %v", err)
+			}
+			if e.ScimType != test.scimType {
+				t.Errorf("expected %s, got %s", test.scimType, e)
+			}
+		})
+	}
+}
@@ -1,9 +1,6 @@
 package filter
 
 import (
-	"github.com/di-wu/parser"
-	"github.com/di-wu/parser/ast"
-	"github.com/scim2/filter-parser/v2/internal/grammar"
 	"github.com/scim2/filter-parser/v2/internal/types"
 )
 
@@ -13,65 +10,50 @@ func ParsePath(raw []byte) (Path, error) {
 }
 
 // ParsePathNumber parses the given raw data as an Path with json.Number.
+//
+// Deprecated: Use NewParser(UseNumber()).ParsePath instead.
 func ParsePathNumber(raw []byte) (Path, error) {
 	return parsePath(raw, config{useNumber: true})
 }
 
 func parsePath(raw []byte, c config) (Path, error) {
-	p, err := ast.New(raw)
-	if err != nil {
-		return Path{}, err
-	}
-	node, err := grammar.Path(p)
-	if err != nil {
-		return Path{}, err
-	}
-	if _, err := p.Expect(parser.EOD); err != nil {
-		return Path{}, err
-	}
-	return c.parsePath(node)
+	return parse(raw, c, typ.Path, (*parser).path)
 }
 
-func (p config) parsePath(node *ast.Node) (Path, error) {
-	children := node.Children()
-	if len(children) == 0 {
-		return Path{}, invalidLengthError(typ.Path, 1, 0)
-	}
-
-	// AttrPath
-	if node.Type == typ.AttrPath {
-		attrPath, err := parseAttrPath(node)
-		if err != nil {
-			return Path{}, err
+func (p *parser) path() (Path, bool) {
+	f := p.push(typ.Path)
+	start := p.pos
+	// ValuePath SubAttr?
+	if valuePath, ok := p.valuePath(); ok {
+		path := Path{
+			AttributePath:   valuePath.AttributePath,
+			ValueExpression: valuePath.ValueFilter,
+		}
+		end := p.pos
+		if subAttr, ok := p.subAttr(); ok {
+			path.SubAttribute = new(subAttr)
+		} else {
+			p.pos = end
 		}
-		return Path{
-			AttributePath: attrPath,
-		}, nil
+		p.pop(f, true)
+		if p.positions {
+			path.Span = Span{Start: start, End: p.pos}
+		}
+		return path, true
 	}
 
-	if node.Type != typ.Path {
-		return Path{}, invalidTypeError(typ.Path, node.Type)
+	// AttrPath
+	p.pos = start
+	attrPath, ok := p.attrPath()
+	p.pop(f, ok)
+	if !ok {
+		return Path{}, false
 	}
-
-	// ValuePath SubAttr?
-	valuePath, err := p.parseValuePath(children[0])
-	if err != nil {
-		return Path{}, err
+	path := Path{
+		AttributePath: attrPath,
 	}
-
-	var subAttr *string
-	if len(children) == 2 {
-		node := children[1]
-		if node.Type != typ.AttrName {
-			return Path{}, invalidTypeError(typ.AttrName, node.Type)
-		}
-		value := node.Value
-		subAttr = &value
+	if p.positions {
+		path.Span = Span{Start: start, End: p.pos}
 	}
-
-	return Path{
-		AttributePath:   valuePath.AttributePath,
-		ValueExpression: valuePath.ValueFilter,
-		SubAttribute:    subAttr,
-	}, nil
+	return path, true
 }
@@ -0,0 +1,271 @@
+// Package projection prunes resources to the attributes requested with the
+// "attributes" and "excludedAttributes" parameters.
+//
+// More info: https://tools.ietf.org/html/rfc7644#section-3.4.2.5
+package projection
+
+import (
+	filter "github.com/scim2/filter-parser/v2"
+	"github.com/scim2/filter-parser/v2/schema"
+	"strings"
+)
+
+// isExtension checks whether the given key of a resource is the URI of a
+// schema extension. Attribute names can not contain colons.
+func isExtension(key string) bool {
+	return strings.Contains(key, ":")
+}
+
+// projectValue projects the sub-attributes of the given (complex) value. If
+// include is not nil, only those sub-attributes are returned. Sub-attributes
+// that are returned on request are only returned if requested is true.
+func projectValue(value any, attribute *schema.Attribute, include, exclude map[string]bool, requested bool) any {
+	switch v := value.(type) {
+	case map[string]any:
+		projected := make(map[string]any)
+		for k, v := range v {
+			var subAttribute *schema.Attribute
+			if attribute != nil {
+				if a, ok := attribute.SubAttribute(k); ok {
+					subAttribute = &a
+				}
+			}
+			name := strings.ToLower(k)
+			switch r := returned(subAttribute); {
+			case r == schema.Never:
+				continue
+			case r == schema.Always:
+			case include != nil:
+				if !include[name] {
+					continue
+				}
+			case exclude[name]:
+				continue
+			case r == schema.Request && !requested:
+				continue
+			}
+			projected[k] = v
+		}
+		if len(projected) == 0 {
+			return nil
+		}
+		return projected
+	case []any:
+		if len(v) == 0 {
+			return v
+		}
+		var projected []any
+		for _, v := range v {
+			if v := projectValue(v, attribute, include, exclude, requested); v != nil {
+				projected = append(projected, v)
+			}
+		}
+		if len(projected) == 0 {
+			return nil
+		}
+		return projected
+	default:
+		return value
+	}
+}
+
+// returned returns the "returned" characteristic of the given attribute.
+// Unknown attributes are returned by default.
+func returned(attribute *schema.Attribute) schema.Returned {
+	if attribute == nil || attribute.Returned == "" {
+		return schema.Default
+	}
+	return schema.Returned(strings.ToLower(string(attribute.Returned)))
+}
+
+// Projector projects resources according to the "attributes" and
+// "excludedAttributes" parameters.
+//
+// The following rules apply:
+//   - "id" and "schemas" are always returned,
+//   - if Attributes is not empty, only those attributes are returned,
+//   - otherwise all attributes except ExcludedAttributes are returned.
+//
+// If a schema is supplied, the "returned" characteristic of its attributes is
+// respected: attributes that are returned "always" can not be excluded,
+// attributes that are returned "never" are never returned and attributes
+// that are returned on "request" are only returned if they are listed in
+// Attributes.
+//
+// Attribute paths with the URI of a schema extension refer to the attributes
+// of the extension object in the resource (e.g.
+// "urn:ietf:params:scim:schemas:extension:enterprise:2.0:User:employeeNumber"),
+// the URI itself refers to the extension object as a whole. Attribute paths
+// with the URI of the core schema refer to the attributes of the resource.
+type Projector struct {
+	// Attributes are the attributes to return.
+	Attributes []filter.AttributePath
+	// ExcludedAttributes are the attributes to exclude. They are ignored if
+	// Attributes is not empty.
+	ExcludedAttributes []filter.AttributePath
+
+	// Schema is the (optional) core schema of the resources.
+	Schema *schema.Schema
+	// Extensions are the (optional) schema extensions of the resources.
+	Extensions []schema.Schema
+}
+
+// Project returns a copy of the given resource that only contains the
+// requested attributes. The resource itself is not modified, but the returned
+// resource shares the values that are returned as a whole.
+func (p Projector) Project(resource map[string]any) map[string]any {
+	include := p.selections(p.Attributes, resource)
+	exclude := p.selections(p.ExcludedAttributes, resource)
+
+	projected := p.projectObject(resource, p.Schema, true, include[""], exclude[""])
+	for k, v := range resource {
+		if !isExtension(k) {
+			continue
+		}
+		extension, ok := v.(map[string]any)
+		if !ok {
+			continue
+		}
+		uri := strings.ToLower(k)
+		if excluded := exclude[uri]; excluded != nil && excluded.all && len(p.Attributes) == 0 {
+			continue
+		}
+		if obj := p.projectObject(extension, p.extension(k), false, include[uri], exclude[uri]); len(obj) != 0 {
+			projected[k] = obj
+		}
+	}
+	return projected
+}
+
+// extension returns the schema extension with the given (case-insensitive)
+// URI, or nil if there is none.
+func (p Projector) extension(uri string) *schema.Schema {
+	for i, extension := range p.Extensions {
+		if strings.EqualFold(extension.ID, uri) {
+			return &p.Extensions[i]
+		}
+	}
+	return nil
+}
+
+// projectObject projects the attributes of the resource (core) or an extension
+// object.
+func (p Projector) projectObject(obj map[string]any, s *schema.Schema, core bool, include, exclude *selection) map[string]any {
+	projected := make(map[string]any)
+	for k, v := range obj {
+		if core && isExtension(k) {
+			continue
+		}
+
+		var attribute *schema.Attribute
+		if s != nil {
+			if a, ok := s.Attribute(k); ok {
+				attribute = &a
+			}
+		}
+		r := returned(attribute)
+		if core && (strings.EqualFold(k, "id") || strings.EqualFold(k, "schemas")) {
+			r = schema.Always
+		}
+		name := strings.ToLower(k)
+		switch {
+		case r == schema.Never:
+			continue
+		case r == schema.Always:
+			v = projectValue(v, attribute, nil, nil, true)
+		case len(p.Attributes) != 0:
+			if include == nil {
+				continue
+			}
+			subAttributes, ok := include.attributes[name]
+			if include.all {
+				subAttributes = nil
+			} else if !ok {
+				continue
+			}
+			v = projectValue(v, attribute, subAttributes, nil, true)
+		default:
+			if r == schema.Request {
+				continue
+			}
+			var subAttributes map[string]bool
+			if exclude != nil {
+				s, ok := exclude.attributes[name]
+				if ok && s == nil {
+					continue
+				}
+				subAttributes = s
+			}
+			v = projectValue(v, attribute, nil, subAttributes, false)
+		}
+		if v != nil {
+			projected[k] = v
+		}
+	}
+	return projected
+}
+
+// selections groups the given attribute paths by the (lowercase) URI of the
+// object they refer to. The attributes of the resource itself are grouped by
+// the empty string.
+func (p Projector) selections(attrPaths []filter.AttributePath, resource map[string]any) map[string]*selection {
+	extensions := make(map[string]bool)
+	for k, v := range resource {
+		if _, ok := v.(map[string]any); ok && isExtension(k) {
+			extensions[strings.ToLower(k)] = true
+		}
+	}
+	for _, extension := range p.Extensions {
+		extensions[strings.ToLower(extension.ID)] = true
+	}
+
+	selections := make(map[string]*selection)
+	get := func(uri string) *selection {
+		s, ok := selections[uri]
+		if !ok {
+			s = &selection{attributes: make(map[string]map[string]bool)}
+			selections[uri] = s
+		}
+		return s
+	}
+	for _, attrPath := range attrPaths {
+		var uri string
+		if attrPath.URIPrefix != nil {
+			full := strings.ToLower(*attrPath.URIPrefix + ":" + attrPath.AttributeName)
+			if attrPath.SubAttribute == nil && extensions[full] {
+				get(full).all = true
+				continue
+			}
+			if prefix := strings.ToLower(*attrPath.URIPrefix); extensions[prefix] {
+				uri = prefix
+			}
+		}
+
+		s := get(uri)
+		name := strings.ToLower(attrPath.AttributeName)
+		subAttributes, ok := s.attributes[name]
+		switch {
+		case attrPath.SubAttribute == nil:
+			s.attributes[name] = nil
+		case ok && subAttributes == nil:
+			// The attribute as a whole is already selected.
+		default:
+			if subAttributes == nil {
+				subAttributes = make(map[string]bool)
+				s.attributes[name] = subAttributes
+			}
+			subAttributes[strings.ToLower(*attrPath.SubAttribute)] = true
+		}
+	}
+	return selections
+}
+
+// selection contains the selected attributes of an object.
+type selection struct {
+	// all indicates that the object as a whole is selected.
+	all bool
+	// attributes maps the (lowercase) names of the selected attributes to
+	// their selected (lowercase) sub-attributes. A nil map indicates that the
+	// attribute as a whole is selected.
+	attributes map[string]map[string]bool
+}
@@ -0,0 +1,228 @@
+package projection
+
+import (
+	"encoding/json"
+	"fmt"
+	filter "github.com/scim2/filter-parser/v2"
+	"github.com/scim2/filter-parser/v2/schema"
+	"reflect"
+	"testing"
+)
+
+const testUser = `{
+	"schemas": [
+		"urn:ietf:params:scim:schemas:core:2.0:User",
+		"urn:ietf:params:scim:schemas:extension:enterprise:2.0:User"
+	],
+	"id": "2819c223-7f76-453a-919d-413861904646",
+	"userName": "bjensen",
+	"password": "t1meMa$heen",
+	"name": {
+		"familyName": "Jensen",
+		"givenName": "Barbara"
+	},
+	"emails": [
+		{"value": "bjensen@example.com", "type": "work", "primary": true},
+		{"value": "babs@jensen.org", "type": "home"}
+	],
+	"groups": [
+		{"value": "e9e30dba-f08f-4109-8486-d5c6a331660a", "display": "Tour Guides"}
+	],
+	"urn:ietf:params:scim:schemas:extension:enterprise:2.0:User": {
+		"employeeNumber": "701984",
+		"costCenter": "4130"
+	}
+}`
+
+const testSchemas = `[{
+	"id": "urn:ietf:params:scim:schemas:core:2.0:User",
+	"attributes": [
+		{"name": "userName", "type": "string", "returned": "always"},
+		{"name": "password", "type": "string", "returned": "never"},
+		{"name": "name", "type": "complex", "subAttributes": [
+			{"name": "familyName", "type": "string"},
+			{"name": "givenName", "type": "string"}
+		]},
+		{"name": "emails", "type": "complex", "multiValued": true, "subAttributes": [
+			{"name": "value", "type": "string", "returned": "always"},
+			{"name": "type", "type": "string"},
+			{"name": "primary", "type": "boolean", "returned": "request"}
+		]},
+		{"name": "groups", "type": "complex", "multiValued": true, "returned": "request", "subAttributes": [
+			{"name": "value", "type": "string"},
+			{"name": "display", "type": "string"}
+		]}
+	]
+}, {
+	"id": "urn:ietf:params:scim:schemas:extension:enterprise:2.0:User",
+	"attributes": [
+		{"name": "employeeNumber", "type": "string"},
+		{"name": "costCenter", "type": "string", "returned": "never"}
+	]
+}]`
+
+func ExampleProjector_Project() {
+	var resource map[string]any
+	_ = json.Unmarshal([]byte(testUser), &resource)
+	attributes, _ := filter.ParseAttributeList([]byte("name.givenName,urn:ietf:params:scim:schemas:extension:enterprise:2.0:User:employeeNumber"))
+
+	raw, _ := json.MarshalIndent(Projector{Attributes: attributes}.Project(resource), "", "\t")
+	fmt.Println(string(raw))
+	// Output:
+	// {
+	// 	"id": "2819c223-7f76-453a-919d-413861904646",
+	// 	"name": {
+	// 		"givenName": "Barbara"
+	// 	},
+	// 	"schemas": [
+	// 		"urn:ietf:params:scim:schemas:core:2.0:User",
+	// 		"urn:ietf:params:scim:schemas:extension:enterprise:2.0:User"
+	// 	],
+	// 	"urn:ietf:params:scim:schemas:extension:enterprise:2.0:User": {
+	// 		"employeeNumber": "701984"
+	// 	}
+	// }
+}
+
+func TestProjector_Project(t *testing.T) {
+	schemas, err := schema.Parse([]byte(testSchemas))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, test := range []struct {
+		name       string
+		attributes string
+		excluded   string
+		schema     bool
+		expected   string
+	}{
+		{
+			name:     "all attributes",
+			expected: testUser,
+		},
+		{
+			name:       "attributes",
+			attributes: "USERNAME,emails.type,urn:ietf:params:scim:schemas:core:2.0:User:name",
+			expected: `{
+				"userName": "bjensen",
+				"name": {"familyName": "Jensen", "givenName": "Barbara"},
+				"emails": [{"type": "work"}, {"type": "home"}]
+			}`,
+		},
+		{
+			name:       "attribute and sub-attribute",
+			attributes: "name.familyName,name",
+			expected:   `{"name": {"familyName": "Jensen", "givenName": "Barbara"}}`,
+		},
+		{
+			name:       "missing attributes",
+			attributes: "nickName,name.middleName",
+			expected:   `{}`,
+		},
+		{
+			name:       "extension",
+			attributes: "urn:ietf:params:scim:schemas:extension:enterprise:2.0:User",
+			expected: `{"urn:ietf:params:scim:schemas:extension:enterprise:2.0:User": {
+				"employeeNumber": "701984",
+				"costCenter": "4130"
+			}}`,
+		},
+		{
+			name:     "excluded attributes",
+			excluded: "id,password,name.givenName,emails.primary,groups",
+			expected: `{
+				"userName": "bjensen",
+				"name": {"familyName": "Jensen"},
+				"emails": [
+					{"value": "bjensen@example.com", "type": "work"},
+					{"value": "babs@jensen.org", "type": "home"}
+				],
+				"urn:ietf:params:scim:schemas:extension:enterprise:2.0:User": {
+					"employeeNumber": "701984",
+					"costCenter": "4130"
+				}
+			}`,
+		},
+		{
+			name:     "excluded extension",
+			excluded: "urn:ietf:params:scim:schemas:extension:enterprise:2.0:User,password,name,emails,groups",
+			expected: `{"userName": "bjensen"}`,
+		},
+		{
+			name:   "schema",
+			schema: true,
+			expected: `{
+				"userName": "bjensen",
+				"name": {"familyName": "Jensen", "givenName": "Barbara"},
+				"emails": [
+					{"value": "bjensen@example.com", "type": "work"},
+					{"value": "babs@jensen.org", "type": "home"}
+				],
+				"urn:ietf:params:scim:schemas:extension:enterprise:2.0:User": {"employeeNumber": "701984"}
+			}`,
+		},
+		{
+			name:       "schema with attributes",
+			attributes: "password,emails.primary,groups,urn:ietf:params:scim:schemas:extension:enterprise:2.0:User:costCenter",
+			schema:     true,
+			expected: `{
+				"userName": "bjensen",
+				"emails": [
+					{"value": "bjensen@example.com", "primary": true},
+					{"value": "babs@jensen.org"}
+				],
+				"groups": [{"value": "e9e30dba-f08f-4109-8486-d5c6a331660a", "display": "Tour Guides"}]
+			}`,
+		},
+		{
+			name:     "schema with excluded attributes",
+			excluded: "userName,emails.value,emails.type,name",
+			schema:   true,
+			expected: `{
+				"userName": "bjensen",
+				"emails": [
+					{"value": "bjensen@example.com"},
+					{"value": "babs@jensen.org"}
+				],
+				"urn:ietf:params:scim:schemas:extension:enterprise:2.0:User": {"employeeNumber": "701984"}
+			}`,
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			var p Projector
+			if test.attributes != "" {
+				if p.Attributes, err = filter.ParseAttributeList([]byte(test.attributes)); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if test.excluded != "" {
+				if p.ExcludedAttributes, err = filter.ParseAttributeList([]byte(test.excluded)); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if test.schema {
+				p.Schema = &schemas[0]
+				p.Extensions = schemas[1:]
+			}
+
+			var resource, expected map[string]any
+			_ = json.Unmarshal([]byte(testUser), &resource)
+			if err := json.Unmarshal([]byte(test.expected), &expected); err != nil {
+				t.Fatal(err)
+			}
+			expected["id"] = resource["id"]
+			expected["schemas"] = resource["schemas"]
+
+			if projected := p.Project(resource); !reflect.DeepEqual(projected, expected) {
+				t.Errorf("expected %v, got %v", expected, projected)
+			}
+
+			var original map[string]any
+			_ = json.Unmarshal([]byte(testUser), &original)
+			if !reflect.DeepEqual(resource, original) {
+				t.Error("resource was modified")
+			}
+		})
+	}
+}
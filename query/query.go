@@ -0,0 +1,258 @@
+// Package query parses the query parameters of SCIM list and search requests.
+//
+// More info: https://tools.ietf.org/html/rfc7644#section-3.4.2
+package query
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	filter "github.com/scim2/filter-parser/v2"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// MaxBodySize is the maximum size of the body of a search request in bytes, 1
+// MiB by default. Larger bodies result in a 413 (Payload Too Large) error.
+var MaxBodySize int64 = 1 << 20
+
+// parser parses the filters and attribute lists of requests, which come from
+// untrusted clients.
+var parser = filter.NewParser(filter.WithLimits(filter.DefaultLimits))
+
+// FromContext returns the query stored in the given context by Middleware.
+func FromContext(ctx context.Context) (*Query, bool) {
+	q, ok := ctx.Value(contextKey{}).(*Query)
+	return q, ok
+}
+
+// Middleware parses the query of every request and stores it in the request
+// context, where it can be retrieved with FromContext. If the query is invalid,
+// an error response is written and the next handler is not called.
+func Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		q, err := Parse(r)
+		if err != nil {
+			WriteError(w, err)
+			return
+		}
+		next.ServeHTTP(w, r.WithContext(NewContext(r.Context(), q)))
+	})
+}
+
+// NewContext returns a copy of the given context that stores the query.
+func NewContext(ctx context.Context, q *Query) context.Context {
+	return context.WithValue(ctx, contextKey{}, q)
+}
+
+// Parse parses the query of the given request. POST requests to a path that
+// ends with "/.search" are parsed from the JSON body (a SearchRequest), all
+// other requests from the URL query parameters. The body is limited to
+// MaxBodySize bytes. The returned error is always an *Error.
+//
+// More info: https://tools.ietf.org/html/rfc7644#section-3.4.3
+func Parse(r *http.Request) (*Query, error) {
+	var req request
+	if r.Method == http.MethodPost && strings.HasSuffix(r.URL.Path, "/.search") {
+		body := http.MaxBytesReader(nil, r.Body, MaxBodySize)
+		if err := json.NewDecoder(body).Decode(&req); err != nil {
+			if _, ok := errors.AsType[*http.MaxBytesError](err); ok {
+				return nil, &Error{
+					Status: http.StatusRequestEntityTooLarge,
+					Detail: fmt.Sprintf("search request exceeds %d bytes", MaxBodySize),
+				}
+			}
+			return nil, &Error{
+				Status:   http.StatusBadRequest,
+				ScimType: "invalidSyntax",
+				Detail:   fmt.Sprintf("invalid search request: %v", err),
+			}
+		}
+		return req.parse()
+	}
+
+	values := r.URL.Query()
+	req = request{
+		Filter:    values.Get("filter"),
+		SortBy:    values.Get("sortBy"),
+		SortOrder: values.Get("sortOrder"),
+	}
+	if v := values.Get("attributes"); v != "" {
+		req.Attributes = []string{v}
+	}
+	if v := values.Get("excludedAttributes"); v != "" {
+		req.ExcludedAttributes = []string{v}
+	}
+	for _, p := range []struct {
+		name  string
+		value **int
+	}{
+		{name: "startIndex", value: &req.StartIndex},
+		{name: "count", value: &req.Count},
+	} {
+		v := values.Get(p.name)
+		if v == "" {
+			continue
+		}
+		i, err := strconv.Atoi(v)
+		if err != nil {
+			return nil, invalidValue("invalid %s %q: not an integer", p.name, v)
+		}
+		*p.value = &i
+	}
+	return req.parse()
+}
+
+// WriteError writes the given error as a SCIM error response. Errors that are
+// not of type *Error result in an internal server error.
+//
+// More info: https://tools.ietf.org/html/rfc7644#section-3.12
+func WriteError(w http.ResponseWriter, err error) {
+	e, ok := err.(*Error)
+	if !ok {
+		e = &Error{
+			Status: http.StatusInternalServerError,
+			Detail: err.Error(),
+		}
+	}
+	raw, _ := json.Marshal(e)
+	w.Header().Set("Content-Type", "application/scim+json")
+	w.WriteHeader(e.Status)
+	_, _ = w.Write(raw)
+}
+
+func invalidValue(format string, a ...any) *Error {
+	return &Error{
+		Status:   http.StatusBadRequest,
+		ScimType: "invalidValue",
+		Detail:   fmt.Sprintf(format, a...),
+	}
+}
+
+// parseAttributes parses the given (comma separated lists of) attribute names
+// of the given parameter.
+func parseAttributes(parameter string, names []string) ([]filter.AttributePath, error) {
+	if len(names) == 0 {
+		return nil, nil
+	}
+	attributes, err := parser.ParseAttributeList([]byte(strings.Join(names, ",")))
+	if err != nil {
+		return nil, invalidValue("invalid %s: %v", parameter, err)
+	}
+	return attributes, nil
+}
+
+// Error is a SCIM error response.
+//
+// More info: https://tools.ietf.org/html/rfc7644#section-3.12
+type Error struct {
+	// Status is the HTTP status code.
+	Status int
+	// ScimType is the SCIM detail error keyword, e.g. "invalidFilter".
+	ScimType string
+	// Detail is a human-readable description of the error.
+	Detail string
+}
+
+func (e *Error) Error() string {
+	if e.ScimType == "" {
+		return fmt.Sprintf("%d: %s", e.Status, e.Detail)
+	}
+	return fmt.Sprintf("%d %s: %s", e.Status, e.ScimType, e.Detail)
+}
+
+// MarshalJSON marshals the error as a SCIM error response, in which the status
+// is a JSON string.
+func (e *Error) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		Schemas  []string `json:"schemas"`
+		ScimType string   `json:"scimType,omitempty"`
+		Detail   string   `json:"detail,omitempty"`
+		Status   string   `json:"status"`
+	}{
+		Schemas:  []string{"urn:ietf:params:scim:api:messages:2.0:Error"},
+		ScimType: e.ScimType,
+		Detail:   e.Detail,
+		Status:   strconv.Itoa(e.Status),
+	})
+}
+
+// Query represents the query parameters of a SCIM list or search request.
+type Query struct {
+	// Filter is the parsed "filter" parameter, nil if absent.
+	Filter filter.Expression
+	// Sort is the parsed "sortBy" and "sortOrder" parameters, nil if "sortBy"
+	// is absent.
+	Sort *filter.SortSpec
+	// Attributes is the parsed "attributes" parameter.
+	Attributes []filter.AttributePath
+	// ExcludedAttributes is the parsed "excludedAttributes" parameter.
+	ExcludedAttributes []filter.AttributePath
+	// StartIndex is the 1-based index of the first result. Defaults to 1,
+	// values less than 1 are interpreted as 1.
+	StartIndex int
+	// Count is the maximum number of results, nil if absent. Negative values
+	// are interpreted as 0.
+	Count *int
+}
+
+type contextKey struct{}
+
+// request contains the raw parameters of a list or search request.
+type request struct {
+	Attributes         []string `json:"attributes"`
+	ExcludedAttributes []string `json:"excludedAttributes"`
+	Filter             string   `json:"filter"`
+	SortBy             string   `json:"sortBy"`
+	SortOrder          string   `json:"sortOrder"`
+	StartIndex         *int     `json:"startIndex"`
+	Count              *int     `json:"count"`
+}
+
+func (r request) parse() (*Query, error) {
+	q := Query{
+		StartIndex: 1,
+	}
+	if r.Filter != "" {
+		expr, err := parser.ParseFilter([]byte(r.Filter))
+		if err != nil {
+			scimType := "invalidFilter"
+			if limitErr, ok := errors.AsType[*filter.LimitError](err); ok {
+				scimType = limitErr.ScimType()
+			}
+			return nil, &Error{
+				Status:   http.StatusBadRequest,
+				ScimType: scimType,
+				Detail:   err.Error(),
+			}
+		}
+		q.Filter = expr
+	}
+
+	if r.SortBy != "" {
+		sort, err := filter.ParseSortSpec([]byte(r.SortBy), []byte(r.SortOrder))
+		if err != nil {
+			return nil, invalidValue("invalid sortBy or sortOrder: %v", err)
+		}
+		q.Sort = &sort
+	}
+
+	var err error
+	if q.Attributes, err = parseAttributes("attributes", r.Attributes); err != nil {
+		return nil, err
+	}
+	if q.ExcludedAttributes, err = parseAttributes("excludedAttributes", r.ExcludedAttributes); err != nil {
+		return nil, err
+	}
+
+	if r.StartIndex != nil && *r.StartIndex > 1 {
+		q.StartIndex = *r.StartIndex
+	}
+	if r.Count != nil {
+		count := max(*r.Count, 0)
+		q.Count = &count
+	}
+	return &q, nil
+}
@@ -0,0 +1,205 @@
+package query
+
+import (
+	"encoding/json"
+	"fmt"
+	filter "github.com/scim2/filter-parser/v2"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func ExampleMiddleware() {
+	handler := Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		q, _ := FromContext(r.Context())
+		fmt.Fprintln(w, q.Filter, q.Sort, q.StartIndex, *q.Count)
+	}))
+
+	for _, query := range []string{
+		`filter=userName eq "bjensen"&sortBy=name.familyName&startIndex=11&count=10`,
+		`filter=userName eq "bjensen" adn active eq true`,
+	} {
+		r := httptest.NewRequest(http.MethodGet, "/Users", nil)
+		r.URL.RawQuery = encode(query)
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		fmt.Print(w.Code, " ", w.Body.String())
+	}
+	// Output:
+	// 200 userName eq "bjensen" name.familyName ascending 11 10
+	// 400 {"schemas":["urn:ietf:params:scim:api:messages:2.0:Error"],"scimType":"invalidFilter","detail":"parse error at 1:23 in FilterAnd: expected \"and\" or \"or\", got \"adn\"","status":"400"}
+}
+
+func TestParse(t *testing.T) {
+	for _, test := range []struct {
+		query      string
+		filter     string
+		sort       string
+		attributes []string
+		excluded   []string
+		startIndex int
+		count      int
+	}{
+		{query: "", startIndex: 1, count: -1},
+		{query: "filter=title pr", filter: "title pr", startIndex: 1, count: -1},
+		{query: "sortBy=userName", sort: "userName ascending", startIndex: 1, count: -1},
+		{query: "sortBy=userName&sortOrder=DESCENDING", sort: "userName descending", startIndex: 1, count: -1},
+		{query: "sortOrder=descending", startIndex: 1, count: -1},
+		{
+			query:      "attributes=userName, name.givenName,urn:ietf:params:scim:schemas:extension:enterprise:2.0:User:employeeNumber",
+			attributes: []string{"userName", "name.givenName", "urn:ietf:params:scim:schemas:extension:enterprise:2.0:User:employeeNumber"},
+			startIndex: 1, count: -1,
+		},
+		{query: "excludedAttributes=emails,groups", excluded: []string{"emails", "groups"}, startIndex: 1, count: -1},
+		{query: "startIndex=0&count=-5", startIndex: 1, count: 0},
+		{query: "startIndex=3&count=0", startIndex: 3, count: 0},
+	} {
+		t.Run(test.query, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/Users", nil)
+			r.URL.RawQuery = encode(test.query)
+			q, err := Parse(r)
+			if err != nil {
+				t.Fatal(err)
+			}
+			checkQuery(t, q, test.filter, test.sort, test.attributes, test.excluded, test.startIndex, test.count)
+		})
+	}
+}
+
+func TestParse_invalid(t *testing.T) {
+	for _, test := range []struct {
+		method, target, body string
+		scimType             string
+	}{
+		{method: http.MethodGet, target: "/Users?filter=" + url.QueryEscape("userName eq"), scimType: "invalidFilter"},
+		{method: http.MethodGet, target: "/Users?sortBy=" + url.QueryEscape("name.")},
+		{method: http.MethodGet, target: "/Users?sortBy=userName&sortOrder=up"},
+		{method: http.MethodGet, target: "/Users?attributes=userName,,emails"},
+		{method: http.MethodGet, target: "/Users?excludedAttributes=-"},
+		{method: http.MethodGet, target: "/Users?startIndex=one"},
+		{method: http.MethodGet, target: "/Users?count=1.5"},
+		{method: http.MethodPost, target: "/.search", body: `{"filter": "userName eq"}`, scimType: "invalidFilter"},
+		{method: http.MethodPost, target: "/.search", body: `{"filter": "` + strings.Repeat("(", 100) + `title pr` + strings.Repeat(")", 100) + `"}`, scimType: "tooMany"},
+		{method: http.MethodPost, target: "/Users/.search", body: `{"filter": `, scimType: "invalidSyntax"},
+		{method: http.MethodPost, target: "/Users/.search", body: `{"count": "10"}`, scimType: "invalidSyntax"},
+	} {
+		t.Run(test.target, func(t *testing.T) {
+			if test.scimType == "" {
+				test.scimType = "invalidValue"
+			}
+			handler := Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				t.Error("handler should not be called")
+			}))
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, httptest.NewRequest(test.method, test.target, strings.NewReader(test.body)))
+			if w.Code != http.StatusBadRequest {
+				t.Errorf("expected status 400, got %d", w.Code)
+			}
+			if ct := w.Header().Get("Content-Type"); ct != "application/scim+json" {
+				t.Errorf("expected content type application/scim+json, got %s", ct)
+			}
+			var body map[string]any
+			if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+				t.Fatal(err)
+			}
+			if body["status"] != "400" || body["scimType"] != test.scimType || body["detail"] == "" {
+				t.Errorf("unexpected error response: %s", w.Body)
+			}
+		})
+	}
+}
+
+func TestParse_search(t *testing.T) {
+	r := httptest.NewRequest(http.MethodPost, "/Users/.search", strings.NewReader(`{
+		"schemas": ["urn:ietf:params:scim:api:messages:2.0:SearchRequest"],
+		"attributes": ["displayName", "userName"],
+		"excludedAttributes": ["emails"],
+		"filter": "displayName sw \"smith\"",
+		"sortBy": "userName",
+		"startIndex": 1,
+		"count": 10
+	}`))
+	// Query parameters are ignored for search requests.
+	r.URL.RawQuery = encode("filter=title pr")
+	q, err := Parse(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkQuery(t, q, `displayName sw "smith"`, "userName ascending", []string{"displayName", "userName"}, []string{"emails"}, 1, 10)
+
+	// A search request without a body.
+	q, err = Parse(httptest.NewRequest(http.MethodPost, "/.search", strings.NewReader("{}")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkQuery(t, q, "", "", nil, nil, 1, -1)
+}
+
+func TestParse_tooLarge(t *testing.T) {
+	body := `{"filter": "` + strings.Repeat("a", int(MaxBodySize)) + ` pr"}`
+	r := httptest.NewRequest(http.MethodPost, "/Users/.search", strings.NewReader(body))
+	w := httptest.NewRecorder()
+	Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Error("unexpected call of the next handler")
+	})).ServeHTTP(w, r)
+	if w.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("expected status 413, got %d: %s", w.Code, w.Body)
+	}
+}
+
+func TestWriteError(t *testing.T) {
+	w := httptest.NewRecorder()
+	WriteError(w, fmt.Errorf("database unavailable"))
+	if w.Code != http.StatusInternalServerError {
+		t.Errorf("expected status 500, got %d", w.Code)
+	}
+	if body := w.Body.String(); body != `{"schemas":["urn:ietf:params:scim:api:messages:2.0:Error"],"detail":"database unavailable","status":"500"}` {
+		t.Errorf("unexpected error response: %s", body)
+	}
+}
+
+func checkQuery(t *testing.T, q *Query, expr, sort string, attributes, excluded []string, startIndex, count int) {
+	t.Helper()
+	if s := fmt.Sprint(q.Filter); q.Filter != nil && s != expr || q.Filter == nil && expr != "" {
+		t.Errorf("expected filter %q, got %q", expr, s)
+	}
+	if q.Sort != nil && q.Sort.String() != sort || q.Sort == nil && sort != "" {
+		t.Errorf("expected sort %q, got %v", sort, q.Sort)
+	}
+	for _, p := range []struct {
+		expected []string
+		actual   []filter.AttributePath
+	}{
+		{expected: attributes, actual: q.Attributes},
+		{expected: excluded, actual: q.ExcludedAttributes},
+	} {
+		if len(p.expected) != len(p.actual) {
+			t.Errorf("expected attributes %v, got %v", p.expected, p.actual)
+			continue
+		}
+		for i, attrPath := range p.actual {
+			if attrPath.String() != p.expected[i] {
+				t.Errorf("expected attribute %s, got %s", p.expected[i], attrPath)
+			}
+		}
+	}
+	if q.StartIndex != startIndex {
+		t.Errorf("expected startIndex %d, got %d", startIndex, q.StartIndex)
+	}
+	if q.Count == nil && count != -1 || q.Count != nil && *q.Count != count {
+		t.Errorf("expected count %d, got %v", count, q.Count)
+	}
+}
+
+// encode encodes the given unescaped query, e.g. filter=title pr.
+func encode(query string) string {
+	values := url.Values{}
+	for _, pair := range strings.Split(query, "&") {
+		if k, v, ok := strings.Cut(pair, "="); ok {
+			values.Set(k, v)
+		}
+	}
+	return values.Encode()
+}
@@ -0,0 +1,126 @@
+// Package schema validates filters and paths against SCIM schema definitions.
+//
+// More info: https://tools.ietf.org/html/rfc7643#section-7
+package schema
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"strings"
+)
+
+const (
+	// String is a sequence of zero or more Unicode characters.
+	String Type = "string"
+	// Boolean is either true or false.
+	Boolean Type = "boolean"
+	// Decimal is a real number with at least one digit to the left and right of
+	// the period.
+	Decimal Type = "decimal"
+	// Integer is a decimal number with no fractional digits.
+	Integer Type = "integer"
+	// DateTime is a string that is a valid xsd:dateTime.
+	DateTime Type = "dateTime"
+	// Binary is a base64 encoded string.
+	Binary Type = "binary"
+	// Reference is a URI for a resource.
+	Reference Type = "reference"
+	// Complex is a singular or multi-valued attribute whose value is a
+	// composition of one or more simple attributes.
+	Complex Type = "complex"
+
+	// Always indicates that the attribute is always returned.
+	Always Returned = "always"
+	// Never indicates that the attribute is never returned.
+	Never Returned = "never"
+	// Default indicates that the attribute is returned by default.
+	Default Returned = "default"
+	// Request indicates that the attribute is only returned if requested.
+	Request Returned = "request"
+)
+
+// Parse parses the given raw JSON as one or more schemas. It accepts a single
+// schema, a JSON array of schemas and a list response as returned by the
+// "/Schemas" endpoint.
+func Parse(raw []byte) ([]Schema, error) {
+	raw = bytes.TrimSpace(raw)
+	if len(raw) == 0 {
+		return nil, errors.New("no schemas found")
+	}
+
+	if raw[0] == '[' {
+		var schemas []Schema
+		if err := json.Unmarshal(raw, &schemas); err != nil {
+			return nil, err
+		}
+		return schemas, nil
+	}
+
+	var resource struct {
+		Schema
+		Resources []Schema `json:"Resources"`
+	}
+	if err := json.Unmarshal(raw, &resource); err != nil {
+		return nil, err
+	}
+	if resource.Resources != nil {
+		return resource.Resources, nil
+	}
+	if resource.ID == "" {
+		return nil, errors.New("no schemas found")
+	}
+	return []Schema{resource.Schema}, nil
+}
+
+// find returns the attribute with the given (case-insensitive) name.
+func find(attributes []Attribute, name string) (Attribute, bool) {
+	for _, attribute := range attributes {
+		if strings.EqualFold(attribute.Name, name) {
+			return attribute, true
+		}
+	}
+	return Attribute{}, false
+}
+
+// Attribute describes an attribute of a schema.
+// More info: https://tools.ietf.org/html/rfc7643#section-7
+type Attribute struct {
+	Name            string      `json:"name"`
+	Type            Type        `json:"type"`
+	SubAttributes   []Attribute `json:"subAttributes,omitempty"`
+	MultiValued     bool        `json:"multiValued"`
+	Description     string      `json:"description,omitempty"`
+	Required        bool        `json:"required"`
+	CanonicalValues []string    `json:"canonicalValues,omitempty"`
+	CaseExact       bool        `json:"caseExact"`
+	Mutability      string      `json:"mutability,omitempty"`
+	Returned        Returned    `json:"returned,omitempty"`
+	Uniqueness      string      `json:"uniqueness,omitempty"`
+	ReferenceTypes  []string    `json:"referenceTypes,omitempty"`
+}
+
+// SubAttribute returns the sub-attribute with the given (case-insensitive)
+// name.
+func (a Attribute) SubAttribute(name string) (Attribute, bool) {
+	return find(a.SubAttributes, name)
+}
+
+// Returned indicates when an attribute and its associated values are returned.
+type Returned string
+
+// Schema describes a SCIM schema, e.g. the core user schema or an extension.
+type Schema struct {
+	ID          string      `json:"id"`
+	Name        string      `json:"name,omitempty"`
+	Description string      `json:"description,omitempty"`
+	Attributes  []Attribute `json:"attributes"`
+}
+
+// Attribute returns the attribute with the given (case-insensitive) name.
+func (s Schema) Attribute(name string) (Attribute, bool) {
+	return find(s.Attributes, name)
+}
+
+// Type is the data type of an attribute.
+type Type string
@@ -0,0 +1,85 @@
+package schema
+
+import (
+	"fmt"
+	"testing"
+)
+
+const userSchema = `{
+	"id": "urn:ietf:params:scim:schemas:core:2.0:User",
+	"name": "User",
+	"attributes": [
+		{"name": "userName", "type": "string", "required": true, "uniqueness": "server"},
+		{"name": "password", "type": "string", "returned": "never"},
+		{"name": "name", "type": "complex", "subAttributes": [
+			{"name": "familyName", "type": "string"},
+			{"name": "givenName", "type": "string"}
+		]},
+		{"name": "active", "type": "boolean"},
+		{"name": "x509Certificates", "type": "complex", "multiValued": true, "subAttributes": [
+			{"name": "value", "type": "binary"}
+		]},
+		{"name": "emails", "type": "complex", "multiValued": true, "subAttributes": [
+			{"name": "value", "type": "string"},
+			{"name": "type", "type": "string", "canonicalValues": ["work", "home", "other"]},
+			{"name": "primary", "type": "boolean"}
+		]},
+		{"name": "meta", "type": "complex", "subAttributes": [
+			{"name": "created", "type": "dateTime"},
+			{"name": "lastModified", "type": "dateTime"}
+		]}
+	]
+}`
+
+const enterpriseSchema = `{
+	"id": "urn:ietf:params:scim:schemas:extension:enterprise:2.0:User",
+	"name": "EnterpriseUser",
+	"attributes": [
+		{"name": "employeeNumber", "type": "string"},
+		{"name": "costCenter", "type": "integer"},
+		{"name": "salary", "type": "decimal"}
+	]
+}`
+
+func ExampleParse() {
+	schemas, _ := Parse([]byte(`{
+		"schemas": ["urn:ietf:params:scim:api:messages:2.0:ListResponse"],
+		"totalResults": 2,
+		"Resources": [` + userSchema + `, ` + enterpriseSchema + `]
+	}`))
+	for _, schema := range schemas {
+		fmt.Println(schema.ID, len(schema.Attributes))
+	}
+	// Output:
+	// urn:ietf:params:scim:schemas:core:2.0:User 7
+	// urn:ietf:params:scim:schemas:extension:enterprise:2.0:User 3
+}
+
+func TestParse(t *testing.T) {
+	for _, raw := range []string{
+		userSchema,
+		"[" + userSchema + "]",
+		`{"Resources": [` + userSchema + `]}`,
+	} {
+		schemas, err := Parse([]byte(raw))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(schemas) != 1 {
+			t.Fatalf("expected 1 schema, got %d", len(schemas))
+		}
+		attribute, ok := schemas[0].Attribute("EMAILS")
+		if !ok {
+			t.Fatal("emails not found")
+		}
+		if _, ok := attribute.SubAttribute("Primary"); !ok {
+			t.Error("emails.primary not found")
+		}
+	}
+
+	for _, raw := range []string{"", "{}", "[", "{\"id\": 1}"} {
+		if _, err := Parse([]byte(raw)); err == nil {
+			t.Errorf("expected an error for %q", raw)
+		}
+	}
+}
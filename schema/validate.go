@@ -0,0 +1,313 @@
+package schema
+
+import (
+	"encoding/json"
+	"fmt"
+	filter "github.com/scim2/filter-parser/v2"
+	"math/big"
+	"strings"
+	"time"
+)
+
+// NewValidator creates a validator for a resource type with the given core
+// schema and schema extensions.
+func NewValidator(core Schema, extensions ...Schema) *Validator {
+	return &Validator{
+		core:       core,
+		extensions: extensions,
+	}
+}
+
+// isInteger checks whether the given compare value is an integer.
+func isInteger(value any) bool {
+	switch v := value.(type) {
+	case int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64:
+		return true
+	case json.Number:
+		return !strings.ContainsAny(string(v), ".eE")
+	case *big.Int:
+		return true
+	default:
+		return false
+	}
+}
+
+// isNumber checks whether the given compare value is a number.
+func isNumber(value any) bool {
+	switch value.(type) {
+	case float32, float64, *big.Rat:
+		return true
+	case json.Number:
+		return true
+	default:
+		return isInteger(value)
+	}
+}
+
+// validateAttrExp validates the operator and compare value of the given
+// attribute expression. Returns a message describing the problem, if any.
+func validateAttrExp(attribute Attribute, e *filter.AttributeExpression) string {
+	op := filter.CompareOperator(strings.ToLower(string(e.Operator)))
+	if op == filter.PR {
+		return ""
+	}
+
+	if attribute.Type == Complex {
+		value, ok := attribute.SubAttribute("value")
+		if !ok {
+			return fmt.Sprintf("operator %q is not supported on complex attributes", op)
+		}
+		attribute = value
+	}
+
+	switch op {
+	case filter.GT, filter.GE, filter.LT, filter.LE:
+		if attribute.Type == Boolean || attribute.Type == Binary {
+			return fmt.Sprintf("operator %q is not supported on %s attributes", op, attribute.Type)
+		}
+		if e.CompareValue == nil {
+			return fmt.Sprintf("operator %q is not supported on null", op)
+		}
+	case filter.CO, filter.SW, filter.EW:
+		if e.CompareValue == nil {
+			return fmt.Sprintf("operator %q is not supported on null", op)
+		}
+	case filter.EQ, filter.NE:
+		if e.CompareValue == nil {
+			return ""
+		}
+	default:
+		return fmt.Sprintf("invalid compare operator %q", op)
+	}
+
+	var valid bool
+	switch value := e.CompareValue; attribute.Type {
+	case String, Reference, Binary:
+		_, valid = value.(string)
+	case DateTime:
+		if s, ok := value.(string); ok {
+			if _, err := time.Parse(time.RFC3339Nano, s); err != nil {
+				return fmt.Sprintf("invalid dateTime value %q", s)
+			}
+			valid = true
+		}
+	case Boolean:
+		_, valid = value.(bool)
+	case Integer:
+		valid = isInteger(value)
+	case Decimal:
+		valid = isNumber(value)
+	default:
+		valid = true
+	}
+	if !valid {
+		return fmt.Sprintf("compare value %v is not of type %s", e.CompareValue, attribute.Type)
+	}
+	return ""
+}
+
+// Diagnostic describes a problem with an attribute path in a filter or path.
+type Diagnostic struct {
+	// AttributePath is the offending attribute path. Attribute paths within
+	// value filters are combined with the attribute path of the value path,
+	// e.g. emails[type eq "work"] results in "emails.type". Its span is set if
+	// the filter was parsed with filter.TrackPositions, in which case it
+	// points at the attribute path within the filter.
+	AttributePath filter.AttributePath
+	// Message describes the problem.
+	Message string
+}
+
+func (d Diagnostic) String() string {
+	return fmt.Sprintf("%s: %s", d.AttributePath, d.Message)
+}
+
+// Diagnostics is a list of diagnostics. It implements the error interface, but
+// should be returned as an error with Err, since an empty list stored in an
+// error is not nil. The error should result in an "invalidFilter" or
+// "invalidPath" error.
+type Diagnostics []Diagnostic
+
+// Err returns the diagnostics as an error, or nil if there are none.
+func (d Diagnostics) Err() error {
+	if len(d) == 0 {
+		return nil
+	}
+	return d
+}
+
+func (d Diagnostics) Error() string {
+	s := make([]string, len(d))
+	for i, d := range d {
+		s[i] = d.String()
+	}
+	return strings.Join(s, "; ")
+}
+
+// Validator validates filters and paths against the schemas of a resource type.
+type Validator struct {
+	core       Schema
+	extensions []Schema
+}
+
+// ValidateFilter validates the given filter. It reports:
+//   - unknown schemas, attributes or sub-attributes,
+//   - sub-attributes and value filters on attributes that are not complex,
+//   - ordering operators (gt, ge, lt, le) on boolean or binary attributes,
+//   - compare values that do not match the type of the attribute.
+//
+// Complex attributes without a sub-attribute are compared to their "value"
+// sub-attribute (e.g. emails co "example.com").
+func (v *Validator) ValidateFilter(expr filter.Expression) Diagnostics {
+	var diagnostics Diagnostics
+	v.validate(expr, nil, &diagnostics)
+	return diagnostics
+}
+
+// ValidatePath validates the given (PATCH) path. See ValidateFilter for more
+// information.
+func (v *Validator) ValidatePath(path filter.Path) Diagnostics {
+	var diagnostics Diagnostics
+	attribute, ok := v.resolve(path.AttributePath, nil, &diagnostics)
+	if !ok {
+		return diagnostics
+	}
+	if path.ValueExpression != nil {
+		if attribute.Type != Complex {
+			diagnostics = append(diagnostics, Diagnostic{
+				AttributePath: path.AttributePath,
+				Message:       "value filter on an attribute that is not complex",
+			})
+			return diagnostics
+		}
+		v.validate(path.ValueExpression, &valuePath{
+			path:      path.AttributePath,
+			attribute: attribute,
+		}, &diagnostics)
+	}
+	if path.SubAttribute != nil {
+		attrPath := path.AttributePath
+		attrPath.SubAttribute = path.SubAttribute
+		if _, ok := attribute.SubAttribute(*path.SubAttribute); !ok {
+			diagnostics = append(diagnostics, Diagnostic{
+				AttributePath: attrPath,
+				Message:       "unknown sub-attribute",
+			})
+		}
+	}
+	return diagnostics
+}
+
+// resolve resolves the given attribute path within the value path, or the
+// schemas of the validator if the value path is nil.
+func (v *Validator) resolve(path filter.AttributePath, parent *valuePath, diagnostics *Diagnostics) (Attribute, bool) {
+	report := func(path filter.AttributePath, message string) (Attribute, bool) {
+		*diagnostics = append(*diagnostics, Diagnostic{
+			AttributePath: path,
+			Message:       message,
+		})
+		return Attribute{}, false
+	}
+
+	if parent != nil {
+		// Attributes within value filters are sub-attributes of the value
+		// path, e.g. emails[type eq "work"].
+		full := parent.path
+		full.SubAttribute = &path.AttributeName
+		full.Span = path.Span
+		if path.URIPrefix != nil || path.SubAttribute != nil {
+			return report(full, "value filters can only contain sub-attributes")
+		}
+		attribute, ok := parent.attribute.SubAttribute(path.AttributeName)
+		if !ok {
+			return report(full, "unknown sub-attribute")
+		}
+		return attribute, true
+	}
+
+	schema := v.core
+	if path.URIPrefix != nil {
+		var ok bool
+		if schema, ok = v.schema(*path.URIPrefix); !ok {
+			return report(path, fmt.Sprintf("unknown schema %q", *path.URIPrefix))
+		}
+	}
+	attribute, ok := schema.Attribute(path.AttributeName)
+	if !ok {
+		withoutSubAttr := path
+		withoutSubAttr.SubAttribute = nil
+		return report(withoutSubAttr, "unknown attribute")
+	}
+	if path.SubAttribute == nil {
+		return attribute, true
+	}
+	if attribute.Type != Complex {
+		return report(path, "sub-attribute on an attribute that is not complex")
+	}
+	subAttribute, ok := attribute.SubAttribute(*path.SubAttribute)
+	if !ok {
+		return report(path, "unknown sub-attribute")
+	}
+	return subAttribute, true
+}
+
+// schema returns the schema with the given (case-insensitive) URI.
+func (v *Validator) schema(uri string) (Schema, bool) {
+	if strings.EqualFold(v.core.ID, uri) {
+		return v.core, true
+	}
+	for _, extension := range v.extensions {
+		if strings.EqualFold(extension.ID, uri) {
+			return extension, true
+		}
+	}
+	return Schema{}, false
+}
+
+func (v *Validator) validate(expr filter.Expression, parent *valuePath, diagnostics *Diagnostics) {
+	switch e := expr.(type) {
+	case *filter.AttributeExpression:
+		attribute, ok := v.resolve(e.AttributePath, parent, diagnostics)
+		if !ok {
+			return
+		}
+		path := e.AttributePath
+		if parent != nil {
+			path = parent.path
+			path.SubAttribute = &e.AttributePath.AttributeName
+			path.Span = e.AttributePath.Span
+		}
+		if message := validateAttrExp(attribute, e); message != "" {
+			*diagnostics = append(*diagnostics, Diagnostic{
+				AttributePath: path,
+				Message:       message,
+			})
+		}
+	case *filter.LogicalExpression:
+		v.validate(e.Left, parent, diagnostics)
+		v.validate(e.Right, parent, diagnostics)
+	case *filter.NotExpression:
+		v.validate(e.Expression, parent, diagnostics)
+	case *filter.ValuePath:
+		attribute, ok := v.resolve(e.AttributePath, parent, diagnostics)
+		if !ok {
+			return
+		}
+		if attribute.Type != Complex {
+			*diagnostics = append(*diagnostics, Diagnostic{
+				AttributePath: e.AttributePath,
+				Message:       "value filter on an attribute that is not complex",
+			})
+			return
+		}
+		v.validate(e.ValueFilter, &valuePath{
+			path:      e.AttributePath,
+			attribute: attribute,
+		}, diagnostics)
+	}
+}
+
+type valuePath struct {
+	path      filter.AttributePath
+	attribute Attribute
+}
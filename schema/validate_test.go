@@ -0,0 +1,157 @@
+package schema
+
+import (
+	"fmt"
+	filter "github.com/scim2/filter-parser/v2"
+	"strings"
+	"testing"
+)
+
+func ExampleDiagnostics_Err() {
+	validator := newTestValidator()
+	for _, raw := range []string{"userName eq \"bjensen\"", "nickName pr"} {
+		expression, _ := filter.ParseFilter([]byte(raw))
+		if err := validator.ValidateFilter(expression).Err(); err != nil {
+			fmt.Println(err)
+		} else {
+			fmt.Println("valid")
+		}
+	}
+	// Output:
+	// valid
+	// nickName: unknown attribute
+}
+
+func ExampleValidator_ValidateFilter() {
+	validator := newTestValidator()
+	expression, _ := filter.ParseFilter([]byte("userName eq 1 and emails[tpye eq \"work\"] and active gt true"))
+	for _, diagnostic := range validator.ValidateFilter(expression) {
+		fmt.Println(diagnostic)
+	}
+	// Output:
+	// userName: compare value 1 is not of type string
+	// emails.tpye: unknown sub-attribute
+	// active: operator "gt" is not supported on boolean attributes
+}
+
+func ExampleValidator_ValidateFilter_positions() {
+	validator := newTestValidator()
+	raw := []byte("userName eq \"bjensen\" and emails[tpye eq \"work\"]")
+	expression, _ := filter.NewParser(filter.TrackPositions()).ParseFilter(raw)
+	for _, diagnostic := range validator.ValidateFilter(expression) {
+		span := diagnostic.AttributePath.Span
+		fmt.Println(diagnostic)
+		fmt.Printf("%s\n%s%s\n", raw, strings.Repeat(" ", span.Start), strings.Repeat("^", span.End-span.Start))
+	}
+	// Output:
+	// emails.tpye: unknown sub-attribute
+	// userName eq "bjensen" and emails[tpye eq "work"]
+	//                                  ^^^^
+}
+
+func TestDiagnostics_Err(t *testing.T) {
+	var diagnostics Diagnostics
+	if err := diagnostics.Err(); err != nil {
+		t.Errorf("expected nil, got %v", err)
+	}
+	if err := (Diagnostics{}).Err(); err != nil {
+		t.Errorf("expected nil, got %v", err)
+	}
+	diagnostics = append(diagnostics, Diagnostic{Message: "unknown attribute"})
+	if err := diagnostics.Err(); err == nil || err.Error() != diagnostics.Error() {
+		t.Errorf("expected %v, got %v", diagnostics, err)
+	}
+}
+
+func TestValidator_ValidateFilter(t *testing.T) {
+	validator := newTestValidator()
+	for _, test := range []struct {
+		filter      string
+		diagnostics []string
+	}{
+		{filter: "userName eq \"bjensen\""},
+		{filter: "USERNAME sw \"j\" and name.FamilyName co \"O'Malley\""},
+		{filter: "title pr", diagnostics: []string{"title: unknown attribute"}},
+		{filter: "name.middleName pr", diagnostics: []string{"name.middleName: unknown sub-attribute"}},
+		{filter: "userName.first pr", diagnostics: []string{"userName.first: sub-attribute on an attribute that is not complex"}},
+		{filter: "userName[value pr]", diagnostics: []string{"userName: value filter on an attribute that is not complex"}},
+		{filter: "emails[type eq \"work\" and (primary eq true or value ew \"@example.com\")]"},
+		{filter: "emails[primary eq \"true\"]", diagnostics: []string{"emails.primary: compare value true is not of type boolean"}},
+		{filter: "emails co \"example.com\" or emails.value co \"example.org\""},
+		{filter: "emails eq 1", diagnostics: []string{"emails: compare value 1 is not of type string"}},
+		{filter: "name eq \"x\"", diagnostics: []string{"name: operator \"eq\" is not supported on complex attributes"}},
+		{filter: "active eq true and active ne false and active pr"},
+		{filter: "active eq \"true\"", diagnostics: []string{"active: compare value true is not of type boolean"}},
+		{filter: "active le false", diagnostics: []string{"active: operator \"le\" is not supported on boolean attributes"}},
+		{filter: "x509Certificates.value gt \"a\"", diagnostics: []string{"x509Certificates.value: operator \"gt\" is not supported on binary attributes"}},
+		{filter: "meta.lastModified gt \"2011-05-13T04:42:34Z\""},
+		{filter: "meta.lastModified gt \"yesterday\"", diagnostics: []string{"meta.lastModified: invalid dateTime value \"yesterday\""}},
+		{filter: "meta.lastModified gt null", diagnostics: []string{"meta.lastModified: operator \"gt\" is not supported on null"}},
+		{filter: "userName eq null"},
+		{filter: "urn:ietf:params:scim:schemas:core:2.0:User:userName eq \"bjensen\""},
+		{filter: "urn:ietf:params:scim:schemas:extension:enterprise:2.0:User:costCenter ge 4130"},
+		{filter: "urn:ietf:params:scim:schemas:extension:enterprise:2.0:User:costCenter ge 41.5", diagnostics: []string{"urn:ietf:params:scim:schemas:extension:enterprise:2.0:User:costCenter: compare value 41.5 is not of type integer"}},
+		{filter: "urn:ietf:params:scim:schemas:extension:enterprise:2.0:User:salary lt 1e5"},
+		{filter: "urn:ietf:params:scim:schemas:extension:enterprise:2.0:User:salary lt \"1\"", diagnostics: []string{"urn:ietf:params:scim:schemas:extension:enterprise:2.0:User:salary: compare value 1 is not of type decimal"}},
+		{filter: "urn:example:unknown:1.0:User:x pr", diagnostics: []string{"urn:example:unknown:1.0:User:x: unknown schema \"urn:example:unknown:1.0:User\""}},
+		{filter: "not (title pr) or name.middleName pr", diagnostics: []string{"title: unknown attribute", "name.middleName: unknown sub-attribute"}},
+	} {
+		t.Run(test.filter, func(t *testing.T) {
+			for _, parse := range []func([]byte) (filter.Expression, error){filter.ParseFilter, filter.ParseFilterNumber} {
+				expression, err := parse([]byte(test.filter))
+				if err != nil {
+					t.Fatal(err)
+				}
+				diagnostics := validator.ValidateFilter(expression)
+				if len(diagnostics) != len(test.diagnostics) {
+					t.Fatalf("expected %v, got %v", test.diagnostics, diagnostics)
+				}
+				for i, diagnostic := range diagnostics {
+					if s := diagnostic.String(); s != test.diagnostics[i] {
+						t.Errorf("expected %s, got %s", test.diagnostics[i], s)
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestValidator_ValidatePath(t *testing.T) {
+	validator := newTestValidator()
+	for _, test := range []struct {
+		path        string
+		diagnostics []string
+	}{
+		{path: "userName"},
+		{path: "name.givenName"},
+		{path: "emails[type eq \"work\"].value"},
+		{path: "emails[type eq \"work\"].display", diagnostics: []string{"emails.display: unknown sub-attribute"}},
+		{path: "emails[kind eq \"work\"]", diagnostics: []string{"emails.kind: unknown sub-attribute"}},
+		{path: "active[value eq true]", diagnostics: []string{"active: value filter on an attribute that is not complex"}},
+		{path: "nickName", diagnostics: []string{"nickName: unknown attribute"}},
+	} {
+		t.Run(test.path, func(t *testing.T) {
+			path, err := filter.ParsePath([]byte(test.path))
+			if err != nil {
+				t.Fatal(err)
+			}
+			diagnostics := validator.ValidatePath(path)
+			if len(diagnostics) != len(test.diagnostics) {
+				t.Fatalf("expected %v, got %v", test.diagnostics, diagnostics)
+			}
+			for i, diagnostic := range diagnostics {
+				if s := diagnostic.String(); s != test.diagnostics[i] {
+					t.Errorf("expected %s, got %s", test.diagnostics[i], s)
+				}
+			}
+		})
+	}
+}
+
+func newTestValidator() *Validator {
+	schemas, err := Parse([]byte("[" + userSchema + ", " + enterpriseSchema + "]"))
+	if err != nil {
+		panic(err)
+	}
+	return NewValidator(schemas[0], schemas[1:]...)
+}
@@ -0,0 +1,178 @@
+package filter
+
+import (
+	"cmp"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+const (
+	// Ascending sorts in ascending order.
+	Ascending SortOrder = "ascending"
+	// Descending sorts in descending order.
+	Descending SortOrder = "descending"
+)
+
+// ParseSortSpec parses the given raw "sortBy" and "sortOrder" parameters as a
+// SortSpec. The sort order is case-insensitive and defaults to ascending.
+//
+// More info: https://tools.ietf.org/html/rfc7644#section-3.4.2.3
+func ParseSortSpec(sortBy, sortOrder []byte) (SortSpec, error) {
+	attrPath, err := ParseAttrPath(sortBy)
+	if err != nil {
+		return SortSpec{}, err
+	}
+	order := Ascending
+	if len(sortOrder) != 0 {
+		switch o := SortOrder(strings.ToLower(string(sortOrder))); o {
+		case Ascending, Descending:
+			order = o
+		default:
+			return SortSpec{}, fmt.Errorf("invalid sort order %q: expected %q or %q", sortOrder, Ascending, Descending)
+		}
+	}
+	return SortSpec{
+		AttributePath: attrPath,
+		Order:         order,
+	}, nil
+}
+
+// compareValues compares two (non-nil) attribute values. Values of different
+// types are ordered by type: booleans, numbers, strings and all other values.
+func compareValues(a, b any, caseExact bool) int {
+	rank := func(value any) int {
+		switch value.(type) {
+		case bool:
+			return 0
+		case string:
+			return 2
+		}
+		if _, ok := toFloat(value); ok {
+			return 1
+		}
+		return 3
+	}
+	if ra, rb := rank(a), rank(b); ra != rb {
+		return cmp.Compare(ra, rb)
+	}
+
+	switch a := a.(type) {
+	case bool:
+		b := b.(bool)
+		switch {
+		case a == b:
+			return 0
+		case !a:
+			return -1
+		default:
+			return 1
+		}
+	case string:
+		return compareStrings(a, b.(string), caseExact)
+	}
+	c, _ := compareNumbers(a, b)
+	return c
+}
+
+// isPrimary checks whether the given value is a complex value of which the
+// "primary" sub-attribute is true.
+func isPrimary(value any) bool {
+	complexValue, ok := value.(map[string]any)
+	if !ok {
+		return false
+	}
+	primary, _ := get(complexValue, "primary").(bool)
+	return primary
+}
+
+// sortValue returns the value of the given attribute path to sort by. For
+// multi-valued attributes this is the primary value, or the first value if
+// none is primary. For complex attributes without a sub-attribute this is the
+// "value" sub-attribute.
+func sortValue(path AttributePath, resource map[string]any) any {
+	if path.URIPrefix != nil {
+		if extension, ok := get(resource, *path.URIPrefix).(map[string]any); ok {
+			resource = extension
+		}
+	}
+
+	pick := func(values []any) any {
+		for _, value := range values {
+			if isPrimary(value) {
+				return value
+			}
+		}
+		if len(values) == 0 {
+			return nil
+		}
+		return values[0]
+	}
+	value := pick(flatten(get(resource, path.AttributeName)))
+	subAttribute := "value"
+	if path.SubAttribute != nil {
+		subAttribute = *path.SubAttribute
+	}
+	if complexValue, ok := value.(map[string]any); ok {
+		value = pick(flatten(get(complexValue, subAttribute)))
+	} else if path.SubAttribute != nil {
+		return nil
+	}
+	if !present(value) {
+		return nil
+	}
+	return value
+}
+
+// SortOrder is the order in which resources are sorted.
+type SortOrder string
+
+// SortSpec represents the "sortBy" and "sortOrder" parameters of a query.
+//
+// More info: https://tools.ietf.org/html/rfc7644#section-3.4.2.3
+type SortSpec struct {
+	AttributePath AttributePath
+	Order         SortOrder
+	// CaseExact indicates whether strings are compared case-sensitively. By
+	// default, strings are compared case-insensitively, as is the default for
+	// string attributes.
+	CaseExact bool
+}
+
+// Compare compares two resources by the attribute path of the sort spec. It
+// returns a negative number if a sorts before b, a positive number if a sorts
+// after b and zero otherwise.
+//
+// Multi-valued attributes are compared by their primary value, or their first
+// value if none is primary. Strings that are both valid RFC 3339 timestamps
+// are compared chronologically. Resources without a value for the attribute
+// path are sorted last, regardless of the sort order.
+func (s SortSpec) Compare(a, b map[string]any) int {
+	va, vb := sortValue(s.AttributePath, a), sortValue(s.AttributePath, b)
+	switch {
+	case va == nil && vb == nil:
+		return 0
+	case va == nil:
+		return 1
+	case vb == nil:
+		return -1
+	}
+	c := compareValues(va, vb, s.CaseExact)
+	if strings.EqualFold(string(s.Order), string(Descending)) {
+		return -c
+	}
+	return c
+}
+
+// Sort sorts the given resources in place. The sort is stable.
+func (s SortSpec) Sort(resources []map[string]any) {
+	slices.SortStableFunc(resources, s.Compare)
+}
+
+func (s SortSpec) String() string {
+	order := s.Order
+	if order == "" {
+		order = Ascending
+	}
+	return fmt.Sprintf("%s %s", s.AttributePath, order)
+}
@@ -0,0 +1,98 @@
+package filter
+
+import (
+	"encoding/json"
+	"fmt"
+	"testing"
+)
+
+func ExampleParseSortSpec() {
+	fmt.Println(ParseSortSpec([]byte("name.familyName"), nil))
+	fmt.Println(ParseSortSpec([]byte("meta.lastModified"), []byte("Descending")))
+	// Output:
+	// name.familyName ascending <nil>
+	// meta.lastModified descending <nil>
+}
+
+func ExampleSortSpec_Sort() {
+	var resources []map[string]any
+	_ = json.Unmarshal([]byte(`[
+		{"userName": "bjensen", "emails": [{"value": "z@example.com"}, {"value": "b@example.com", "primary": true}]},
+		{"userName": "jsmith", "emails": [{"value": "a@example.com"}, {"value": "c@example.com"}]},
+		{"userName": "mdoe"}
+	]`), &resources)
+
+	sort, _ := ParseSortSpec([]byte("emails"), nil)
+	sort.Sort(resources)
+	for _, resource := range resources {
+		fmt.Println(resource["userName"])
+	}
+	// Output:
+	// jsmith
+	// bjensen
+	// mdoe
+}
+
+func TestParseSortSpec_invalid(t *testing.T) {
+	for _, test := range []struct {
+		sortBy, sortOrder string
+	}{
+		{sortBy: ""},
+		{sortBy: "name."},
+		{sortBy: "userName", sortOrder: "asc"},
+	} {
+		if _, err := ParseSortSpec([]byte(test.sortBy), []byte(test.sortOrder)); err == nil {
+			t.Errorf("expected an error for %q %q", test.sortBy, test.sortOrder)
+		}
+	}
+}
+
+func TestSortSpec_Compare(t *testing.T) {
+	for _, test := range []struct {
+		sortBy    string
+		order     SortOrder
+		caseExact bool
+		a, b      string
+		expected  int
+	}{
+		{sortBy: "userName", a: `{"userName": "a"}`, b: `{"userName": "B"}`, expected: -1},
+		{sortBy: "userName", caseExact: true, a: `{"userName": "a"}`, b: `{"userName": "B"}`, expected: 1},
+		{sortBy: "USERNAME", a: `{"userName": "Alice"}`, b: `{"username": "alice"}`, expected: 0},
+		{sortBy: "userName", order: Descending, a: `{"userName": "a"}`, b: `{"userName": "b"}`, expected: 1},
+		{sortBy: "userName", order: Descending, a: `{}`, b: `{"userName": "b"}`, expected: 1},
+		{sortBy: "userName", a: `{"userName": ""}`, b: `{"userName": "b"}`, expected: 1},
+		{sortBy: "userName", a: `{}`, b: `{}`, expected: 0},
+		{sortBy: "name.familyName", a: `{"name": {"familyName": "Smith"}}`, b: `{"name": {"familyName": "Jensen"}}`, expected: 1},
+		{sortBy: "name.familyName", a: `{"name": "Smith"}`, b: `{"name": {"familyName": "Jensen"}}`, expected: 1},
+		{sortBy: "age", a: `{"age": 9}`, b: `{"age": 10}`, expected: -1},
+		{sortBy: "active", a: `{"active": true}`, b: `{"active": false}`, expected: 1},
+		{sortBy: "value", a: `{"value": "1"}`, b: `{"value": 2}`, expected: 1},
+		{sortBy: "meta.created", a: `{"meta": {"created": "2011-05-13T05:42:34+01:00"}}`, b: `{"meta": {"created": "2011-05-13T04:42:35Z"}}`, expected: -1},
+		{sortBy: "emails.type", a: `{"emails": [{"type": "work"}, {"type": "home", "primary": true}]}`, b: `{"emails": [{"type": "other"}]}`, expected: -1},
+		{sortBy: "roles", a: `{"roles": ["b", "a"]}`, b: `{"roles": ["a"]}`, expected: 1},
+		{
+			sortBy:   "urn:ietf:params:scim:schemas:extension:enterprise:2.0:User:employeeNumber",
+			a:        `{"urn:ietf:params:scim:schemas:extension:enterprise:2.0:User": {"employeeNumber": 701984}}`,
+			b:        `{"urn:ietf:params:scim:schemas:extension:enterprise:2.0:User": {"employeeNumber": 701985}}`,
+			expected: -1,
+		},
+	} {
+		t.Run(fmt.Sprintf("%s %s %s", test.sortBy, test.a, test.b), func(t *testing.T) {
+			sort, err := ParseSortSpec([]byte(test.sortBy), []byte(test.order))
+			if err != nil {
+				t.Fatal(err)
+			}
+			sort.CaseExact = test.caseExact
+			var a, b map[string]any
+			if err := json.Unmarshal([]byte(test.a), &a); err != nil {
+				t.Fatal(err)
+			}
+			if err := json.Unmarshal([]byte(test.b), &b); err != nil {
+				t.Fatal(err)
+			}
+			if c := sort.Compare(a, b); c != test.expected {
+				t.Errorf("expected %d, got %d", test.expected, c)
+			}
+		})
+	}
+}
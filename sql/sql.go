@@ -0,0 +1,290 @@
+// Package sql translates filter expressions to parameterized PostgreSQL WHERE
+// clauses.
+package sql
+
+import (
+	"fmt"
+	filter "github.com/scim2/filter-parser/v2"
+	"math/big"
+	"strings"
+)
+
+// Translate translates the given expression to a PostgreSQL WHERE clause (without
+// the WHERE keyword) and its bind arguments. Arguments are referenced with
+// positional parameters, starting at $1.
+//
+// Attribute paths are resolved with the given mapping. String comparisons are
+// case-insensitive unless the column is marked as case exact. An attribute
+// expression that is negated matches rows where the column is NULL, like the
+// 'ne' operator does. Sub-attributes of child tables match like Evaluate does,
+// e.g. emails.value ne "..." matches if none of the rows are equal.
+func Translate(expr filter.Expression, mapping Mapping) (string, []any, error) {
+	t := translator{
+		columns: normalize(mapping.Columns),
+		tables:  make(map[string]table),
+	}
+	for k, v := range mapping.Tables {
+		t.tables[key(k)] = table{
+			Table:   v,
+			columns: normalize(v.Columns),
+		}
+	}
+	where, err := t.translate(expr, t.columns)
+	if err != nil {
+		return "", nil, err
+	}
+	return where, t.args, nil
+}
+
+// escape escapes the wildcards of the LIKE operator.
+func escape(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(s)
+}
+
+// key returns the normalized key of the given attribute path.
+func key(path string) string {
+	return strings.ToLower(path)
+}
+
+// lookup returns the column that is mapped to the given attribute path. An
+// attribute path with a URI prefix falls back to the column of the path
+// without the prefix, so that attributes of the core schema only need to be
+// mapped once.
+func lookup(columns map[string]Column, path filter.AttributePath) (Column, bool) {
+	if column, ok := columns[key(path.String())]; ok {
+		return column, true
+	}
+	if path.URIPrefix != nil {
+		path.URIPrefix = nil
+		column, ok := columns[key(path.String())]
+		return column, ok
+	}
+	return Column{}, false
+}
+
+func normalize(columns map[string]Column) map[string]Column {
+	normalized := make(map[string]Column, len(columns))
+	for k, v := range columns {
+		normalized[key(k)] = v
+	}
+	return normalized
+}
+
+// Column describes the column in which the value of an attribute is stored.
+type Column struct {
+	// Name is the (qualified) name of the column, e.g. "users.user_name".
+	Name string
+	// CaseExact indicates that values are compared case-sensitively. This
+	// should be set for all columns that are not of a text type (e.g.
+	// timestamps), since these can not be lowercased.
+	CaseExact bool
+}
+
+// Mapping maps attribute paths to columns and tables. The keys are attribute
+// paths as accepted by filter.ParseAttrPath (e.g. "name.familyName") and are
+// matched case-insensitively.
+type Mapping struct {
+	// Columns maps attribute paths to columns.
+	Columns map[string]Column
+	// Tables maps multi-valued attributes (e.g. "emails") to the child tables
+	// in which their values are stored.
+	Tables map[string]Table
+}
+
+// Table describes a child table that contains the values of a multi-valued
+// attribute.
+type Table struct {
+	// Name is the name of the table, optionally followed by an alias.
+	Name string
+	// Condition joins the child table to the parent, e.g.
+	// "emails.user_id = users.id".
+	Condition string
+	// Columns maps the sub-attributes (e.g. "value") to columns.
+	Columns map[string]Column
+}
+
+type table struct {
+	Table
+	columns map[string]Column
+}
+
+type translator struct {
+	columns map[string]Column
+	tables  map[string]table
+	args    []any
+}
+
+// arg adds the given value to the arguments and returns its parameter.
+func (t *translator) arg(value any) string {
+	// Big numbers are not supported by database/sql, they are passed as their
+	// decimal representation instead.
+	switch v := value.(type) {
+	case *big.Int:
+		value = v.String()
+	case *big.Rat:
+		prec, _ := v.FloatPrec()
+		value = v.FloatString(prec)
+	}
+	t.args = append(t.args, value)
+	return fmt.Sprintf("$%d", len(t.args))
+}
+
+// exists translates the given value filter to a sub query on the child table
+// of the given attribute path.
+func (t *translator) exists(path filter.AttributePath, valueFilter filter.Expression) (string, error) {
+	child, ok := t.tables[key(path.String())]
+	if !ok && path.URIPrefix != nil {
+		path.URIPrefix = nil
+		child, ok = t.tables[key(path.String())]
+	}
+	if !ok {
+		return "", fmt.Errorf("no table mapped for attribute %q", path)
+	}
+	where, err := t.translate(valueFilter, child.columns)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf(
+		"EXISTS (SELECT 1 FROM %s WHERE %s AND %s)",
+		child.Name, child.Condition, where,
+	), nil
+}
+
+func (t *translator) translate(expr filter.Expression, columns map[string]Column) (string, error) {
+	switch e := expr.(type) {
+	case *filter.AttributeExpression:
+		column, ok := lookup(columns, e.AttributePath)
+		if !ok && e.AttributePath.SubAttribute != nil {
+			// e.g. emails.value eq "..." => emails[value eq "..."]
+			path := e.AttributePath
+			path.SubAttribute = nil
+			// The 'ne' operator is the negation of 'eq', so that it matches if
+			// none of the values are equal: emails.value ne "..." =>
+			// NOT emails[value eq "..."]. The same goes for 'eq null', which
+			// matches if none of the values are present.
+			op := filter.CompareOperator(strings.ToLower(string(e.Operator)))
+			var negate bool
+			switch {
+			case op == filter.NE && e.CompareValue != nil:
+				op, negate = filter.EQ, true
+			case op == filter.EQ && e.CompareValue == nil:
+				op, negate = filter.NE, true
+			}
+			where, err := t.exists(path, &filter.AttributeExpression{
+				AttributePath: filter.AttributePath{
+					AttributeName: e.AttributePath.SubAttributeName(),
+				},
+				Operator:     op,
+				CompareValue: e.CompareValue,
+			})
+			if err != nil || !negate {
+				return where, err
+			}
+			return "NOT " + where, nil
+		}
+		if !ok {
+			return "", fmt.Errorf("no column mapped for attribute %q", e.AttributePath)
+		}
+		return t.translateAttrExp(column, e)
+	case *filter.LogicalExpression:
+		op := strings.ToUpper(string(e.Operator))
+		if op != "AND" && op != "OR" {
+			return "", fmt.Errorf("invalid logical operator: %q", e.Operator)
+		}
+		left, err := t.translate(e.Left, columns)
+		if err != nil {
+			return "", err
+		}
+		right, err := t.translate(e.Right, columns)
+		if err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("(%s %s %s)", left, op, right), nil
+	case *filter.NotExpression:
+		where, err := t.translate(e.Expression, columns)
+		if err != nil {
+			return "", err
+		}
+		// NOT NULL is NULL, while unassigned attributes do match negated
+		// expressions.
+		return fmt.Sprintf("(%s) IS NOT TRUE", where), nil
+	case *filter.ValuePath:
+		return t.exists(e.AttributePath, e.ValueFilter)
+	default:
+		return "", fmt.Errorf("invalid expression type: %T", expr)
+	}
+}
+
+func (t *translator) translateAttrExp(column Column, e *filter.AttributeExpression) (string, error) {
+	op := filter.CompareOperator(strings.ToLower(string(e.Operator)))
+	if op == filter.PR {
+		return fmt.Sprintf("%s IS NOT NULL", column.Name), nil
+	}
+
+	value, isString := e.CompareValue.(string)
+	if _, ok := e.CompareValue.(bool); ok {
+		switch op {
+		case filter.EQ, filter.NE:
+		default:
+			return "", fmt.Errorf("operator %q is not supported on boolean values", op)
+		}
+	}
+
+	var (
+		name  = column.Name
+		param string
+	)
+	switch op {
+	case filter.CO, filter.SW, filter.EW:
+		if !isString {
+			return "", fmt.Errorf("operator %q is only supported on strings", op)
+		}
+		like := "ILIKE"
+		if column.CaseExact {
+			like = "LIKE"
+		}
+		pattern := escape(value)
+		switch op {
+		case filter.CO:
+			pattern = "%" + pattern + "%"
+		case filter.SW:
+			pattern = pattern + "%"
+		case filter.EW:
+			pattern = "%" + pattern
+		}
+		return fmt.Sprintf("%s %s %s", name, like, t.arg(pattern)), nil
+	case filter.EQ, filter.NE:
+		if e.CompareValue == nil {
+			if op == filter.EQ {
+				return fmt.Sprintf("%s IS NULL", name), nil
+			}
+			return fmt.Sprintf("%s IS NOT NULL", name), nil
+		}
+	case filter.GT, filter.GE, filter.LT, filter.LE:
+		if e.CompareValue == nil {
+			return "", fmt.Errorf("operator %q is not supported on null", op)
+		}
+	default:
+		return "", fmt.Errorf("invalid compare operator: %q", op)
+	}
+
+	param = t.arg(e.CompareValue)
+	if isString && !column.CaseExact {
+		name = fmt.Sprintf("lower(%s)", name)
+		param = fmt.Sprintf("lower(%s)", param)
+	}
+	switch op {
+	case filter.EQ:
+		return fmt.Sprintf("%s = %s", name, param), nil
+	case filter.NE:
+		return fmt.Sprintf("%s IS DISTINCT FROM %s", name, param), nil
+	case filter.GT:
+		return fmt.Sprintf("%s > %s", name, param), nil
+	case filter.GE:
+		return fmt.Sprintf("%s >= %s", name, param), nil
+	case filter.LT:
+		return fmt.Sprintf("%s < %s", name, param), nil
+	default:
+		return fmt.Sprintf("%s <= %s", name, param), nil
+	}
+}
@@ -0,0 +1,499 @@
+package sql
+
+import (
+	"fmt"
+	filter "github.com/scim2/filter-parser/v2"
+	"reflect"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+var mapping = Mapping{
+	Columns: map[string]Column{
+		"id":                {Name: "users.id", CaseExact: true},
+		"userName":          {Name: "users.user_name"},
+		"name.familyName":   {Name: "users.family_name"},
+		"active":            {Name: "users.active", CaseExact: true},
+		"meta.lastModified": {Name: "users.last_modified", CaseExact: true},
+		"urn:ietf:params:scim:schemas:extension:enterprise:2.0:User:employeeNumber": {Name: "users.employee_number"},
+	},
+	Tables: map[string]Table{
+		"emails": {
+			Name:      "user_emails",
+			Condition: "user_emails.user_id = users.id",
+			Columns: map[string]Column{
+				"value":   {Name: "user_emails.value"},
+				"type":    {Name: "user_emails.type"},
+				"primary": {Name: "user_emails.is_primary", CaseExact: true},
+			},
+		},
+	},
+}
+
+func ExampleTranslate() {
+	expression, _ := filter.ParseFilter([]byte("userName sw \"j\" and emails[type eq \"work\" and value co \"100%\"]"))
+	fmt.Println(Translate(expression, Mapping{
+		Columns: map[string]Column{
+			"userName": {Name: "user_name"},
+		},
+		Tables: map[string]Table{
+			"emails": {
+				Name:      "emails",
+				Condition: "emails.user_id = users.id",
+				Columns: map[string]Column{
+					"type":  {Name: "emails.type"},
+					"value": {Name: "emails.value"},
+				},
+			},
+		},
+	}))
+	// Output:
+	// (user_name ILIKE $1 AND EXISTS (SELECT 1 FROM emails WHERE emails.user_id = users.id AND (lower(emails.type) = lower($2) AND emails.value ILIKE $3))) [j% work %100\%%] <nil>
+}
+
+func TestTranslate(t *testing.T) {
+	for _, test := range []struct {
+		filter string
+		where  string
+		args   []any
+	}{
+		{
+			filter: "userName eq \"bjensen\"",
+			where:  "lower(users.user_name) = lower($1)",
+			args:   []any{"bjensen"},
+		},
+		{
+			filter: "USERNAME NE \"bjensen\"",
+			where:  "lower(users.user_name) IS DISTINCT FROM lower($1)",
+			args:   []any{"bjensen"},
+		},
+		{
+			filter: "id eq \"2819c223\"",
+			where:  "users.id = $1",
+			args:   []any{"2819c223"},
+		},
+		{
+			filter: "name.familyName co \"O'Mal_ley\\\\\"",
+			where:  "users.family_name ILIKE $1",
+			args:   []any{`%O'Mal\_ley\\%`},
+		},
+		{
+			filter: "userName ew \"sen\"",
+			where:  "users.user_name ILIKE $1",
+			args:   []any{"%sen"},
+		},
+		{
+			filter: "id sw \"28\"",
+			where:  "users.id LIKE $1",
+			args:   []any{"28%"},
+		},
+		{
+			filter: "userName pr",
+			where:  "users.user_name IS NOT NULL",
+		},
+		{
+			filter: "userName eq null",
+			where:  "users.user_name IS NULL",
+		},
+		{
+			filter: "active eq true",
+			where:  "users.active = $1",
+			args:   []any{true},
+		},
+		{
+			filter: "meta.lastModified gt \"2011-05-13T04:42:34Z\"",
+			where:  "users.last_modified > $1",
+			args:   []any{"2011-05-13T04:42:34Z"},
+		},
+		{
+			filter: "meta.lastModified le \"2011-05-13T04:42:34Z\"",
+			where:  "users.last_modified <= $1",
+			args:   []any{"2011-05-13T04:42:34Z"},
+		},
+		{
+			filter: "urn:ietf:params:scim:schemas:extension:enterprise:2.0:User:employeeNumber ge \"100\"",
+			where:  "lower(users.employee_number) >= lower($1)",
+			args:   []any{"100"},
+		},
+		{
+			filter: "urn:ietf:params:scim:schemas:core:2.0:User:userName eq \"bjensen\"",
+			where:  "lower(users.user_name) = lower($1)",
+			args:   []any{"bjensen"},
+		},
+		{
+			filter: "userName eq \"a\" or userName eq \"b\" and not (active eq false)",
+			where:  "(lower(users.user_name) = lower($1) OR (lower(users.user_name) = lower($2) AND (users.active = $3) IS NOT TRUE))",
+			args:   []any{"a", "b", false},
+		},
+		{
+			filter: "emails[type eq \"work\" and primary eq true]",
+			where:  "EXISTS (SELECT 1 FROM user_emails WHERE user_emails.user_id = users.id AND (lower(user_emails.type) = lower($1) AND user_emails.is_primary = $2))",
+			args:   []any{"work", true},
+		},
+		{
+			filter: "meta.lastModified gt 92233720368547758070",
+			where:  "users.last_modified > $1",
+			args:   []any{"92233720368547758070"},
+		},
+		{
+			filter: "emails.value ew \"@example.com\"",
+			where:  "EXISTS (SELECT 1 FROM user_emails WHERE user_emails.user_id = users.id AND user_emails.value ILIKE $1)",
+			args:   []any{"%@example.com"},
+		},
+		{
+			filter: "emails.value ne \"bjensen@example.com\"",
+			where:  "NOT EXISTS (SELECT 1 FROM user_emails WHERE user_emails.user_id = users.id AND lower(user_emails.value) = lower($1))",
+			args:   []any{"bjensen@example.com"},
+		},
+		{
+			filter: "emails.value eq null",
+			where:  "NOT EXISTS (SELECT 1 FROM user_emails WHERE user_emails.user_id = users.id AND user_emails.value IS NOT NULL)",
+		},
+		{
+			filter: "emails.value ne null",
+			where:  "EXISTS (SELECT 1 FROM user_emails WHERE user_emails.user_id = users.id AND user_emails.value IS NOT NULL)",
+		},
+	} {
+		t.Run(test.filter, func(t *testing.T) {
+			expression, err := filter.ParseFilter([]byte(test.filter))
+			if err != nil {
+				t.Fatal(err)
+			}
+			where, args, err := Translate(expression, mapping)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if where != test.where {
+				t.Errorf("expected %s, got %s", test.where, where)
+			}
+			if !reflect.DeepEqual(args, test.args) {
+				t.Errorf("expected %v, got %v", test.args, args)
+			}
+		})
+	}
+}
+
+func TestTranslate_invalid(t *testing.T) {
+	for _, example := range []string{
+		"title pr",
+		"ims[type eq \"xmpp\"]",
+		"emails[display pr]",
+		"active gt true",
+		"userName gt null",
+		"active co 1",
+	} {
+		t.Run(example, func(t *testing.T) {
+			expression, err := filter.ParseFilter([]byte(example))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, _, err := Translate(expression, mapping); err == nil {
+				t.Error("expected an error")
+			}
+		})
+	}
+}
+
+// TestTranslate_evaluate checks that the translated WHERE clauses select the
+// same resources as Evaluate, by evaluating them against an in-memory
+// database.
+func TestTranslate_evaluate(t *testing.T) {
+	resources := []map[string]any{
+		{"userName": "bjensen", "active": true, "emails": []any{
+			map[string]any{"value": "a@x", "type": "work", "primary": true},
+			map[string]any{"value": "b@y", "type": "home"},
+		}},
+		{"userName": "BJensen", "emails": []any{
+			map[string]any{"value": "A@X", "type": "work"},
+		}},
+		{},
+		{"userName": "x", "active": false, "emails": []any{
+			map[string]any{"type": "work"},
+		}},
+		{"emails": []any{
+			map[string]any{"value": "b@y", "type": "home", "primary": false},
+		}},
+		{"emails": []any{}},
+	}
+	tables := map[string][]testRow{
+		"user_emails": nil,
+	}
+	for i, resource := range resources {
+		tables["users"] = append(tables["users"], testRow{
+			"users.id":        i,
+			"users.user_name": resource["userName"],
+			"users.active":    resource["active"],
+		})
+		emails, _ := resource["emails"].([]any)
+		for _, email := range emails {
+			email := email.(map[string]any)
+			tables["user_emails"] = append(tables["user_emails"], testRow{
+				"user_emails.user_id":    i,
+				"user_emails.value":      email["value"],
+				"user_emails.type":       email["type"],
+				"user_emails.is_primary": email["primary"],
+			})
+		}
+	}
+
+	for _, raw := range []string{
+		`userName eq "bjensen"`,
+		`userName ne "bjensen"`,
+		`emails.value eq "a@x"`,
+		`emails.value ne "a@x"`,
+		`emails.value eq null`,
+		`emails.value ne null`,
+		`emails.value pr`,
+		`emails.value co "@"`,
+		`emails.primary ne true`,
+		`not (emails.value ne "a@x")`,
+		`emails[value ne "a@x"]`,
+		`emails[type eq "work" and value ne "a@x"]`,
+		`not (userName eq "bjensen") and emails.type ne "work"`,
+		`active ne true or emails.value eq null`,
+	} {
+		t.Run(raw, func(t *testing.T) {
+			expression, err := filter.ParseFilter([]byte(raw))
+			if err != nil {
+				t.Fatal(err)
+			}
+			where, args, err := Translate(expression, mapping)
+			if err != nil {
+				t.Fatal(err)
+			}
+			p := whereParser{t: t, tokens: tokenize(where), args: args, tables: tables}
+			condition := p.or()
+			if p.pos != len(p.tokens) {
+				t.Fatalf("unexpected token %q in %s", p.tokens[p.pos], where)
+			}
+			for i, resource := range resources {
+				expected, err := filter.Evaluate(expression, resource)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if match := condition([]testRow{tables["users"][i]}) == true; match != expected {
+					t.Errorf("%s: expected %v, got %v for %v", where, expected, match, resource)
+				}
+			}
+		})
+	}
+}
+
+// tokenize splits the given WHERE clause into tokens.
+func tokenize(where string) []string {
+	var tokens []string
+	for _, field := range strings.Fields(where) {
+		for field != "" {
+			i := strings.IndexAny(field, "()")
+			switch {
+			case i == -1:
+				tokens, field = append(tokens, field), ""
+			case i == 0:
+				tokens, field = append(tokens, field[:1]), field[1:]
+			default:
+				tokens, field = append(tokens, field[:i]), field[i:]
+			}
+		}
+	}
+	return tokens
+}
+
+// testRow is a row of the in-memory database, by qualified column name.
+type testRow map[string]any
+
+// sqlExpr is an expression of a WHERE clause. It is evaluated with the rows
+// that are in scope. Conditions result in true, false or nil (NULL).
+type sqlExpr func(rows []testRow) any
+
+// whereParser parses the subset of SQL that Translate generates.
+type whereParser struct {
+	t      *testing.T
+	tokens []string
+	pos    int
+	args   []any
+	tables map[string][]testRow
+}
+
+func (p *whereParser) and() sqlExpr {
+	left := p.not()
+	for p.accept("AND") {
+		l, r := left, p.not()
+		left = func(rows []testRow) any {
+			a, b := l(rows), r(rows)
+			if a == false || b == false {
+				return false
+			}
+			if a == nil || b == nil {
+				return nil
+			}
+			return true
+		}
+	}
+	return left
+}
+
+func (p *whereParser) accept(tokens ...string) bool {
+	for i, token := range tokens {
+		if p.pos+i >= len(p.tokens) || p.tokens[p.pos+i] != token {
+			return false
+		}
+	}
+	p.pos += len(tokens)
+	return true
+}
+
+func (p *whereParser) comparison() sqlExpr {
+	left := p.operand()
+	switch {
+	case p.accept("IS", "NOT", "NULL"):
+		return func(rows []testRow) any { return left(rows) != nil }
+	case p.accept("IS", "NULL"):
+		return func(rows []testRow) any { return left(rows) == nil }
+	case p.accept("IS", "DISTINCT", "FROM"):
+		right := p.operand()
+		return func(rows []testRow) any { return left(rows) != right(rows) }
+	case p.accept("="):
+		right := p.operand()
+		return func(rows []testRow) any {
+			a, b := left(rows), right(rows)
+			if a == nil || b == nil {
+				return nil
+			}
+			return a == b
+		}
+	case p.accept("LIKE"), p.accept("ILIKE"):
+		flags := "(?s)"
+		if p.tokens[p.pos-1] == "ILIKE" {
+			flags = "(?is)"
+		}
+		right := p.operand()
+		return func(rows []testRow) any {
+			a, b := left(rows), right(rows)
+			if a == nil || b == nil {
+				return nil
+			}
+			var pattern strings.Builder
+			escaped := false
+			for _, r := range b.(string) {
+				switch {
+				case escaped:
+					pattern.WriteString(regexp.QuoteMeta(string(r)))
+					escaped = false
+				case r == '\\':
+					escaped = true
+				case r == '%':
+					pattern.WriteString(".*")
+				case r == '_':
+					pattern.WriteString(".")
+				default:
+					pattern.WriteString(regexp.QuoteMeta(string(r)))
+				}
+			}
+			return regexp.MustCompile(flags + "^" + pattern.String() + "$").MatchString(a.(string))
+		}
+	default:
+		p.t.Fatalf("unsupported comparison at token %d of %v", p.pos, p.tokens)
+		return nil
+	}
+}
+
+func (p *whereParser) expect(tokens ...string) {
+	if !p.accept(tokens...) {
+		p.t.Fatalf("expected %v at token %d of %v", tokens, p.pos, p.tokens)
+	}
+}
+
+func (p *whereParser) not() sqlExpr {
+	if p.accept("NOT") {
+		x := p.not()
+		return func(rows []testRow) any {
+			if v := x(rows); v != nil {
+				return !v.(bool)
+			}
+			return nil
+		}
+	}
+	return p.primary()
+}
+
+func (p *whereParser) operand() sqlExpr {
+	switch token := p.tokens[p.pos]; {
+	case p.accept("lower", "("):
+		x := p.operand()
+		p.expect(")")
+		return func(rows []testRow) any {
+			if s, ok := x(rows).(string); ok {
+				return strings.ToLower(s)
+			}
+			return nil
+		}
+	case strings.HasPrefix(token, "$"):
+		p.pos++
+		i, err := strconv.Atoi(token[1:])
+		if err != nil {
+			p.t.Fatal(err)
+		}
+		arg := p.args[i-1]
+		return func([]testRow) any { return arg }
+	default:
+		p.pos++
+		return func(rows []testRow) any {
+			for _, row := range rows {
+				if v, ok := row[token]; ok {
+					return v
+				}
+			}
+			p.t.Fatalf("unknown column %q", token)
+			return nil
+		}
+	}
+}
+
+func (p *whereParser) or() sqlExpr {
+	left := p.and()
+	for p.accept("OR") {
+		l, r := left, p.and()
+		left = func(rows []testRow) any {
+			a, b := l(rows), r(rows)
+			if a == true || b == true {
+				return true
+			}
+			if a == nil || b == nil {
+				return nil
+			}
+			return false
+		}
+	}
+	return left
+}
+
+func (p *whereParser) primary() sqlExpr {
+	switch {
+	case p.accept("EXISTS", "(", "SELECT", "1", "FROM"):
+		table := p.tables[p.tokens[p.pos]]
+		p.pos++
+		p.expect("WHERE")
+		x := p.or()
+		p.expect(")")
+		return func(rows []testRow) any {
+			for _, row := range table {
+				if x(append(slices.Clip(rows), row)) == true {
+					return true
+				}
+			}
+			return false
+		}
+	case p.accept("("):
+		x := p.or()
+		p.expect(")")
+		if p.accept("IS", "NOT", "TRUE") {
+			return func(rows []testRow) any { return x(rows) != true }
+		}
+		return x
+	default:
+		return p.comparison()
+	}
+}
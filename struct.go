@@ -0,0 +1,209 @@
+package filter
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"reflect"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// structTypes caches the attributes of struct types, by reflect.Type.
+var structTypes sync.Map
+
+// EvaluateStruct reports whether the given resource, a struct or a pointer to a
+// struct, matches the expression. It has the same semantics as Evaluate.
+//
+// Attribute names are resolved to the exported fields of the struct by the
+// name in their "scim" tag, e.g. `scim:"userName"`, or by the name of the
+// field if it has no tag. Names are matched case-insensitively. Fields tagged
+// with "-" are ignored and the fields of embedded structs without a tag are
+// promoted. The extension of an attribute path with a URI prefix is the field
+// tagged with that URI, e.g.
+//
+//	Enterprise *EnterpriseUser `scim:"urn:ietf:params:scim:schemas:extension:enterprise:2.0:User"`
+//
+// Complex attributes are structs or maps with string keys, multi-valued
+// attributes are slices or arrays. Pointers and interfaces are dereferenced.
+// Nil values and zero time.Time values are unassigned, other time.Time values
+// are compared as RFC 3339 timestamps.
+//
+// The attributes of a struct type are resolved once and cached.
+func EvaluateStruct(expr Expression, resource any) (bool, error) {
+	rv := reflect.ValueOf(resource)
+	for rv.Kind() == reflect.Pointer && !rv.IsNil() {
+		rv = rv.Elem()
+	}
+	if rv.Kind() != reflect.Struct {
+		return false, fmt.Errorf("invalid resource type: %T", resource)
+	}
+	return evaluate(expr, rv.Interface())
+}
+
+// normalize converts the given field value to a value as it would be decoded
+// from JSON, so that it can be compared like the values of a map. Structs are
+// returned as is.
+func normalize(rv reflect.Value) any {
+	switch rv.Type() {
+	case reflect.TypeFor[time.Time]():
+		t := rv.Interface().(time.Time)
+		if t.IsZero() {
+			return nil
+		}
+		return t.Format(time.RFC3339Nano)
+	case reflect.TypeFor[json.Number](), reflect.TypeFor[*big.Int](), reflect.TypeFor[*big.Rat]():
+		if rv.Kind() == reflect.Pointer && rv.IsNil() {
+			return nil
+		}
+		return rv.Interface()
+	case reflect.TypeFor[map[string]any]():
+		return rv.Interface()
+	}
+
+	switch rv.Kind() {
+	case reflect.Pointer, reflect.Interface:
+		if rv.IsNil() {
+			return nil
+		}
+		return normalize(rv.Elem())
+	case reflect.Bool:
+		return rv.Bool()
+	case reflect.String:
+		return rv.String()
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return rv.Int()
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return rv.Uint()
+	case reflect.Float32, reflect.Float64:
+		return rv.Float()
+	case reflect.Slice, reflect.Array:
+		if rv.Kind() == reflect.Slice && rv.IsNil() {
+			return nil
+		}
+		if rv.Type().Elem().Kind() == reflect.Uint8 {
+			if rv.Kind() == reflect.Array {
+				// Bytes panics on arrays that are not addressable.
+				b := make([]byte, rv.Len())
+				reflect.Copy(reflect.ValueOf(b), rv)
+				return b
+			}
+			return rv.Bytes()
+		}
+		values := make([]any, rv.Len())
+		for i := range values {
+			values[i] = normalize(rv.Index(i))
+		}
+		return values
+	case reflect.Map:
+		if rv.Type().Key().Kind() != reflect.String || rv.IsNil() {
+			return nil
+		}
+		values := make(map[string]any, rv.Len())
+		for iter := rv.MapRange(); iter.Next(); {
+			values[iter.Key().String()] = normalize(iter.Value())
+		}
+		return values
+	case reflect.Struct:
+		return rv.Interface()
+	default:
+		return nil
+	}
+}
+
+// typeOf returns the (cached) attributes of the given struct type.
+func typeOf(t reflect.Type) *structType {
+	if st, ok := structTypes.Load(t); ok {
+		return st.(*structType)
+	}
+	st := &structType{
+		index: make(map[string]int),
+	}
+	st.add(t, nil)
+	actual, _ := structTypes.LoadOrStore(t, st)
+	return actual.(*structType)
+}
+
+// structField is a field of a struct type that holds an attribute.
+type structField struct {
+	// name is the name of the attribute.
+	name string
+	// index is the index sequence of the field, for reflect.Value.FieldByIndex.
+	index []int
+}
+
+// value returns the normalized value of the field within the given struct.
+func (f structField) value(rv reflect.Value) any {
+	v, err := rv.FieldByIndexErr(f.index)
+	if err != nil {
+		// The field is promoted through a nil embedded pointer.
+		return nil
+	}
+	return normalize(v)
+}
+
+// structType holds the attributes of a struct type.
+type structType struct {
+	fields []structField
+	// index maps lowercased attribute names to fields.
+	index map[string]int
+}
+
+// add adds the fields of the given struct type. Fields of the struct itself take
+// precedence over the fields of embedded structs.
+func (st *structType) add(t reflect.Type, index []int) {
+	var embedded []reflect.StructField
+	for i := range t.NumField() {
+		f := t.Field(i)
+		tag, _ := f.Tag.Lookup("scim")
+		name, _, _ := strings.Cut(tag, ",")
+		if name == "-" {
+			continue
+		}
+		f.Index = append(slices.Clone(index), i)
+		if f.Anonymous && name == "" {
+			ft := f.Type
+			if ft.Kind() == reflect.Pointer {
+				ft = ft.Elem()
+			}
+			if ft.Kind() == reflect.Struct {
+				embedded = append(embedded, f)
+				continue
+			}
+		}
+		if !f.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		key := strings.ToLower(name)
+		if _, ok := st.index[key]; ok {
+			continue
+		}
+		st.index[key] = len(st.fields)
+		st.fields = append(st.fields, structField{
+			name:  name,
+			index: f.Index,
+		})
+	}
+	for _, f := range embedded {
+		ft := f.Type
+		if ft.Kind() == reflect.Pointer {
+			ft = ft.Elem()
+		}
+		st.add(ft, f.Index)
+	}
+}
+
+// get returns the normalized value of the given attribute name within the given
+// struct, or nil if the struct has no such attribute.
+func (st *structType) get(rv reflect.Value, name string) any {
+	i, ok := st.index[strings.ToLower(name)]
+	if !ok {
+		return nil
+	}
+	return st.fields[i].value(rv)
+}
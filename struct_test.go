@@ -0,0 +1,236 @@
+package filter
+
+import (
+	"fmt"
+	"slices"
+	"testing"
+	"time"
+)
+
+// testStructUser is the struct equivalent of testUser.
+var testStructUser = structUser{
+	structResource: structResource{
+		Schemas: []string{
+			"urn:ietf:params:scim:schemas:core:2.0:User",
+			"urn:ietf:params:scim:schemas:extension:enterprise:2.0:User",
+		},
+		ID: "2819c223-7f76-453a-919d-413861904646",
+		Meta: &structMeta{
+			LastModified: time.Date(2011, 5, 13, 4, 42, 34, 0, time.UTC),
+		},
+	},
+	UserName: "bjensen",
+	Name: structName{
+		FamilyName: "O'Malley",
+		GivenName:  "Barbara",
+	},
+	Title:    "Tour Guide",
+	UserType: "Employee",
+	Active:   true,
+	Emails: []structEmail{
+		{Value: "bjensen@example.com", Type: "work", Primary: true},
+		{Value: "babs@jensen.org", Type: "home"},
+	},
+	Enterprise: &structEnterpriseUser{
+		EmployeeNumber: "701984",
+		CostCenter:     4130,
+		Manager: &structManager{
+			Value: "26118915-6090-4610-87e4-49d8ca9f808d",
+		},
+	},
+	Password: "t1meMa$heen",
+}
+
+func ExampleEvaluateStruct() {
+	type Email struct {
+		Value string `scim:"value"`
+		Type  string `scim:"type"`
+	}
+	type User struct {
+		UserName string  `scim:"userName"`
+		Emails   []Email `scim:"emails"`
+	}
+	user := User{
+		UserName: "bjensen",
+		Emails:   []Email{{Value: "bjensen@example.com", Type: "work"}},
+	}
+
+	expression, _ := ParseFilter([]byte("userName eq \"BJensen\" and emails[type eq \"work\" and value ew \"@example.com\"]"))
+	fmt.Println(EvaluateStruct(expression, &user))
+	// Output:
+	// true <nil>
+}
+
+func TestEvaluateStruct(t *testing.T) {
+	for _, test := range slices.Concat(evaluateTests, []struct {
+		filter string
+		match  bool
+	}{
+		{"password pr", false},
+		{"resource pr", false},
+		{"meta pr", true},
+		{"emails[primary eq false]", true},
+		{"urn:ietf:params:scim:schemas:extension:enterprise:2.0:User:costCenter lt 4130.5", true},
+	}) {
+		t.Run(test.filter, func(t *testing.T) {
+			expression, err := ParseFilter([]byte(test.filter))
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, resource := range []any{testStructUser, &testStructUser} {
+				match, err := EvaluateStruct(expression, resource)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if match != test.match {
+					t.Errorf("expected %v, got %v", test.match, match)
+				}
+			}
+		})
+	}
+}
+
+func TestEvaluateStruct_unassigned(t *testing.T) {
+	var user structUser
+	for _, test := range []struct {
+		filter string
+		match  bool
+	}{
+		{"userName pr", false},
+		{"userName eq null", true},
+		{"name pr", false},
+		{"emails pr", false},
+		{"emails[type eq \"work\"]", false},
+		{"meta.lastModified pr", false},
+		{"meta.created pr", false},
+		{"urn:ietf:params:scim:schemas:extension:enterprise:2.0:User:manager.value pr", false},
+		{"active eq false", true},
+	} {
+		t.Run(test.filter, func(t *testing.T) {
+			expression, err := ParseFilter([]byte(test.filter))
+			if err != nil {
+				t.Fatal(err)
+			}
+			match, err := EvaluateStruct(expression, &user)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if match != test.match {
+				t.Errorf("expected %v, got %v", test.match, match)
+			}
+		})
+	}
+}
+
+func TestEvaluateStruct_invalid(t *testing.T) {
+	expression, err := ParseFilter([]byte("userName pr"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, resource := range []any{
+		nil,
+		"bjensen",
+		map[string]any{"userName": "bjensen"},
+		(*structUser)(nil),
+	} {
+		t.Run(fmt.Sprintf("%T", resource), func(t *testing.T) {
+			if _, err := EvaluateStruct(expression, resource); err == nil {
+				t.Error("expected an error")
+			}
+		})
+	}
+}
+
+func TestEvaluateStruct_types(t *testing.T) {
+	resource := structValues{
+		ID:     [16]byte{0x28, 0x19, 0xc2, 0x23},
+		Tags:   map[string]string{"a": "x"},
+		Counts: map[string]int{"a": 2},
+		Codes:  map[int]string{1: "x"},
+	}
+	for _, test := range []struct {
+		filter string
+		match  bool
+	}{
+		{"id pr", true},
+		{"userName eq \"x\" or id pr", true},
+		{"tags pr", true},
+		{"tags.a eq \"x\"", true},
+		{"tags.A eq \"X\"", true},
+		{"tags.b pr", false},
+		{"counts.a gt 1", true},
+		{"counts.a gt 2", false},
+		{"codes pr", false},
+		{"empty pr", false},
+	} {
+		t.Run(test.filter, func(t *testing.T) {
+			expression, err := ParseFilter([]byte(test.filter))
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, r := range []any{resource, &resource} {
+				match, err := EvaluateStruct(expression, r)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if match != test.match {
+					t.Errorf("expected %v, got %v", test.match, match)
+				}
+			}
+		})
+	}
+}
+
+type structEmail struct {
+	Value   string `scim:"value"`
+	Type    string `scim:"type"`
+	Primary bool   `scim:"primary"`
+}
+
+type structEnterpriseUser struct {
+	EmployeeNumber string         `scim:"employeeNumber"`
+	CostCenter     float64        `scim:"costCenter"`
+	Manager        *structManager `scim:"manager"`
+}
+
+type structManager struct {
+	Value string `scim:"value"`
+}
+
+type structMeta struct {
+	Created      *time.Time
+	LastModified time.Time `scim:"lastModified"`
+}
+
+type structName struct {
+	FamilyName string `scim:"familyName"`
+	GivenName  string `scim:"givenName"`
+	MiddleName string `scim:"middleName,omitempty"`
+}
+
+type structResource struct {
+	Schemas []string    `scim:"schemas"`
+	ID      string      `scim:"id"`
+	Meta    *structMeta `scim:"meta"`
+}
+
+type structUser struct {
+	structResource
+	UserName   string                `scim:"userName"`
+	Name       structName            `scim:"name"`
+	Title      string                `scim:"title"`
+	UserType   string                `scim:"userType"`
+	NickName   *string               `scim:"nickName"`
+	Active     bool                  `scim:"active"`
+	Emails     []structEmail         `scim:"emails"`
+	Enterprise *structEnterpriseUser `scim:"urn:ietf:params:scim:schemas:extension:enterprise:2.0:User"`
+	Password   string                `scim:"-"`
+}
+
+type structValues struct {
+	ID     [16]byte          `scim:"id"`
+	Tags   map[string]string `scim:"tags"`
+	Counts map[string]int    `scim:"counts"`
+	Codes  map[int]string    `scim:"codes"`
+	Empty  map[string]string `scim:"empty"`
+}
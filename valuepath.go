@@ -1,9 +1,6 @@
 package filter
 
 import (
-	"github.com/di-wu/parser"
-	"github.com/di-wu/parser/ast"
-	"github.com/scim2/filter-parser/v2/internal/grammar"
 	"github.com/scim2/filter-parser/v2/internal/types"
 )
 
@@ -13,100 +10,192 @@ func ParseValuePath(raw []byte) (ValuePath, error) {
 }
 
 // ParseValuePathNumber parses the given raw data as an ValuePath with json.Number.
+//
+// Deprecated: Use NewParser(UseNumber()).ParseValuePath instead.
 func ParseValuePathNumber(raw []byte) (ValuePath, error) {
 	return parseValuePath(raw, config{useNumber: true})
 }
 
 func parseValuePath(raw []byte, c config) (ValuePath, error) {
-	p, err := ast.New(raw)
-	if err != nil {
-		return ValuePath{}, err
+	return parse(raw, c, typ.ValuePath, (*parser).valuePath)
+}
+
+func (p *parser) valueFilterNot() (Expression, bool) {
+	f := p.push(typ.ValueFilterNot)
+	begin := p.pos
+	if !p.keyword("not") {
+		p.pop(f, false)
+		return nil, false
 	}
-	node, err := grammar.ValuePath(p)
-	if err != nil {
-		return ValuePath{}, err
+	p.notSpaces()
+	exp, ok := p.valueFilterParentheses()
+	p.pop(f, ok)
+	if !ok {
+		return nil, false
 	}
-	if _, err := p.Expect(parser.EOD); err != nil {
-		return ValuePath{}, err
+	notExp := NotExpression{
+		Expression: exp,
 	}
-	return c.parseValuePath(node)
+	if p.positions {
+		notExp.Span = Span{Start: begin, End: p.pos}
+	}
+	return &notExp, true
 }
 
-func (p config) parseValueFilter(node *ast.Node) (Expression, error) {
-	switch t := node.Type; t {
-	case typ.ValueLogExpOr, typ.ValueLogExpAnd:
-		children := node.Children()
-		if l := len(children); l != 2 {
-			return nil, invalidLengthError(node.Type, 2, l)
-		}
+func (p *parser) valueFilterParentheses() (Expression, bool) {
+	if !p.char('(') {
+		return nil, false
+	}
+	defer p.leave()
+	if !p.enter() {
+		return nil, false
+	}
+	p.spaces(0)
+	exp, ok := p.valueLogExpOr()
+	if !ok {
+		return nil, false
+	}
+	p.spaces(0)
+	if !p.char(')') {
+		return nil, false
+	}
+	return exp, true
+}
+
+func (p *parser) valueFilterValue() (Expression, bool) {
+	if p.err != nil {
+		return nil, false
+	}
+	start := p.pos
+	if attrExp, ok := p.attrExp(); ok {
+		return attrExp, true
+	}
+	p.pos = start
+	if exp, ok := p.valueFilterNot(); ok {
+		return exp, true
+	}
+	p.pos = start
+	return p.valueFilterParentheses()
+}
 
-		left, err := p.parseAttrExp(children[0])
-		if err != nil {
-			return nil, err
+// valueLogExpAnd parses one or more value filters separated by 'and'. Unlike
+// filters, the spaces around 'and' are optional.
+func (p *parser) valueLogExpAnd() (Expression, bool) {
+	f := p.push(typ.ValueLogExpAnd)
+	begin := p.pos
+	exp, ok := p.valueFilterValue()
+	if !ok {
+		p.pop(f, false)
+		return nil, false
+	}
+	for {
+		start := p.pos
+		if !p.spaces(p.valueSpaces()) || !p.keyword("and") || !p.spaces(p.valueSpaces()) {
+			p.pos = start
+			break
 		}
-		right, err := p.parseAttrExp(children[1])
-		if err != nil {
-			return nil, err
+		right, ok := p.valueFilterValue()
+		if !ok {
+			p.pos = start
+			break
 		}
-
-		var operator LogicalOperator
-		if node.Type == typ.ValueLogExpOr {
-			operator = OR
-		} else {
-			operator = AND
+		logExp := LogicalExpression{
+			Left:     exp,
+			Right:    right,
+			Operator: AND,
 		}
+		if p.positions {
+			logExp.Span = Span{Start: begin, End: p.pos}
+		}
+		exp = &logExp
+	}
+	p.pop(f, true)
+	return exp, true
+}
 
-		return &LogicalExpression{
-			Left:     &left,
-			Right:    &right,
-			Operator: operator,
-		}, nil
-	case typ.AttrExp:
-		attrExp, err := p.parseAttrExp(node)
-		if err != nil {
-			return nil, err
+// valueLogExpOr parses one or more valueLogExpAnd separated by 'or'. Unlike
+// filters, the spaces around 'or' are optional.
+func (p *parser) valueLogExpOr() (Expression, bool) {
+	f := p.push(typ.ValueLogExpOr)
+	begin := p.pos
+	exp, ok := p.valueLogExpAnd()
+	if !ok {
+		p.pop(f, false)
+		return nil, false
+	}
+	for {
+		start := p.pos
+		if !p.spaces(p.valueSpaces()) || !p.keyword("or") || !p.spaces(p.valueSpaces()) {
+			p.pos = start
+			break
 		}
-		return &attrExp, nil
-	case typ.ValueFilterNot:
-		children := node.Children()
-		if l := len(children); l != 1 {
-			return nil, invalidLengthError(typ.ValueFilterNot, 1, l)
+		right, ok := p.valueLogExpAnd()
+		if !ok {
+			p.pos = start
+			break
 		}
-
-		valueFilter, err := p.parseValueFilter(children[0])
-		if err != nil {
-			return nil, err
+		logExp := LogicalExpression{
+			Left:     exp,
+			Right:    right,
+			Operator: OR,
+		}
+		if p.positions {
+			logExp.Span = Span{Start: begin, End: p.pos}
 		}
-		return &NotExpression{
-			Expression: valueFilter,
-		}, nil
-	default:
-		return nil, invalidChildTypeError(typ.ValuePath, t)
+		exp = &logExp
 	}
+	p.pop(f, true)
+	return exp, true
 }
 
-func (p config) parseValuePath(node *ast.Node) (ValuePath, error) {
-	if node.Type != typ.ValuePath {
-		return ValuePath{}, invalidTypeError(typ.ValuePath, node.Type)
+func (p *parser) valuePath() (ValuePath, bool) {
+	f := p.push(typ.ValuePath)
+	begin := p.pos
+	attrPath, ok := p.attrPath()
+	if !ok {
+		p.pop(f, false)
+		return ValuePath{}, false
 	}
-
-	children := node.Children()
-	if l := len(children); l != 2 {
-		return ValuePath{}, invalidLengthError(typ.ValuePath, 2, l)
+	p.spaces(0)
+	if !p.char('[') {
+		p.pop(f, false)
+		return ValuePath{}, false
 	}
-
-	attrPath, err := parseAttrPath(children[0])
-	if err != nil {
-		return ValuePath{}, err
+	defer p.leave()
+	if !p.enter() {
+		p.pop(f, false)
+		return ValuePath{}, false
 	}
-
-	valueFilter, err := p.parseValueFilter(children[1])
-	if err != nil {
-		return ValuePath{}, err
+	p.spaces(0)
+	p.valueFilter = true
+	valueFilter, ok := p.valueLogExpOr()
+	p.valueFilter = false
+	if !ok {
+		p.pop(f, false)
+		return ValuePath{}, false
 	}
-
-	return ValuePath{
+	p.spaces(0)
+	if !p.char(']') {
+		p.pop(f, false)
+		return ValuePath{}, false
+	}
+	p.pop(f, true)
+	valuePath := ValuePath{
 		AttributePath: attrPath,
 		ValueFilter:   valueFilter,
-	}, nil
+	}
+	if p.positions {
+		valuePath.Span = Span{Start: begin, End: p.pos}
+	}
+	return valuePath, true
+}
+
+// valueSpaces returns the number of spaces that are required around the
+// logical operators of value filters. The RFC defines value filters as
+// filters, so strict mode requires the same spaces.
+func (p *parser) valueSpaces() int {
+	if p.mode == Strict {
+		return 1
+	}
+	return 0
 }
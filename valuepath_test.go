@@ -1,6 +1,9 @@
 package filter
 
-import "fmt"
+import (
+	"fmt"
+	"testing"
+)
 
 func ExampleParseValuePath() {
 	fmt.Println(ParseValuePath([]byte("emails[type eq \"work\"]")))
@@ -11,3 +14,85 @@ func ExampleParseValuePath() {
 	// emails[not(type eq "work")] <nil>
 	// emails[type eq "work" and value co "@example.com"] <nil>
 }
+
+func ExampleParseValuePath_logExp() {
+	fmt.Println(ParseValuePath([]byte("emails[type eq \"work\" and primary eq true and value ew \"@example.com\"]")))
+	fmt.Println(ParseValuePath([]byte("emails[(type eq \"work\" or type eq \"home\") and primary eq true]")))
+	// Output:
+	// emails[type eq "work" and primary eq true and value ew "@example.com"] <nil>
+	// emails[(type eq "work" or type eq "home") and primary eq true] <nil>
+}
+
+func TestParseValuePath_precedence(t *testing.T) {
+	for _, tc := range []struct {
+		input string
+		want  string
+	}{
+		{
+			input: "emails[a pr and b pr and c pr]",
+			want:  "((a pr and b pr) and c pr)",
+		},
+		{
+			input: "emails[a pr or b pr and c pr]",
+			want:  "(a pr or (b pr and c pr))",
+		},
+		{
+			input: "emails[a pr and b pr or c pr]",
+			want:  "((a pr and b pr) or c pr)",
+		},
+		{
+			input: "emails[a pr and (b pr or c pr)]",
+			want:  "(a pr and (b pr or c pr))",
+		},
+		{
+			input: "emails[(a pr)]",
+			want:  "a pr",
+		},
+		{
+			input: "emails[not (a pr or b pr) and not (c pr)]",
+			want:  "(not((a pr or b pr)) and not(c pr))",
+		},
+		{
+			input: "emails[ ( a pr  OR  b pr ) AND not(c pr) ]",
+			want:  "((a pr or b pr) and not(c pr))",
+		},
+	} {
+		t.Run(tc.input, func(t *testing.T) {
+			valuePath, err := ParseValuePath([]byte(tc.input))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := group(valuePath.ValueFilter); got != tc.want {
+				t.Errorf("got %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestParseValuePath_invalid(t *testing.T) {
+	for _, example := range []string{
+		"emails[]",
+		"emails[a pr and]",
+		"emails[(a pr]",
+		"emails[a pr)]",
+		"emails[not a pr]",
+	} {
+		t.Run(example, func(t *testing.T) {
+			if _, err := ParseValuePath([]byte(example)); err == nil {
+				t.Error("expected an error")
+			}
+		})
+	}
+}
+
+// group returns the given expression with every logical expression grouped.
+func group(e Expression) string {
+	switch e := e.(type) {
+	case *LogicalExpression:
+		return fmt.Sprintf("(%s %s %s)", group(e.Left), e.Operator, group(e.Right))
+	case *NotExpression:
+		return fmt.Sprintf("not(%s)", group(e.Expression))
+	default:
+		return fmt.Sprint(e)
+	}
+}
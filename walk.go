@@ -0,0 +1,105 @@
+package filter
+
+// Rewrite returns a new expression in which every node is replaced by the
+// result of the given function. The expression is rewritten bottom-up, the
+// function receives a copy of each node in which the children are already
+// rewritten. The given expression is not modified, but attribute paths still
+// share their URI prefix and sub attribute (see Clone).
+//
+// If the function returns nil, the node is removed. A logical expression of
+// which one operand is removed is replaced by the other operand, a 'not' or
+// value path of which the (value) filter is removed is removed itself.
+func Rewrite(expr Expression, fn func(Expression) Expression) Expression {
+	switch e := expr.(type) {
+	case nil:
+		return nil
+	case *AttributeExpression:
+		attrExp := *e
+		return fn(&attrExp)
+	case *LogicalExpression:
+		left := Rewrite(e.Left, fn)
+		right := Rewrite(e.Right, fn)
+		switch {
+		case left == nil:
+			return right
+		case right == nil:
+			return left
+		}
+		return fn(&LogicalExpression{
+			Left:     left,
+			Right:    right,
+			Operator: e.Operator,
+			Span:     e.Span,
+		})
+	case *NotExpression:
+		expression := Rewrite(e.Expression, fn)
+		if expression == nil {
+			return nil
+		}
+		return fn(&NotExpression{
+			Expression: expression,
+			Span:       e.Span,
+		})
+	case *ValuePath:
+		valueFilter := Rewrite(e.ValueFilter, fn)
+		if valueFilter == nil {
+			return nil
+		}
+		return fn(&ValuePath{
+			AttributePath: e.AttributePath,
+			ValueFilter:   valueFilter,
+			Span:          e.Span,
+		})
+	default:
+		return fn(expr)
+	}
+}
+
+// Visit traverses the expression in depth-first order and calls the method of
+// the visitor that corresponds to each node. The children of a node are only
+// visited if its method returns true.
+func Visit(expr Expression, v Visitor) {
+	Walk(expr, func(e Expression) bool {
+		switch e := e.(type) {
+		case *AttributeExpression:
+			v.VisitAttributeExpression(e)
+		case *LogicalExpression:
+			return v.VisitLogicalExpression(e)
+		case *NotExpression:
+			return v.VisitNotExpression(e)
+		case *ValuePath:
+			return v.VisitValuePath(e)
+		}
+		return false
+	})
+}
+
+// Walk traverses the expression in depth-first order. It starts by calling the
+// given function with the expression itself. If the function returns true, Walk
+// is called recursively for each of the children of the expression:
+//   - the left and right operand of a LogicalExpression,
+//   - the expression of a NotExpression,
+//   - the value filter of a ValuePath.
+func Walk(expr Expression, fn func(Expression) bool) {
+	if expr == nil || !fn(expr) {
+		return
+	}
+	switch e := expr.(type) {
+	case *LogicalExpression:
+		Walk(e.Left, fn)
+		Walk(e.Right, fn)
+	case *NotExpression:
+		Walk(e.Expression, fn)
+	case *ValuePath:
+		Walk(e.ValueFilter, fn)
+	}
+}
+
+// Visitor has a method for every type of node in an expression. The methods of
+// nodes with children return whether the children need to be visited.
+type Visitor interface {
+	VisitAttributeExpression(e *AttributeExpression)
+	VisitLogicalExpression(e *LogicalExpression) bool
+	VisitNotExpression(e *NotExpression) bool
+	VisitValuePath(e *ValuePath) bool
+}
@@ -0,0 +1,136 @@
+package filter
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func ExampleRewrite() {
+	expression, _ := ParseFilter([]byte("userName eq \"bjensen\" and not (emails[type eq \"work\"] or title pr)"))
+	fmt.Println(Rewrite(expression, func(e Expression) Expression {
+		if v, ok := e.(*AttributeExpression); ok {
+			if v.AttributePath.AttributeName == "title" {
+				return nil // Remove the node.
+			}
+			v.AttributePath.AttributeName = strings.ToUpper(v.AttributePath.AttributeName)
+		}
+		return e
+	}))
+	// Output:
+	// USERNAME eq "bjensen" and not(emails[TYPE eq "work"])
+}
+
+func ExampleVisit() {
+	expression, _ := ParseFilter([]byte("not (title pr) and emails[type eq \"work\"]"))
+	v := countVisitor{}
+	Visit(expression, &v)
+	fmt.Println(v)
+	// Output:
+	// {2 1 1 1}
+}
+
+func ExampleWalk() {
+	expression, _ := ParseFilter([]byte("not (title pr) and emails[type eq \"work\"]"))
+	Walk(expression, func(e Expression) bool {
+		fmt.Printf("%T: %v\n", e, e)
+		return true
+	})
+	// Output:
+	// *filter.LogicalExpression: not(title pr) and emails[type eq "work"]
+	// *filter.NotExpression: not(title pr)
+	// *filter.AttributeExpression: title pr
+	// *filter.ValuePath: emails[type eq "work"]
+	// *filter.AttributeExpression: type eq "work"
+}
+
+func TestRewrite(t *testing.T) {
+	raw := "a pr and not (b pr or c[d pr]) or e[f pr and g pr]"
+	expression, err := ParseFilter([]byte(raw))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, test := range []struct {
+		remove   string
+		expected string
+	}{
+		{"", "A pr and not(B pr or C[D pr]) or E[F pr and G pr]"},
+		{"a", "not(B pr or C[D pr]) or E[F pr and G pr]"},
+		{"b", "A pr and not(C[D pr]) or E[F pr and G pr]"},
+		{"d", "A pr and not(B pr) or E[F pr and G pr]"},
+		{"f", "A pr and not(B pr or C[D pr]) or E[G pr]"},
+	} {
+		t.Run(test.remove, func(t *testing.T) {
+			rewritten := Rewrite(expression, func(e Expression) Expression {
+				if v, ok := e.(*AttributeExpression); ok {
+					if v.AttributePath.AttributeName == test.remove {
+						return nil
+					}
+					v.AttributePath.AttributeName = strings.ToUpper(v.AttributePath.AttributeName)
+				}
+				if v, ok := e.(*ValuePath); ok {
+					v.AttributePath.AttributeName = strings.ToUpper(v.AttributePath.AttributeName)
+				}
+				return e
+			})
+			if s := fmt.Sprint(rewritten); s != test.expected {
+				t.Errorf("expected %s, got %s", test.expected, s)
+			}
+			// The original expression should not be modified.
+			if s := Format(expression); s != "a pr and not (b pr or c[d pr]) or e[f pr and g pr]" {
+				t.Errorf("original expression was modified: %s", s)
+			}
+		})
+	}
+
+	if e := Rewrite(expression, func(Expression) Expression { return nil }); e != nil {
+		t.Errorf("expected nil, got %v", e)
+	}
+}
+
+func TestWalk_skip(t *testing.T) {
+	expression, err := ParseFilter([]byte("a pr and not (b pr) and c[d pr]"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var visited []string
+	Walk(expression, func(e Expression) bool {
+		switch e := e.(type) {
+		case *AttributeExpression:
+			visited = append(visited, e.AttributePath.String())
+		case *NotExpression:
+			return false
+		case *ValuePath:
+			visited = append(visited, e.AttributePath.String())
+			return false
+		}
+		return true
+	})
+	if s := strings.Join(visited, ","); s != "a,c" {
+		t.Errorf("expected a,c, got %s", s)
+	}
+}
+
+type countVisitor struct {
+	attrExps, logExps, notExps, valuePaths int
+}
+
+func (v *countVisitor) VisitAttributeExpression(*AttributeExpression) {
+	v.attrExps++
+}
+
+func (v *countVisitor) VisitLogicalExpression(*LogicalExpression) bool {
+	v.logExps++
+	return true
+}
+
+func (v *countVisitor) VisitNotExpression(*NotExpression) bool {
+	v.notExps++
+	return true
+}
+
+func (v *countVisitor) VisitValuePath(*ValuePath) bool {
+	v.valuePaths++
+	return true
+}